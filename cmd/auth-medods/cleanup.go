@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+	"github.com/volchok96/auth-medods/internal/retention"
+)
+
+// retentionConfig переводит сроки хранения из конфигурации
+func retentionConfig(c config.Retention) retention.Config {
+	return retention.Config{
+		Interval:      c.Interval,
+		BatchSize:     c.BatchSize,
+		RefreshTokens: c.RefreshTokens,
+		Sessions:      c.Sessions,
+		DenylistGrace: c.DenylistGrace,
+		AuditEvents:   c.AuditEvents,
+		RateLimits:    c.RateLimits,
+		Lockouts:      c.RefreshLockouts,
+	}
+}
+
+// cleanup выполняет один проход очистки и завершается
+func cleanup(cfg *config.Config, _ []string) int {
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	defer storage.Close()
+
+	worker := retention.NewWorker(storage, pgsql.RetentionLock, retentionConfig(cfg.Retention))
+
+	stats, ran, err := worker.RunOnce(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("cleanup failed")
+		return 1
+	}
+	if !ran {
+		fmt.Println("cleanup skipped: another instance holds the retention lock")
+		return 0
+	}
+
+	for _, table := range []string{
+		retention.TableRefreshTokens,
+		retention.TableSessions,
+		retention.TableDenylist,
+		retention.TableAuditEvents,
+		retention.TableRateLimits,
+		retention.TableLockouts,
+		retention.TableReplayKeys,
+	} {
+		fmt.Printf("%s: %d pruned\n", table, stats[table])
+	}
+
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+)
+
+// clientAuthenticator настраивает проверку клиентов, запрашивающих токены.
+// По умолчанию клиент предъявляет client_id и секрет. replay - хранилище
+// использованных jti assertion, то же, что у DPoP
+func clientAuthenticator(store database.ClientStore, c config.ClientAuth, proxies *ip.Resolver,
+	replay database.ReplayStore) clientauth.Authenticator {
+	auth, err := clientauth.New(store, clientauth.Config{
+		Methods:        c.Methods,
+		CertHeader:     c.CertHeader,
+		TrustedProxies: proxies,
+		Audience:       c.AssertionAudience,
+		Replay:         replay,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid CLIENT_AUTH")
+	}
+
+	if auth == nil {
+		log.Warn().Msg("CLIENT_AUTH=none: tokens are issued to anyone who knows a user's GUID")
+	} else {
+		log.Info().Str("methods", strings.Join(c.Methods, ",")).Msg("client authentication enabled")
+	}
+
+	return auth
+}
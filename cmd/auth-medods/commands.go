@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+)
+
+const usage = `Usage: auth-medods [flags] [command]
+
+Commands:
+  serve                                          run the server (default)
+  migrate up | down [N] | version | force V      manage the database schema
+  user add [-guid GUID] -email EMAIL             create a user
+  user list [-limit N] [-offset N]               list users
+  user disable [-reason TEXT] GUID               disable a user and revoke their sessions
+  user enable GUID                               re-enable a user
+  sessions list [-all] GUID                      list active (or all) sessions of a user
+  sessions revoke [-reason TEXT] ID              revoke a session
+  sessions revoke [-reason TEXT] -user GUID      revoke all sessions of a user
+  token issue [-ip IP] [-scope S] [-aud A] GUID  issue a token pair to a user
+  token inspect [-json] TOKEN|-                  show a token's header, claims, expiry, revocation and session
+  keys rotate [-out FILE]                        generate a new token signing key
+  verify-audit [-batch N]                        verify the audit log hash chain
+  cleanup                                        run one retention pass
+
+Exit codes: 0 - success, 1 - the command failed, 2 - invalid usage.
+Commands read the same configuration as the server.
+
+Flags:
+`
+
+// command выполняет служебную команду и возвращает код завершения процесса
+type command func(cfg *config.Config, args []string) int
+
+var commands = map[string]command{
+	"serve":        serveCommand,
+	"migrate":      migrateCommand,
+	"user":         userCommand,
+	"sessions":     sessionsCommand,
+	"token":        tokenCommand,
+	"keys":         keysCommand,
+	"verify-audit": verifyAudit,
+	"cleanup":      cleanup,
+}
+
+// run выполняет команду из args; без команды запускается сервер
+func run(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		return serveCommand(cfg, nil)
+	}
+	return dispatch("auth-medods", commands, cfg, args)
+}
+
+// dispatch выбирает подкоманду по первому аргументу
+func dispatch(name string, subcommands map[string]command, cfg *config.Config, args []string) int {
+	names := make([]string, 0, len(subcommands))
+	for sub := range subcommands {
+		names = append(names, sub)
+	}
+	sort.Strings(names)
+
+	if len(args) == 0 {
+		return usageError("%s: command required, one of: %s", name, strings.Join(names, ", "))
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		return usageError("%s: unknown command %q, expected one of: %s", name, args[0], strings.Join(names, ", "))
+	}
+	return cmd(cfg, args[1:])
+}
+
+// usageError сообщает о неверном вызове команды и возвращает код 2
+func usageError(format string, a ...any) int {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	return 2
+}
+
+// openStorage подключается к базе данных; ошибка попадает в лог
+func openStorage(cfg *config.Config) (*pgsql.DB, bool) {
+	storage, err := pgsql.NewDB(cfg.Database.DSN())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to init storage")
+		return nil, false
+	}
+	return storage, true
+}
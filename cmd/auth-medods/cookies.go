@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+// tokenCookies настраивает выдачу refresh токена браузерным клиентам в cookie.
+// Без TOKEN_COOKIES=true токены возвращаются только в теле ответа
+func tokenCookies(c config.Cookies) *handlers.Cookies {
+	if !c.Enabled {
+		return nil
+	}
+
+	sameSite := map[string]http.SameSite{
+		"strict": http.SameSiteStrictMode,
+		"lax":    http.SameSiteLaxMode,
+		"none":   http.SameSiteNoneMode,
+	}[strings.ToLower(c.SameSite)]
+
+	if c.Insecure {
+		log.Warn().Msg("COOKIE_INSECURE is set, refresh cookies are sent over plain http")
+	}
+
+	return &handlers.Cookies{
+		Domain:   c.Domain,
+		SameSite: sameSite,
+		MaxAge:   c.MaxAge,
+		Insecure: c.Insecure,
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/dpop"
+)
+
+// replayStore выбирает хранилище использованных jti для DPoP proof и client
+// assertion. С DPOP_REPLAY_STORE=postgres они видны всем репликам; nil - память процесса
+func replayStore(c config.DPoP, storage database.ReplayStore) database.ReplayStore {
+	if c.ReplayStore == "postgres" {
+		return storage
+	}
+	return nil
+}
+
+// dpopVerifier настраивает проверку DPoP proof. Внешний адрес для сравнения с htu
+// берётся из DPOP_BASE_URL, затем из OIDC_ISSUER; без них - из запроса
+func dpopVerifier(c config.DPoP, oidc config.OIDC, replay database.ReplayStore) *dpop.Verifier {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = oidc.Issuer
+	}
+
+	return dpop.NewVerifier(dpop.Config{
+		BaseURL: baseURL,
+		MaxAge:  c.ProofMaxAge,
+		Replay:  replay,
+	})
+}
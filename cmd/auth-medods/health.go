@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/health"
+	"github.com/volchok96/auth-medods/internal/notify"
+)
+
+// pinger - зависимость, доступность которой можно проверить
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readinessChecker собирает проверки /readyz: база данных, версия схемы, ключи
+// подписи токенов и, если настроен SMTP, почтовый сервер. Недоступность почты
+// не снимает готовность: предупреждения не должны останавливать выдачу токенов
+func readinessChecker(storage *pgsql.DB, ownKey string, oidc *handlers.OIDC, n notify.Notifier) *health.Checker {
+	checks := []health.Check{
+		{Name: "database", Run: storage.Ping},
+		{Name: "migrations", Run: func(ctx context.Context) error {
+			return checkMigrations(ctx, storage)
+		}},
+		{Name: "signing_keys", Run: func(context.Context) error {
+			if ownKey == "" {
+				return errors.New("token signing key is not loaded")
+			}
+			if oidc != nil && oidc.Signer == nil {
+				return errors.New("ID token signing key is not loaded")
+			}
+			return nil
+		}},
+	}
+	if p, ok := n.(pinger); ok {
+		checks = append(checks, health.Check{Name: "notifier", Optional: true, Run: p.Ping})
+	}
+
+	return health.NewChecker(health.DefaultTimeout, checks...)
+}
+
+// checkMigrations требует, чтобы схема была не старше SchemaVersion. Более новая
+// схема допускается: во время обновления миграции применяются до смены реплик
+func checkMigrations(ctx context.Context, storage *pgsql.DB) error {
+	version, dirty, err := storage.MigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < pgsql.SchemaVersion {
+		return fmt.Errorf("schema version %d, expected %d", version, pgsql.SchemaVersion)
+	}
+	return nil
+}
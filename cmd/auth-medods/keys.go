@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+)
+
+// signingKeySize - длина ключа HS512 в байтах, равная размеру блока SHA-512
+const signingKeySize = 64
+
+// keysCommand управляет ключом подписи access токенов
+func keysCommand(cfg *config.Config, args []string) int {
+	return dispatch("keys", map[string]command{
+		"rotate": keysRotate,
+	}, cfg, args)
+}
+
+// keysRotate генерирует новый ключ подписи и печатает порядок его ввода. Ключ
+// хранится в конфигурации, поэтому команда ничего не меняет в работающем сервисе
+func keysRotate(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("keys rotate", flag.ExitOnError)
+	out := fs.String("out", "", "write the key to this file (mode 0600) instead of stdout")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		return usageError("keys rotate: unexpected arguments %q", fs.Args())
+	}
+
+	raw := make([]byte, signingKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		log.Error().Err(err).Msg("failed to generate key")
+		return 1
+	}
+	key := base64.RawURLEncoding.EncodeToString(raw)
+
+	if *out != "" {
+		// O_EXCL не даёт затереть ключ, который, возможно, уже введён
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write key")
+			return 1
+		}
+		_, err = fmt.Fprintln(f, key)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write key")
+			return 1
+		}
+	} else {
+		fmt.Println(key)
+	}
+
+	fmt.Fprintf(os.Stderr, `New signing key generated. To roll it out without rejecting issued tokens:
+  1. set OWN_KEY_PREVIOUS to the current OWN_KEY and OWN_KEY to the new key;
+  2. restart every replica; until all of them run with the new key, replicas
+     still on the old one reject tokens signed with the new key;
+  3. after TOKEN_TTL (%s) has passed, remove OWN_KEY_PREVIOUS and restart again.
+Refresh tokens are not signed with OWN_KEY and stay valid.
+`, cfg.Token.TTL)
+	return 0
+}
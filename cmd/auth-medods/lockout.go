@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/lockout"
+	"github.com/volchok96/auth-medods/internal/notify"
+)
+
+// lockoutPolicy переводит настройки блокировки refresh после неудачных попыток
+func lockoutPolicy(c config.Lockout) lockout.Policy {
+	return lockout.Policy{
+		UserThreshold:    c.UserThreshold,
+		SessionThreshold: c.SessionThreshold,
+		BaseBackoff:      c.BaseBackoff,
+		MaxBackoff:       c.MaxBackoff,
+		ResetAfter:       c.ResetAfter,
+	}
+}
+
+// notifier возвращает способ доставки предупреждений пользователям. Без SMTP_HOST
+// предупреждения только пишутся в лог
+func notifier(c config.SMTP) notify.Notifier {
+	if c.Host == "" {
+		log.Warn().Msg("SMTP_HOST is not set, security warnings are only logged")
+		return notify.Log{}
+	}
+
+	return notify.NewEmail(notify.SMTPConfig{
+		Host:     c.Host,
+		Port:     c.Port,
+		Username: c.Username,
+		Password: c.Password.Value(),
+		From:     c.From,
+	})
+}
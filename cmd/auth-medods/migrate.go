@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+)
+
+// migrateCommand управляет схемой базы данных миграциями, встроенными в бинарник
+func migrateCommand(cfg *config.Config, args []string) int {
+	return dispatch("migrate", map[string]command{
+		"up": func(cfg *config.Config, args []string) int {
+			if len(args) > 0 {
+				return usageError("migrate up: unexpected arguments %q", args)
+			}
+			return withMigrator(cfg, func(m *pgsql.Migrator) error {
+				if err := m.Up(); err != nil {
+					return err
+				}
+				return printSchemaVersion(m)
+			})
+		},
+		"down": func(cfg *config.Config, args []string) int {
+			steps := 1
+			switch len(args) {
+			case 0:
+			case 1:
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n <= 0 {
+					return usageError("migrate down: N must be a positive number, got %q", args[0])
+				}
+				steps = n
+			default:
+				return usageError("migrate down: expected at most one argument")
+			}
+			return withMigrator(cfg, func(m *pgsql.Migrator) error {
+				if err := m.Down(steps); err != nil {
+					return err
+				}
+				return printSchemaVersion(m)
+			})
+		},
+		"version": func(cfg *config.Config, args []string) int {
+			if len(args) > 0 {
+				return usageError("migrate version: unexpected arguments %q", args)
+			}
+			return withMigrator(cfg, printSchemaVersion)
+		},
+		"force": func(cfg *config.Config, args []string) int {
+			if len(args) != 1 {
+				return usageError("migrate force: expected a version")
+			}
+			version, err := strconv.Atoi(args[0])
+			if err != nil || version < 0 {
+				return usageError("migrate force: invalid version %q", args[0])
+			}
+			return withMigrator(cfg, func(m *pgsql.Migrator) error {
+				if err := m.Force(version); err != nil {
+					return err
+				}
+				return printSchemaVersion(m)
+			})
+		},
+	}, cfg, args)
+}
+
+// withMigrator подключается к базе и выполняет action; ошибка даёт код 1
+func withMigrator(cfg *config.Config, action func(m *pgsql.Migrator) error) int {
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	defer storage.Close()
+
+	m, err := storage.Migrator(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to init migrations")
+		return 1
+	}
+	defer m.Close()
+
+	if err := action(m); err != nil {
+		log.Error().Err(err).Msg("migration failed")
+		return 1
+	}
+	return 0
+}
+
+func printSchemaVersion(m *pgsql.Migrator) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("schema version %d", version)
+	if dirty {
+		fmt.Print(" (dirty: fix the failed migration, then run migrate force)")
+	}
+	fmt.Printf(", this build expects %d\n", pgsql.SchemaVersion)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+// oidcConfig настраивает OpenID Connect. Без OIDC_ISSUER ID токены не выдаются
+func oidcConfig(c config.OIDC, methods []string) *handlers.OIDC {
+	issuer := c.Issuer
+	if issuer == "" {
+		log.Info().Msg("OIDC_ISSUER is not set, OpenID Connect is disabled")
+		return nil
+	}
+
+	key, err := oidcSigningKey(c.SigningKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid OIDC_SIGNING_KEY")
+	}
+
+	signer, err := jwt.NewIDTokenSigner(key)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid OIDC_SIGNING_KEY")
+	}
+
+	log.Info().Str("issuer", issuer).Str("kid", signer.KeyID()).Msg("OpenID Connect enabled")
+
+	return &handlers.OIDC{
+		Issuer:      issuer,
+		Signer:      signer,
+		AuthMethods: oauthAuthMethods(methods),
+	}
+}
+
+// oidcSigningKey читает закрытый RSA ключ из PEM файла (PKCS#1 или PKCS#8).
+// Без файла генерируется временный ключ: ID токены, выданные до перезапуска,
+// перестают проверяться, а у реплик ключи разные
+func oidcSigningKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		log.Warn().Msg("OIDC_SIGNING_KEY is not set, using an ephemeral key")
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T, RSA is required", parsed)
+	}
+	return key, nil
+}
+
+// oauthAuthMethods переводит способы из CLIENT_AUTH в названия из реестра OAuth
+func oauthAuthMethods(methods []string) []string {
+	var names []string
+	for _, method := range methods {
+		switch method {
+		case clientauth.MethodNone:
+			names = append(names, "none")
+		case clientauth.MethodSecret:
+			names = append(names, "client_secret_basic", "client_secret_post")
+		case clientauth.MethodMTLS:
+			names = append(names, "tls_client_auth")
+		case clientauth.MethodAssertion:
+			names = append(names, "private_key_jwt")
+		}
+	}
+	return names
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+// rateLimitConfig переводит лимиты эндпоинтов выдачи токенов из конфигурации
+func rateLimitConfig(c config.RateLimit) ratelimit.Config {
+	cfg := ratelimit.Config{
+		Store:   c.Store,
+		Access:  ratelimit.RouteLimits{IP: c.AccessIP, GUID: c.AccessGUID},
+		Refresh: ratelimit.RouteLimits{IP: c.RefreshIP, GUID: c.RefreshGUID},
+	}
+
+	log.Info().
+		Str("store", cfg.Store).
+		Stringer("access_ip", cfg.Access.IP).
+		Stringer("access_guid", cfg.Access.GUID).
+		Stringer("refresh_ip", cfg.Refresh.IP).
+		Stringer("refresh_guid", cfg.Refresh.GUID).
+		Msg("rate limits configured")
+
+	return cfg
+}
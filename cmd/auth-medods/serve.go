@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/lockout"
+	"github.com/volchok96/auth-medods/internal/retention"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// serveCommand запускает сервер и работает до SIGINT или SIGTERM
+func serveCommand(cfg *config.Config, args []string) int {
+	if len(args) > 0 {
+		return usageError("serve: unexpected arguments %q", args)
+	}
+
+	log.Info().
+		Str("db_host", cfg.Database.Host).
+		Int("db_port", cfg.Database.Port).
+		Str("db_name", cfg.Database.Name).
+		Str("db_user", cfg.Database.User).
+		Str("db_sslmode", cfg.Database.SSLMode).
+		Dur("token_ttl", cfg.Token.TTL).
+		Msg("configuration loaded")
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingConfig(cfg.Tracing))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to init tracing")
+		return 1
+	}
+
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	prometheus.MustRegister(storage.StatsCollector(cfg.Database.Name))
+
+	notifications := notifier(cfg.SMTP)
+	oidc := oidcConfig(cfg.OIDC, cfg.ClientAuth.Methods)
+	readiness := readinessChecker(storage, cfg.Token.OwnKey.Value(), oidc, notifications)
+	proxies := trustedProxies(cfg.Server)
+	replay := replayStore(cfg.DPoP, storage)
+
+	opts := []handlers.Option{
+		handlers.WithLockout(lockout.NewGuard(storage, lockoutPolicy(cfg.Lockout))),
+		handlers.WithNotifier(notifications),
+		handlers.WithClientAuth(clientAuthenticator(storage, cfg.ClientAuth, proxies, replay)),
+		handlers.WithOIDC(oidc),
+		handlers.WithDPoP(dpopVerifier(cfg.DPoP, cfg.OIDC, replay)),
+		handlers.WithCookies(tokenCookies(cfg.Cookies)),
+		handlers.WithReadiness(readiness),
+		handlers.WithPreviousKeys(cfg.Token.PreviousKey.Value()),
+		handlers.WithTrustedProxies(proxies),
+	}
+	if cfg.AdminToken == "" {
+		log.Warn().Msg("ADMIN_TOKEN is not set, admin API is disabled")
+	}
+	if cfg.Token.PreviousKey != "" {
+		log.Info().Msg("OWN_KEY_PREVIOUS is set, tokens signed with the previous key are accepted")
+	}
+	if cfg.Server.DebugEndpoints {
+		log.Warn().Msg("DEBUG_ENDPOINTS is enabled, /debug/token exposes token and session details; do not use in production")
+		opts = append(opts, handlers.WithDebugEndpoints())
+	}
+
+	// Фоновые задачи: очистка устаревших данных, которую между репликами
+	// координирует advisory lock, и перечитывание сертификата TLS
+	tasks := newBackground()
+	tasks.Go(retention.NewWorker(storage, pgsql.RetentionLock, retentionConfig(cfg.Retention)).Run)
+	tlsConfig := serverTLS(cfg.TLS, tasks)
+
+	var servers []*http.Server
+	if cfg.Server.InternalAddr != "" {
+		internalRoutes := handlers.SetupInternalRoutes(storage, cfg.Token.OwnKey.Value(), cfg.AdminToken.Value(), opts...)
+		servers = append(servers, newServer(cfg.Server.InternalAddr, internalRoutes, tlsConfig))
+		opts = append(opts, handlers.WithInternalListener())
+	}
+	routes := handlers.SetupRoutes(storage, cfg.Token.OwnKey.Value(), cfg.Token.TTL, cfg.AdminToken.Value(),
+		rateLimitConfig(cfg.RateLimit), opts...)
+	// Основной сервер останавливается первым: служебный нужен до конца для метрик
+	servers = append([]*http.Server{newServer(cfg.Server.Addr, routes, tlsConfig)}, servers...)
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	for _, srv := range servers {
+		listen(srv)
+	}
+
+	<-done
+	log.Info().Msg("stopping server")
+	shutdown(cfg.Server, servers, readiness, tasks, done)
+
+	// Отправка span, накопленных экспортёром
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		log.Error().Err(err).Msg("failed to flush traces")
+	}
+
+	// Хранилище закрывается последним: до этого момента им пользуются запросы и фоновые задачи
+	if err := storage.Close(); err != nil {
+		log.Error().Err(err).Msg("failed to close storage")
+	}
+
+	return 0
+}
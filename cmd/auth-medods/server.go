@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/servertls"
+)
+
+// newServer настраивает HTTP сервер; с tlsConfig он принимает только HTTPS
+func newServer(addr string, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		TLSConfig:    tlsConfig,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+}
+
+// listen запускает сервер в отдельной горутине
+func listen(srv *http.Server) {
+	go func() {
+		log.Info().Str("addr", srv.Addr).Bool("tls", srv.TLSConfig != nil).Msg("server started")
+
+		var err error
+		if srv.TLSConfig != nil {
+			// Сертификат отдаёт TLSConfig.GetCertificate
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Str("addr", srv.Addr).Msg("server failed to start")
+		}
+	}()
+}
+
+// serverTLS загружает сертификат сервера и ставит его перечитывание в фоновые
+// задачи. Без TLS_CERT_FILE возвращает nil: сервер принимает HTTP
+func serverTLS(c config.TLS, tasks *background) *tls.Config {
+	cfg := servertls.Config{
+		CertFile:       c.CertFile,
+		KeyFile:        c.KeyFile,
+		ClientCA:       c.ClientCA,
+		ClientAuth:     c.ClientAuth,
+		ReloadInterval: c.ReloadInterval,
+	}
+	if !cfg.Enabled() {
+		log.Warn().Msg("TLS_CERT_FILE is not set, serving plain HTTP")
+		return nil
+	}
+
+	tlsConfig, reloader, err := servertls.New(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid TLS configuration")
+	}
+	tasks.Go(reloader.Run)
+
+	cert, _ := reloader.GetCertificate(nil)
+	log.Info().
+		Str("subject", cert.Leaf.Subject.String()).
+		Time("not_after", cert.Leaf.NotAfter).
+		Str("client_auth", c.ClientAuth).
+		Msg("TLS enabled")
+
+	return tlsConfig
+}
+
+// trustedProxies собирает Resolver адреса клиента. Формат подсетей уже проверен в config
+func trustedProxies(c config.Server) *ip.Resolver {
+	prefixes, err := ip.ParsePrefixes(c.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid TRUSTED_PROXIES")
+	}
+	if len(prefixes) == 0 {
+		log.Info().Msg("TRUSTED_PROXIES is not set, X-Forwarded-For is ignored")
+	}
+	return ip.NewResolver(prefixes)
+}
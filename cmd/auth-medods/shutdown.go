@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/health"
+)
+
+// background - фоновые задачи сервера. Их контекст отменяется при остановке,
+// после чего задача должна завершиться
+type background struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBackground() *background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &background{ctx: ctx, cancel: cancel}
+}
+
+// Go запускает задачу в отдельной горутине
+func (b *background) Go(task func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		task(b.ctx)
+	}()
+}
+
+// Stop отменяет задачи и ждёт их завершения, пока не истечёт ctx
+func (b *background) Stop(ctx context.Context) error {
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shutdown останавливает серверы, не обрывая начатые запросы. /readyz сразу
+// начинает отвечать 503; через DrainDelay серверы перестают принимать соединения
+// и одновременно ждут завершения запросов, затем останавливаются фоновые задачи.
+// На запросы и задачи вместе отводится ShutdownTimeout, после него оставшиеся
+// соединения закрываются. Повторный сигнал пропускает паузу DrainDelay
+func shutdown(c config.Server, servers []*http.Server, readiness *health.Checker, tasks *background,
+	signals <-chan os.Signal) {
+	readiness.Drain()
+
+	if c.DrainDelay > 0 {
+		log.Info().Dur("delay", c.DrainDelay).Msg("draining connections")
+		timer := time.NewTimer(c.DrainDelay)
+		select {
+		case <-timer.C:
+		case <-signals:
+			timer.Stop()
+			log.Warn().Msg("second signal received, skipping drain delay")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+
+	// Серверы останавливаются одновременно: долгий запрос на одном не отнимает
+	// время у другого
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Error().Err(err).Str("addr", srv.Addr).Msg("in-flight requests did not finish in time")
+				if errors.Is(err, context.DeadlineExceeded) {
+					_ = srv.Close()
+				}
+			} else {
+				log.Info().Str("addr", srv.Addr).Msg("server stopped gracefully")
+			}
+		}(srv)
+	}
+	wg.Wait()
+
+	// Фоновым задачам остаётся время, не израсходованное серверами
+	if err := tasks.Stop(ctx); err != nil {
+		log.Error().Err(err).Msg("background tasks did not finish in time")
+	} else {
+		log.Info().Msg("background tasks stopped")
+	}
+}
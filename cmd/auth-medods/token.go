@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/inspect"
+)
+
+// cliUserAgent записывается в сессии, открытые командой token issue
+const cliUserAgent = "auth-medods-cli"
+
+// tokenCommand выдаёт и проверяет токены пользователей
+func tokenCommand(cfg *config.Config, args []string) int {
+	return dispatch("token", map[string]command{
+		"issue":   tokenIssue,
+		"inspect": tokenInspect,
+	}, cfg, args)
+}
+
+// tokenIssue выдаёт пару токенов, как /access: новый refresh токен заменяет
+// прежний, а сессия и событие журнала аудита записываются от имени audit.ActorCLI
+func tokenIssue(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("token issue", flag.ExitOnError)
+	clientIP := fs.String("ip", "", "IP the refresh token is bound to; defaults to the user's last IP")
+	scope := fs.String("scope", "", "space separated scopes")
+	aud := fs.String("aud", "", "comma separated audiences")
+	ttl := fs.Duration("ttl", cfg.Token.TTL, "access token lifetime")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return usageError("token issue: expected a user GUID")
+	}
+	guid := fs.Arg(0)
+	if _, err := uuid.Parse(guid); err != nil {
+		return usageError("token issue: invalid guid %q", guid)
+	}
+	if *ttl <= 0 {
+		return usageError("token issue: ttl must be positive")
+	}
+
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	defer storage.Close()
+
+	ctx := context.Background()
+	user, err := storage.GetUserByGUID(ctx, guid)
+	if errors.Is(err, database.ErrUserNotFound) {
+		fmt.Fprintf(os.Stderr, "user %s not found\n", guid)
+		return 1
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get user")
+		return 1
+	}
+	now := time.Now()
+	if !user.IsActive(now) {
+		fmt.Fprintf(os.Stderr, "user %s is %s\n", guid, user.Status)
+		return 1
+	}
+	if *clientIP != "" {
+		user.IP = *clientIP
+	}
+
+	var audience []string
+	if *aud != "" {
+		audience = strings.Split(*aud, ",")
+	}
+	tokens, err := jwt.NewTokens(ctx, cfg.Token.OwnKey.Value(), jwt.Params{
+		GUID:     guid,
+		IP:       user.IP,
+		Scope:    strings.Fields(*scope),
+		Audience: audience,
+		TTL:      *ttl,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to generate tokens")
+		return 1
+	}
+
+	user.AuthTime = &now
+	user.HashedRefreshToken = tokens.RefreshHash
+	user.RefreshScope = strings.Fields(*scope)
+	user.RefreshAudience = audience
+	user.RefreshJKT = ""
+	user.RefreshClientID = ""
+	if err := storage.UpdateUser(ctx, user); err != nil {
+		log.Error().Err(err).Msg("failed to save refresh token")
+		return 1
+	}
+
+	id, err := uuid.Parse(tokens.ID)
+	if err == nil {
+		err = storage.CreateSession(ctx, &models.Session{
+			ID:        id,
+			UserGUID:  user.UserGUID,
+			IP:        user.IP,
+			UserAgent: cliUserAgent,
+			ExpiresAt: tokens.ExpiresAt,
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to save session")
+		return 1
+	}
+
+	audit.NewRecorder(storage).RecordCommand(ctx, audit.Event{
+		Type:     audit.EventTokenIssued,
+		Actor:    audit.ActorCLI,
+		UserGUID: guid,
+		Outcome:  audit.OutcomeSuccess,
+		Details:  "session " + tokens.ID,
+	})
+
+	fmt.Printf("access_token:  %s\n", tokens.Access)
+	fmt.Printf("refresh_token: %s\n", base64.StdEncoding.EncodeToString([]byte(tokens.Refresh)))
+	fmt.Printf("session:       %s\n", tokens.ID)
+	fmt.Printf("expires_at:    %s\n", tokens.ExpiresAt.Format(time.RFC3339))
+	return 0
+}
+
+// tokenInspect разбирает access токен так же, как /debug/token, и печатает заголовок,
+// claims, ключ подписи, срок действия, отзыв и сессию. Токен "-" читается из stdin,
+// чтобы не оставлять его в истории shell. Код возврата 1 - сервис токен не примет
+func tokenInspect(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("token inspect", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return usageError("token inspect: expected a token or - to read it from stdin")
+	}
+	token := fs.Arg(0)
+	if token == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to read token")
+			return 1
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	defer storage.Close()
+
+	inspector := inspect.NewInspector(storage, storage, cfg.Token.OwnKey.Value(), cfg.Token.PreviousKey.Value())
+	report, err := inspector.Inspect(context.Background(), token)
+	if errors.Is(err, inspect.ErrMalformed) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to inspect token")
+		return 1
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
+
+func printReport(report *inspect.Report) {
+	fmt.Println("header:")
+	printFields(report.Header)
+	fmt.Println("claims:")
+	printFields(report.Claims)
+
+	signature := "does not match the configured keys"
+	if report.Key != "" {
+		signature = "valid, " + report.Key + " key"
+	}
+	fmt.Printf("signature:  %s\n", signature)
+
+	expiry := report.Expiry
+	if report.ExpiresAt != nil {
+		left := time.Until(*report.ExpiresAt).Round(time.Second)
+		if left >= 0 {
+			expiry += fmt.Sprintf(", %s left", left)
+		} else {
+			expiry += fmt.Sprintf(" %s ago", -left)
+		}
+	}
+	fmt.Printf("expiry:     %s\n", expiry)
+
+	if report.Revoked != nil {
+		fmt.Printf("revoked:    %t\n", *report.Revoked)
+	}
+	if s := report.Session; s != nil {
+		fmt.Printf("session:    %s (%s), user %s, ip %s, created %s, user agent %q\n", s.ID, s.State, s.UserGUID,
+			s.IP, s.CreatedAt.Format(time.RFC3339), s.UserAgent)
+	} else if report.Revoked != nil {
+		fmt.Println("session:    not found")
+	}
+
+	if report.Valid {
+		fmt.Println("result:     accepted")
+	} else {
+		fmt.Printf("result:     rejected: %s\n", report.Reason)
+	}
+}
+
+// printFields печатает поля заголовка или payload по алфавиту. Время (iat, exp, nbf)
+// дополняется датой
+func printFields(fields map[string]any) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value, _ := json.Marshal(fields[k])
+		line := fmt.Sprintf("  %s: %s", k, value)
+		if n, ok := fields[k].(json.Number); ok && (k == "iat" || k == "exp" || k == "nbf") {
+			if sec, err := n.Int64(); err == nil {
+				line += " (" + time.Unix(sec, 0).UTC().Format(time.RFC3339) + ")"
+			}
+		}
+		fmt.Println(line)
+	}
+}
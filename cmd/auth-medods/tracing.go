@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// tracingConfig переводит настройки трассировки из конфигурации
+func tracingConfig(c config.Tracing) tracing.Config {
+	log.Info().
+		Str("exporter", c.Exporter).
+		Str("endpoint", c.Endpoint).
+		Str("service_name", c.ServiceName).
+		Msg("tracing configured")
+
+	return tracing.Config{
+		Exporter:    c.Exporter,
+		Endpoint:    c.Endpoint,
+		ServiceName: c.ServiceName,
+	}
+}
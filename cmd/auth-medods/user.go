@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+// userCommand управляет пользователями так же, как административный API,
+// и пишет изменения в журнал аудита от имени audit.ActorCLI
+func userCommand(cfg *config.Config, args []string) int {
+	return dispatch("user", map[string]command{
+		"add":     userAdd,
+		"list":    userList,
+		"disable": userSetStatus(models.UserStatusDisabled),
+		"enable":  userSetStatus(models.UserStatusActive),
+	}, cfg, args)
+}
+
+func userAdd(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("user add", flag.ExitOnError)
+	guidFlag := fs.String("guid", "", "user GUID; generated when empty")
+	email := fs.String("email", "", "user email for security warnings")
+	_ = fs.Parse(args)
+
+	guid := uuid.New()
+	if *guidFlag != "" {
+		parsed, err := uuid.Parse(*guidFlag)
+		if err != nil {
+			return usageError("user add: invalid guid %q", *guidFlag)
+		}
+		guid = parsed
+	}
+	if !handlers.ValidEmail(*email) {
+		return usageError("user add: invalid email %q", *email)
+	}
+
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	defer storage.Close()
+
+	ctx := context.Background()
+	user := &models.User{UserGUID: guid, Email: *email}
+	err := storage.CreateUser(ctx, user)
+	if errors.Is(err, database.ErrUserExists) {
+		fmt.Fprintf(os.Stderr, "user %s already exists\n", guid)
+		return 1
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create user")
+		return 1
+	}
+
+	audit.NewRecorder(storage).RecordCommand(ctx, audit.Event{
+		Type:     audit.EventUserCreated,
+		Actor:    audit.ActorCLI,
+		UserGUID: guid.String(),
+		Outcome:  audit.OutcomeSuccess,
+	})
+	fmt.Println(guid)
+	return 0
+}
+
+func userList(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("user list", flag.ExitOnError)
+	limit := fs.Int("limit", 50, "maximum number of users")
+	offset := fs.Int("offset", 0, "number of users to skip")
+	_ = fs.Parse(args)
+
+	if *limit <= 0 || *offset < 0 {
+		return usageError("user list: limit must be positive and offset must not be negative")
+	}
+
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 1
+	}
+	defer storage.Close()
+
+	users, err := storage.ListUsers(context.Background(), *limit, *offset)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list users")
+		return 1
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GUID\tEMAIL\tSTATUS\tREASON\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.UserGUID, u.Email, u.Status, u.StatusReason,
+			u.CreatedAt.Format(time.RFC3339))
+	}
+	_ = w.Flush()
+	return 0
+}
+
+// userSetStatus меняет статус пользователя; отключение отзывает его сессии
+func userSetStatus(status models.UserStatus) command {
+	return func(cfg *config.Config, args []string) int {
+		name := "user " + map[models.UserStatus]string{
+			models.UserStatusDisabled: "disable",
+			models.UserStatusActive:   "enable",
+		}[status]
+
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		reason := new(string)
+		if status != models.UserStatusActive {
+			reason = fs.String("reason", "", "reason shown in the admin API")
+		}
+		_ = fs.Parse(args)
+
+		if fs.NArg() != 1 {
+			return usageError("%s: expected a user GUID", name)
+		}
+		guid := fs.Arg(0)
+		if _, err := uuid.Parse(guid); err != nil {
+			return usageError("%s: invalid guid %q", name, guid)
+		}
+
+		storage, ok := openStorage(cfg)
+		if !ok {
+			return 1
+		}
+		defer storage.Close()
+
+		ctx := context.Background()
+		err := storage.SetUserStatus(ctx, guid, status, *reason, nil)
+		if errors.Is(err, database.ErrUserNotFound) {
+			fmt.Fprintf(os.Stderr, "user %s not found\n", guid)
+			return 1
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to change user status")
+			return 1
+		}
+
+		rec := audit.NewRecorder(storage)
+		rec.RecordCommand(ctx, audit.Event{
+			Type:     audit.EventUserStatusChanged,
+			Actor:    audit.ActorCLI,
+			UserGUID: guid,
+			Outcome:  audit.OutcomeSuccess,
+			Details:  fmt.Sprintf("status %s: %s", status, *reason),
+		})
+		if status != models.UserStatusActive {
+			rec.RecordCommand(ctx, audit.Event{
+				Type:     audit.EventTokensRevoked,
+				Actor:    audit.ActorCLI,
+				UserGUID: guid,
+				Outcome:  audit.OutcomeSuccess,
+				Details:  "all sessions revoked on " + string(status),
+			})
+		}
+		fmt.Printf("user %s is %s\n", guid, status)
+		return 0
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/config"
+)
+
+// verifyAudit проверяет цепочку хешей журнала аудита.
+// Код возврата: 0 - цепочка цела, 1 - найден разрыв, 2 - проверка не выполнена
+func verifyAudit(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("verify-audit", flag.ExitOnError)
+	batch := fs.Int("batch", 1000, "number of records read per query")
+	_ = fs.Parse(args)
+
+	if *batch <= 0 {
+		fmt.Fprintln(os.Stderr, "batch must be positive")
+		return 2
+	}
+
+	storage, ok := openStorage(cfg)
+	if !ok {
+		return 2
+	}
+	defer storage.Close()
+
+	res, err := audit.Verify(context.Background(), storage, *batch)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to verify audit log")
+		return 2
+	}
+
+	if res.Unchained > 0 {
+		fmt.Printf("skipped %d records written before hash chaining was enabled\n", res.Unchained)
+	}
+	if res.AnchorHash != "" {
+		fmt.Printf("chain starts at record %d anchored to pruned hash %s\n", res.FirstID, res.AnchorHash)
+	}
+
+	if !res.OK() {
+		fmt.Printf("audit chain BROKEN at record %d: %s (%d records verified before it)\n",
+			res.BrokenID, res.Reason, res.Checked)
+		return 1
+	}
+
+	fmt.Printf("audit chain OK: %d records verified", res.Checked)
+	if res.Checked > 0 {
+		fmt.Printf(" (ids %d..%d)", res.FirstID, res.LastID)
+	}
+	fmt.Println()
+
+	return 0
+}
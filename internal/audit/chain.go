@@ -0,0 +1,132 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// chainedContent - каноническое представление записи, от которого считается хеш.
+// Порядок и имена полей менять нельзя: это сломает проверку уже записанных данных
+type chainedContent struct {
+	PrevHash  string `json:"prev_hash"`
+	CreatedAt string `json:"created_at"`
+	Type      string `json:"type"`
+	Actor     string `json:"actor"`
+	UserGUID  string `json:"user_guid"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Outcome   string `json:"outcome"`
+	Details   string `json:"details"`
+}
+
+// Hash возвращает SHA-256 от содержимого записи и хеша предыдущей записи
+func Hash(prevHash string, e *models.AuditEvent) string {
+	content := chainedContent{
+		PrevHash:  prevHash,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		Type:      e.Type,
+		Actor:     e.Actor,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Outcome:   e.Outcome,
+		Details:   e.Details,
+	}
+	if e.UserGUID.Valid {
+		content.UserGUID = e.UserGUID.UUID.String()
+	}
+
+	// Маршалинг структуры из строк не может завершиться ошибкой
+	data, _ := json.Marshal(content)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// chain связывает новую запись с последней записью журнала
+func chain(prevHash string, e *models.AuditEvent) {
+	e.PrevHash = prevHash
+	e.Hash = Hash(prevHash, e)
+}
+
+// VerifyResult - итог проверки цепочки журнала аудита
+type VerifyResult struct {
+	// Checked - число записей, хеш которых проверен
+	Checked int
+	// Unchained - записи в начале журнала, добавленные до включения цепочки
+	Unchained int
+	// AnchorHash - prev_hash первой проверенной записи. Непустое значение означает,
+	// что более ранние записи удалены по сроку хранения
+	AnchorHash string
+	FirstID    int64
+	LastID     int64
+	// BrokenID - первая запись, на которой цепочка разорвана; 0, если цепочка цела
+	BrokenID int64
+	Reason   string
+}
+
+func (res *VerifyResult) OK() bool {
+	return res.BrokenID == 0
+}
+
+// Verify проходит журнал от первой записи к последней пачками по batchSize
+// и останавливается на первом разрыве цепочки
+func Verify(ctx context.Context, reader database.AuditChainReader, batchSize int) (*VerifyResult, error) {
+	const fn = "audit.Verify"
+
+	res := &VerifyResult{}
+	started := false
+	prevHash := ""
+	afterID := int64(0)
+
+	for {
+		events, err := reader.AuditEventsAfter(ctx, afterID, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+
+		for i := range events {
+			e := &events[i]
+			afterID = e.ID
+
+			if !started && e.Hash == "" {
+				res.Unchained++
+				continue
+			}
+
+			broken := ""
+			switch {
+			case e.Hash == "":
+				broken = "record has no hash"
+			case started && e.PrevHash != prevHash:
+				broken = "prev_hash does not match hash of previous record"
+			case Hash(e.PrevHash, e) != e.Hash:
+				broken = "record content does not match its hash"
+			}
+			if broken != "" {
+				res.BrokenID = e.ID
+				res.Reason = broken
+				return res, nil
+			}
+
+			if !started {
+				started = true
+				res.AnchorHash = e.PrevHash
+				res.FirstID = e.ID
+			}
+			res.LastID = e.ID
+			res.Checked++
+			prevHash = e.Hash
+		}
+
+		if len(events) < batchSize {
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package clientauth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/replay"
+)
+
+// AssertionType - значение client_assertion_type для подписанного JWT (RFC 7523)
+const AssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// maxAssertionLifetime ограничивает exp, чтобы перехваченную assertion нельзя
+// было долго использовать и список использованных jti оставался небольшим
+const maxAssertionLifetime = 5 * time.Minute
+
+var assertionMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// AssertionMethod проверяет JWT, подписанный закрытым ключом клиента (private_key_jwt).
+// iss и sub - client_id, aud - адрес сервиса, jti одноразовый
+type AssertionMethod struct {
+	store    database.ClientStore
+	audience string
+	replay   database.ReplayStore
+	now      func() time.Time
+}
+
+// NewAssertionMethod создаёт проверку assertion. replayStore - где помнить
+// использованные jti; nil - в памяти процесса, и повтор на другую реплику не виден
+func NewAssertionMethod(store database.ClientStore, audience string, replayStore database.ReplayStore) *AssertionMethod {
+	if replayStore == nil {
+		replayStore = replay.NewCache()
+	}
+
+	return &AssertionMethod{
+		store:    store,
+		audience: audience,
+		replay:   replayStore,
+		now:      time.Now,
+	}
+}
+
+func (m *AssertionMethod) Authenticate(r *http.Request) (*models.Client, error) {
+	const fn = "clientauth.AssertionMethod"
+
+	assertion := r.FormValue("client_assertion")
+	if assertion == "" {
+		return nil, ErrNoCredentials
+	}
+	if r.FormValue("client_assertion_type") != AssertionType {
+		return nil, fmt.Errorf("%s: unsupported assertion type: %w", fn, ErrInvalidCredentials)
+	}
+
+	var (
+		client   *models.Client
+		storeErr error
+	)
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(assertion, claims, func(t *jwt.Token) (any, error) {
+		iss, _ := claims["iss"].(string)
+		if sub, _ := claims["sub"].(string); iss == "" || sub != iss {
+			return nil, errors.New("iss and sub must be the client_id")
+		}
+
+		c, err := m.store.GetClient(r.Context(), iss)
+		if err != nil {
+			if !errors.Is(err, database.ErrClientNotFound) {
+				storeErr = err
+			}
+			return nil, err
+		}
+		if c.PublicKey == "" {
+			return nil, errors.New("client has no public key")
+		}
+		client = c
+
+		return parsePublicKey([]byte(c.PublicKey))
+	}, jwt.WithValidMethods(assertionMethods))
+	if storeErr != nil {
+		return nil, fmt.Errorf("%s: %w", fn, storeErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", fn, err, ErrInvalidCredentials)
+	}
+
+	now := m.now()
+	if !claims.VerifyAudience(m.audience, true) {
+		return nil, fmt.Errorf("%s: unexpected audience: %w", fn, ErrInvalidCredentials)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("%s: exp is required: %w", fn, ErrInvalidCredentials)
+	}
+	expiresAt := time.Unix(int64(exp), 0)
+	if expiresAt.Sub(now) > maxAssertionLifetime {
+		return nil, fmt.Errorf("%s: assertion lifetime is too long: %w", fn, ErrInvalidCredentials)
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return nil, fmt.Errorf("%s: jti is required: %w", fn, ErrInvalidCredentials)
+	}
+	// Префикс отделяет jti assertion от jti DPoP proof в общем хранилище
+	added, err := m.replay.AddReplayKey(r.Context(), "assertion:"+client.ClientID+":"+jti, expiresAt, now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	if !added {
+		return nil, fmt.Errorf("%s: assertion replayed: %w", fn, ErrInvalidCredentials)
+	}
+
+	return client, nil
+}
+
+// parsePublicKey разбирает PEM публичного ключа RSA, ECDSA или Ed25519
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported public key")
+}
+
+// ValidPublicKey сообщает, подходит ли PEM для проверки подписи assertion
+func ValidPublicKey(data string) bool {
+	_, err := parsePublicKey([]byte(data))
+	return err == nil
+}
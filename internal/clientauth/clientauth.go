@@ -0,0 +1,129 @@
+package clientauth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+)
+
+// Способы аутентификации клиента
+const (
+	MethodNone      = "none"
+	MethodSecret    = "secret"
+	MethodMTLS      = "mtls"
+	MethodAssertion = "assertion"
+)
+
+var (
+	// ErrNoCredentials - запрос не содержит данных для этого способа аутентификации
+	ErrNoCredentials = errors.New("no client credentials")
+	// ErrInvalidCredentials - данные есть, но клиент по ним не подтверждён
+	ErrInvalidCredentials = errors.New("invalid client credentials")
+)
+
+// Authenticator определяет клиента, от имени которого пришёл запрос
+type Authenticator interface {
+	Authenticate(r *http.Request) (*models.Client, error)
+}
+
+// Chain пробует способы по порядку. Первый способ, для которого в запросе есть
+// данные, решает исход: неверный секрет не даёт перейти к сертификату
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*models.Client, error) {
+	for _, method := range c {
+		client, err := method.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return client, err
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// Config - включённые способы аутентификации и их параметры
+type Config struct {
+	Methods []string
+	// CertHeader - заголовок, в котором прокси, завершающий TLS, передаёт
+	// клиентский сертификат (PEM, URL-encoded). Пусто - доверять только TLS соединению
+	CertHeader string
+	// TrustedProxies - прокси, от которых принимается CertHeader
+	TrustedProxies *ip.Resolver
+	// Audience - ожидаемое значение aud в JWT assertion
+	Audience string
+	// Replay - где помнить использованные jti assertion; nil - в памяти процесса
+	Replay database.ReplayStore
+}
+
+// New собирает цепочку из включённых способов. Возвращает nil, если аутентификация
+// клиентов отключена
+func New(store database.ClientStore, cfg Config) (Authenticator, error) {
+	const fn = "clientauth.New"
+
+	var chain Chain
+	for _, method := range cfg.Methods {
+		switch strings.TrimSpace(method) {
+		case MethodNone:
+			if len(cfg.Methods) > 1 {
+				return nil, fmt.Errorf("%s: %q can't be combined with other methods", fn, MethodNone)
+			}
+			return nil, nil
+		case MethodSecret:
+			chain = append(chain, NewSecretMethod(store))
+		case MethodMTLS:
+			chain = append(chain, NewMTLSMethod(store, cfg.CertHeader, cfg.TrustedProxies))
+		case MethodAssertion:
+			if cfg.Audience == "" {
+				return nil, fmt.Errorf("%s: assertion audience is required", fn)
+			}
+			chain = append(chain, NewAssertionMethod(store, cfg.Audience, cfg.Replay))
+		default:
+			return nil, fmt.Errorf("%s: unknown method %q", fn, method)
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%s: no methods configured", fn)
+	}
+
+	return chain, nil
+}
+
+// GenerateClientID возвращает новый идентификатор клиента
+func GenerateClientID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("clientauth.GenerateClientID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GenerateSecret возвращает новый секрет клиента и его bcrypt хеш. Секрет
+// показывается один раз и нигде не хранится
+func GenerateSecret() (secret, hash string, err error) {
+	const fn = "clientauth.GenerateSecret"
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("%s: %w", fn, err)
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return secret, string(hashed), nil
+}
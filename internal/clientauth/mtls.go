@@ -0,0 +1,91 @@
+package clientauth
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+)
+
+// MTLSMethod определяет клиента по отпечатку его TLS сертификата (RFC 8705).
+// Цепочку сертификата проверяет тот, кто завершает TLS
+type MTLSMethod struct {
+	store   database.ClientStore
+	header  string
+	proxies *ip.Resolver
+}
+
+// NewMTLSMethod принимает сертификат из заголовка header только от прокси из proxies
+// и только по HTTP: сам прокси должен удалять этот заголовок из входящих запросов
+func NewMTLSMethod(store database.ClientStore, header string, proxies *ip.Resolver) *MTLSMethod {
+	return &MTLSMethod{store: store, header: header, proxies: proxies}
+}
+
+func (m *MTLSMethod) Authenticate(r *http.Request) (*models.Client, error) {
+	const fn = "clientauth.MTLSMethod"
+
+	cert, err := m.certificate(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	if cert == nil {
+		return nil, ErrNoCredentials
+	}
+
+	client, err := m.store.GetClientByThumbprint(r.Context(), Thumbprint(cert))
+	if errors.Is(err, database.ErrClientNotFound) {
+		return nil, fmt.Errorf("%s: %w", fn, ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return client, nil
+}
+
+func (m *MTLSMethod) certificate(r *http.Request) (*x509.Certificate, error) {
+	if r.TLS != nil {
+		if len(r.TLS.PeerCertificates) > 0 {
+			return r.TLS.PeerCertificates[0], nil
+		}
+		return nil, nil
+	}
+	// Заголовок может задать кто угодно, поэтому ему верим только от прокси
+	if m.header == "" || !m.proxies.Trusted(r) {
+		return nil, nil
+	}
+
+	value := r.Header.Get(m.header)
+	if value == "" {
+		return nil, nil
+	}
+
+	decoded, err := url.QueryUnescape(value)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	block, _ := pem.Decode([]byte(decoded))
+	if block == nil {
+		return nil, ErrInvalidCredentials
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return cert, nil
+}
+
+// Thumbprint возвращает отпечаток сертификата в формате x5t#S256
+func Thumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,55 @@
+package clientauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// dummyHash сравнивается с секретом неизвестного клиента, чтобы время ответа
+// не выдавало, зарегистрирован ли client_id
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("unknown client"), bcrypt.DefaultCost)
+
+// SecretMethod проверяет client_id и секрет из заголовка Authorization: Basic
+// (client_secret_basic) или из полей формы (client_secret_post)
+type SecretMethod struct {
+	store database.ClientStore
+}
+
+func NewSecretMethod(store database.ClientStore) *SecretMethod {
+	return &SecretMethod{store: store}
+}
+
+func (m *SecretMethod) Authenticate(r *http.Request) (*models.Client, error) {
+	const fn = "clientauth.SecretMethod"
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok && r.Method == http.MethodPost {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+		ok = clientID != "" && secret != ""
+	}
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	client, err := m.store.GetClient(r.Context(), clientID)
+	if errors.Is(err, database.ErrClientNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(secret))
+		return nil, fmt.Errorf("%s: %w", fn, ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if client.SecretHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret)) != nil {
+		return nil, fmt.Errorf("%s: %w", fn, ErrInvalidCredentials)
+	}
+
+	return client, nil
+}
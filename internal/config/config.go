@@ -0,0 +1,172 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+	"github.com/volchok96/auth-medods/internal/redact"
+)
+
+// Config - настройки сервиса. У каждого поля есть ключ в файле конфигурации (key),
+// переменная окружения (env) и флаг командной строки, имя которого - ключ с дефисами
+// вместо точек и подчёркиваний (db.ssl_mode -> -db-ssl-mode)
+type Config struct {
+	Server     Server
+	TLS        TLS
+	Token      Token
+	Database   Database
+	AdminToken redact.Secret `key:"admin.token" env:"ADMIN_TOKEN" usage:"токен административного API; пустой отключает его"`
+	RateLimit  RateLimit
+	Lockout    Lockout
+	ClientAuth ClientAuth
+	SMTP       SMTP
+	OIDC       OIDC
+	DPoP       DPoP
+	Cookies    Cookies
+	Retention  Retention
+	Tracing    Tracing
+}
+
+type Server struct {
+	Addr string `key:"server.addr" env:"SERVER_ADDR" default:":8080" usage:"адрес HTTP сервера"`
+	// DrainDelay - сколько после сигнала остановки /readyz отвечает 503 до закрытия
+	// listener, чтобы балансировщик успел исключить реплику
+	DrainDelay      time.Duration `key:"server.drain_delay" env:"SHUTDOWN_DRAIN_DELAY" default:"5s" usage:"пауза перед остановкой приёма запросов"`
+	ShutdownTimeout time.Duration `key:"server.shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"20s" usage:"время на завершение начатых запросов и фоновых задач"`
+	// InternalAddr - адрес listener для /admin и /metrics; пока он не задан,
+	// эти маршруты обслуживает основной сервер
+	InternalAddr string `key:"server.internal_addr" env:"INTERNAL_ADDR" usage:"адрес служебного сервера для /admin и /metrics"`
+	// TrustedProxies - подсети прокси, от которых принимается X-Forwarded-For;
+	// без них адрес клиента - адрес соединения
+	TrustedProxies []string `key:"server.trusted_proxies" env:"TRUSTED_PROXIES" usage:"подсети доверенных прокси через запятую"`
+	// DebugEndpoints включает /debug/token под ADMIN_TOKEN; только для среды разработки
+	DebugEndpoints bool `key:"server.debug_endpoints" env:"DEBUG_ENDPOINTS" usage:"включить отладочные эндпоинты под admin.token (только для разработки)"`
+}
+
+// TLS - сертификаты сервера. Без сертификата сервер принимает только HTTP
+type TLS struct {
+	CertFile string `key:"tls.cert_file" env:"TLS_CERT_FILE" usage:"сертификат сервера (PEM, с цепочкой); пустой - без TLS"`
+	KeyFile  string `key:"tls.key_file" env:"TLS_KEY_FILE" usage:"закрытый ключ сервера (PEM)"`
+	// ClientAuth - проверка клиентских сертификатов: none, request, optional или require
+	ClientAuth     string        `key:"tls.client_auth" env:"TLS_CLIENT_AUTH" default:"none" usage:"проверка клиентских сертификатов"`
+	ClientCA       string        `key:"tls.client_ca" env:"TLS_CLIENT_CA" usage:"CA сертификаты клиентов (PEM) для optional и require"`
+	ReloadInterval time.Duration `key:"tls.reload_interval" env:"TLS_RELOAD_INTERVAL" default:"1m" usage:"как часто проверять файлы сертификата; 0 - не перечитывать"`
+}
+
+type Token struct {
+	OwnKey redact.Secret `key:"token.own_key" env:"OWN_KEY" usage:"ключ подписи access токенов"`
+	// PreviousKey - ключ до последней смены; подписанные им токены принимаются до истечения срока
+	PreviousKey redact.Secret `key:"token.previous_key" env:"OWN_KEY_PREVIOUS" usage:"предыдущий ключ подписи, действует на время смены ключа"`
+	TTL         time.Duration `key:"token.ttl" env:"TOKEN_TTL" default:"30m" usage:"срок действия access токена"`
+}
+
+type Database struct {
+	Host     string        `key:"db.host" env:"DB_HOST" default:"localhost" usage:"хост PostgreSQL"`
+	Port     int           `key:"db.port" env:"DB_PORT" default:"5432" usage:"порт PostgreSQL"`
+	User     string        `key:"db.user" env:"DB_USER" usage:"пользователь PostgreSQL"`
+	Password redact.Secret `key:"db.password" env:"DB_PASSWORD" usage:"пароль PostgreSQL"`
+	Name     string        `key:"db.name" env:"DB_NAME" usage:"имя базы данных"`
+	// SSLMode - режим TLS libpq: disable, require, verify-ca или verify-full
+	SSLMode     string `key:"db.ssl_mode" env:"DB_SSLMODE" default:"require" usage:"режим TLS подключения к базе"`
+	SSLRootCert string `key:"db.ssl_root_cert" env:"DB_SSLROOTCERT" usage:"CA сертификат сервера базы для verify-ca и verify-full"`
+}
+
+// DSN - строка подключения к базе. Имя пользователя и пароль экранируются
+func (d Database) DSN() string {
+	query := url.Values{"sslmode": {d.SSLMode}}
+	if d.SSLRootCert != "" {
+		query.Set("sslrootcert", d.SSLRootCert)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password.Value()),
+		Host:     net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:     "/" + d.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+type RateLimit struct {
+	Store       string          `key:"rate_limit.store" env:"RATE_LIMIT_STORE" default:"memory" usage:"хранилище ограничителя: memory или postgres"`
+	AccessIP    ratelimit.Limit `key:"rate_limit.access_ip" env:"RATE_LIMIT_ACCESS_IP" default:"30/1m" usage:"лимит /access по IP"`
+	AccessGUID  ratelimit.Limit `key:"rate_limit.access_guid" env:"RATE_LIMIT_ACCESS_GUID" default:"10/1m" usage:"лимит /access по GUID"`
+	RefreshIP   ratelimit.Limit `key:"rate_limit.refresh_ip" env:"RATE_LIMIT_REFRESH_IP" default:"30/1m" usage:"лимит /refresh по IP"`
+	RefreshGUID ratelimit.Limit `key:"rate_limit.refresh_guid" env:"RATE_LIMIT_REFRESH_GUID" default:"10/1m" usage:"лимит /refresh по GUID"`
+}
+
+type Lockout struct {
+	UserThreshold    int           `key:"lockout.user_threshold" env:"LOCKOUT_USER_THRESHOLD" default:"10" usage:"неудачных refresh с настоящим токеном до блокировки пользователя"`
+	SessionThreshold int           `key:"lockout.session_threshold" env:"LOCKOUT_SESSION_THRESHOLD" default:"5" usage:"неудачных refresh до блокировки одной сессии или IP с неверными токенами"`
+	BaseBackoff      time.Duration `key:"lockout.base_backoff" env:"LOCKOUT_BASE_BACKOFF" default:"1m" usage:"первая блокировка"`
+	MaxBackoff       time.Duration `key:"lockout.max_backoff" env:"LOCKOUT_MAX_BACKOFF" default:"24h" usage:"наибольшая блокировка"`
+	ResetAfter       time.Duration `key:"lockout.reset_after" env:"LOCKOUT_RESET_AFTER" default:"24h" usage:"сброс счётчиков без неудачных попыток"`
+}
+
+type ClientAuth struct {
+	Methods           []string `key:"client_auth.methods" env:"CLIENT_AUTH" default:"secret" usage:"способы аутентификации клиентов через запятую"`
+	CertHeader        string   `key:"client_auth.cert_header" env:"CLIENT_CERT_HEADER" usage:"заголовок с клиентским сертификатом от прокси"`
+	AssertionAudience string   `key:"client_auth.assertion_audience" env:"CLIENT_ASSERTION_AUDIENCE" usage:"ожидаемый aud в JWT assertion клиента"`
+}
+
+type SMTP struct {
+	Host     string        `key:"smtp.host" env:"SMTP_HOST" usage:"почтовый сервер; пустой - предупреждения только в лог"`
+	Port     int           `key:"smtp.port" env:"SMTP_PORT" default:"587" usage:"порт почтового сервера"`
+	Username string        `key:"smtp.username" env:"SMTP_USERNAME" usage:"пользователь почтового сервера"`
+	Password redact.Secret `key:"smtp.password" env:"SMTP_PASSWORD" usage:"пароль почтового сервера"`
+	From     string        `key:"smtp.from" env:"SMTP_FROM" usage:"адрес отправителя"`
+}
+
+type OIDC struct {
+	Issuer     string `key:"oidc.issuer" env:"OIDC_ISSUER" usage:"издатель ID токенов; пустой отключает OpenID Connect"`
+	SigningKey string `key:"oidc.signing_key" env:"OIDC_SIGNING_KEY" usage:"PEM файл RSA ключа подписи ID токенов"`
+}
+
+type DPoP struct {
+	BaseURL     string        `key:"dpop.base_url" env:"DPOP_BASE_URL" usage:"внешний адрес сервиса для htu; по умолчанию oidc.issuer"`
+	ProofMaxAge time.Duration `key:"dpop.proof_max_age" env:"DPOP_PROOF_MAX_AGE" default:"1m" usage:"сколько принимается DPoP proof"`
+	ReplayStore string        `key:"dpop.replay_store" env:"DPOP_REPLAY_STORE" default:"memory" usage:"где помнить использованные jti DPoP proof и client assertion: memory или postgres"`
+}
+
+type Cookies struct {
+	Enabled  bool          `key:"cookies.enabled" env:"TOKEN_COOKIES" usage:"выдавать refresh токен в HttpOnly cookie клиентам с заголовком X-Token-Delivery: cookie"`
+	Domain   string        `key:"cookies.domain" env:"COOKIE_DOMAIN" usage:"домен cookie"`
+	SameSite string        `key:"cookies.same_site" env:"COOKIE_SAMESITE" default:"strict" usage:"SameSite cookie: strict, lax или none"`
+	MaxAge   time.Duration `key:"cookies.max_age" env:"COOKIE_MAX_AGE" default:"720h" usage:"срок жизни cookie"`
+	Insecure bool          `key:"cookies.insecure" env:"COOKIE_INSECURE" usage:"снять флаг Secure для разработки по http"`
+}
+
+type Retention struct {
+	Interval        time.Duration `key:"retention.interval" env:"RETENTION_INTERVAL" default:"1h" usage:"период фоновой очистки; 0 - отключить"`
+	BatchSize       int           `key:"retention.batch_size" env:"RETENTION_BATCH_SIZE" default:"1000" usage:"строк за один запрос очистки"`
+	RefreshTokens   time.Duration `key:"retention.refresh_tokens" env:"RETENTION_REFRESH_TOKENS" default:"720h" usage:"хранение неиспользуемых refresh токенов"`
+	Sessions        time.Duration `key:"retention.sessions" env:"RETENTION_SESSIONS" default:"720h" usage:"хранение истёкших сессий"`
+	DenylistGrace   time.Duration `key:"retention.denylist_grace" env:"RETENTION_DENYLIST_GRACE" default:"1h" usage:"хранение отозванных токенов после истечения"`
+	AuditEvents     time.Duration `key:"retention.audit_events" env:"RETENTION_AUDIT_EVENTS" default:"0" usage:"хранение журнала аудита; 0 - бессрочно"`
+	RateLimits      time.Duration `key:"retention.rate_limits" env:"RETENTION_RATE_LIMITS" default:"24h" usage:"хранение счётчиков ограничителя"`
+	RefreshLockouts time.Duration `key:"retention.refresh_lockouts" env:"RETENTION_REFRESH_LOCKOUTS" default:"168h" usage:"хранение счётчиков блокировки refresh"`
+}
+
+type Tracing struct {
+	Exporter    string `key:"tracing.exporter" env:"TRACING_EXPORTER" default:"none" usage:"экспорт трасс: none, otlp или stdout"`
+	Endpoint    string `key:"tracing.endpoint" env:"TRACING_ENDPOINT" usage:"адрес коллектора OTLP/HTTP; по умолчанию из OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `key:"tracing.service_name" env:"TRACING_SERVICE_NAME" default:"auth-medods" usage:"имя сервиса в трассах"`
+}
+
+var secretType = reflect.TypeOf(redact.Secret(""))
+
+// Secrets возвращает значения всех секретных полей, чтобы убрать их из логов
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, f := range collect(reflect.ValueOf(c).Elem()) {
+		if f.value.Type() == secretType && f.value.String() != "" {
+			secrets = append(secrets, f.value.String())
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Переменные окружения, которые управляют самой загрузкой
+const (
+	// EnvConfigFile - путь к файлу конфигурации YAML или TOML (как флаг -config)
+	EnvConfigFile = "CONFIG_FILE"
+	// EnvDotenvFile - путь к файлу .env; по умолчанию .env в рабочем каталоге
+	EnvDotenvFile = "ENV_FILE"
+	// fileSuffix - суффикс переменной, в которой передаётся путь к файлу со значением
+	fileSuffix = "_FILE"
+)
+
+// LookupEnv - источник переменных окружения, обычно os.LookupEnv
+type LookupEnv func(key string) (string, bool)
+
+// field - поле Config вместе с его тегами
+type field struct {
+	key, env, def, usage string
+	value                reflect.Value
+}
+
+// flagName - имя флага командной строки для ключа
+func (f field) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+// Load собирает настройки из источников по возрастанию приоритета: значения по
+// умолчанию, файл конфигурации, файл .env, переменные окружения (NAME или NAME_FILE
+// с путём к файлу со значением) и флаги командной строки. Возвращает аргументы
+// после флагов и ошибку *Errors со всеми неверными полями
+func Load(args []string, lookupEnv LookupEnv) (*Config, []string, error) {
+	cfg := &Config{}
+	fields := collect(reflect.ValueOf(cfg).Elem())
+
+	fs := flag.NewFlagSet("auth-medods", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", "", "файл конфигурации YAML или TOML")
+	flags := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flags[f.key] = fs.String(f.flagName(), "", f.usage+" ("+f.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+	setFlags := map[string]bool{}
+	fs.Visit(func(fl *flag.Flag) { setFlags[fl.Name] = true })
+
+	var errs Errors
+
+	if *configFile == "" {
+		*configFile, _ = lookupEnv(EnvConfigFile)
+	}
+	var fileValues map[string]string
+	if *configFile != "" {
+		var err error
+		if fileValues, err = readFile(*configFile); err != nil {
+			return nil, nil, fmt.Errorf("config: %s: %w", *configFile, err)
+		}
+	}
+
+	dotenv, err := readDotenv(lookupEnv)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config: %w", err)
+	}
+	env := func(key string) (string, bool) {
+		if v, ok := lookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := dotenv[key]
+		return v, ok
+	}
+
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+
+		raw, source := f.def, "default"
+		if v, ok := fileValues[f.key]; ok {
+			raw, source = v, *configFile
+		}
+		if v, src, ok, err := envValue(env, f.env); err != nil {
+			errs = append(errs, FieldError{Key: f.key, Env: f.env, Source: src, Err: err})
+			continue
+		} else if ok {
+			raw, source = v, src
+		}
+		if setFlags[f.flagName()] {
+			raw, source = *flags[f.key], "-"+f.flagName()
+		}
+
+		if err := set(f.value, raw); err != nil {
+			errs = append(errs, FieldError{Key: f.key, Env: f.env, Source: source, Err: err})
+		}
+	}
+
+	for key := range fileValues {
+		if !known[key] {
+			errs = append(errs, FieldError{Key: key, Source: *configFile, Err: errors.New("unknown key")})
+		}
+	}
+
+	// Поле, которое не разобралось, осталось нулевым: проверка повторила бы ту же ошибку
+	failed := make(map[string]bool, len(errs))
+	for _, fe := range errs {
+		failed[fe.Key] = true
+	}
+	for _, fe := range cfg.validate() {
+		if !failed[fe.Key] {
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		errs.sort()
+		return nil, nil, &errs
+	}
+	return cfg, fs.Args(), nil
+}
+
+// envValue читает переменную name или файл из name_FILE. Задать обе нельзя
+func envValue(env LookupEnv, name string) (value, source string, ok bool, err error) {
+	path, fromFile := env(name + fileSuffix)
+	value, direct := env(name)
+
+	switch {
+	case fromFile && direct:
+		return "", name, false, fmt.Errorf("both %s and %s%s are set", name, name, fileSuffix)
+	case fromFile:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", name + fileSuffix, false, err
+		}
+		// Редакторы и секреты Docker/Kubernetes часто оставляют перевод строки в конце
+		return strings.TrimRight(string(data), "\r\n"), name + fileSuffix, true, nil
+	case direct:
+		return value, name, true, nil
+	}
+	return "", "", false, nil
+}
+
+// readDotenv читает файл .env из ENV_FILE или рабочего каталога. Отсутствующий
+// файл по умолчанию не ошибка: переменные могут быть заданы окружением
+func readDotenv(lookupEnv LookupEnv) (map[string]string, error) {
+	path, explicit := lookupEnv(EnvDotenvFile)
+	if !explicit {
+		path = ".env"
+	}
+
+	values, err := godotenv.Read(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// collect обходит вложенные структуры и возвращает поля с тегом key
+func collect(v reflect.Value) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if key := sf.Tag.Get("key"); key != "" {
+			fields = append(fields, field{
+				key:   key,
+				env:   sf.Tag.Get("env"),
+				def:   sf.Tag.Get("default"),
+				usage: sf.Tag.Get("usage"),
+				value: v.Field(i),
+			})
+			continue
+		}
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collect(v.Field(i))...)
+		}
+	}
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// set разбирает строковое значение в поле его типа
+func set(v reflect.Value, raw string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+
+	switch {
+	case v.Type() == durationType:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		if raw == "" {
+			v.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Usage выводит флаги и соответствующие им переменные окружения
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "  -config string\n    \tфайл конфигурации YAML или TOML ("+EnvConfigFile+")")
+	for _, f := range collect(reflect.ValueOf(&Config{}).Elem()) {
+		line := fmt.Sprintf("  -%s\n    \t%s (%s)", f.flagName(), f.usage, f.env)
+		if f.def != "" {
+			line += fmt.Sprintf(" (default %q)", f.def)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
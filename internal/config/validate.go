@@ -0,0 +1,152 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/servertls"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// clientAuthMethods - способы аутентификации клиентов, которые принимает client_auth.methods
+var clientAuthMethods = []string{
+	clientauth.MethodNone, clientauth.MethodSecret, clientauth.MethodMTLS, clientauth.MethodAssertion,
+}
+
+// FieldError - неверное значение одного поля. Source - откуда взято значение:
+// default, файл конфигурации, переменная окружения или флаг
+type FieldError struct {
+	Key    string
+	Env    string
+	Source string
+	Err    error
+}
+
+func (e FieldError) Error() string {
+	name := e.Key
+	if e.Env != "" {
+		name += " (" + e.Env + ")"
+	}
+	if e.Source != "" && e.Source != e.Env {
+		name += " from " + e.Source
+	}
+	return name + ": " + e.Err.Error()
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Errors - все неверные поля конфигурации
+type Errors []FieldError
+
+func (e *Errors) Error() string {
+	lines := make([]string, 0, len(*e)+1)
+	lines = append(lines, fmt.Sprintf("config: %d invalid field(s):", len(*e)))
+	for _, fe := range *e {
+		lines = append(lines, "  "+fe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *Errors) sort() {
+	sort.SliceStable(*e, func(i, j int) bool { return (*e)[i].Key < (*e)[j].Key })
+}
+
+// validate проверяет значения, которые по отдельности разобрались, но недопустимы
+func (c *Config) validate() Errors {
+	var errs Errors
+	check := func(ok bool, key, env, msg string) {
+		if !ok {
+			errs = append(errs, FieldError{Key: key, Env: env, Err: errors.New(msg)})
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr", "SERVER_ADDR", "is required")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay", "SHUTDOWN_DRAIN_DELAY", "must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "must be positive")
+	check(c.Server.InternalAddr != c.Server.Addr, "server.internal_addr", "INTERNAL_ADDR", "must differ from server.addr")
+	check(!c.Server.DebugEndpoints || c.AdminToken != "", "server.debug_endpoints", "DEBUG_ENDPOINTS", "requires admin.token")
+	_, err := ip.ParsePrefixes(c.Server.TrustedProxies)
+	check(err == nil, "server.trusted_proxies", "TRUSTED_PROXIES", "must be a list of IP addresses or CIDR subnets")
+
+	check(c.TLS.CertFile == "" || c.TLS.KeyFile != "", "tls.key_file", "TLS_KEY_FILE", "is required with tls.cert_file")
+	check(c.TLS.KeyFile == "" || c.TLS.CertFile != "", "tls.cert_file", "TLS_CERT_FILE", "is required with tls.key_file")
+	check(slices.Contains(servertls.ClientAuthModes, c.TLS.ClientAuth), "tls.client_auth", "TLS_CLIENT_AUTH",
+		"must be none, request, optional or require")
+	check(c.TLS.ClientAuth == servertls.ClientAuthNone || c.TLS.CertFile != "", "tls.client_auth", "TLS_CLIENT_AUTH",
+		"requires tls.cert_file")
+	verifiesClients := c.TLS.ClientAuth == servertls.ClientAuthOptional || c.TLS.ClientAuth == servertls.ClientAuthRequire
+	check(!verifiesClients || c.TLS.ClientCA != "", "tls.client_ca", "TLS_CLIENT_CA",
+		"is required for optional and require client auth")
+	check(c.TLS.ReloadInterval >= 0, "tls.reload_interval", "TLS_RELOAD_INTERVAL", "must not be negative")
+
+	check(c.Token.OwnKey != "", "token.own_key", "OWN_KEY", "is required")
+	check(c.Token.PreviousKey == "" || c.Token.PreviousKey != c.Token.OwnKey, "token.previous_key", "OWN_KEY_PREVIOUS",
+		"must differ from token.own_key")
+	check(c.Token.TTL > 0, "token.ttl", "TOKEN_TTL", "must be positive")
+
+	check(c.Database.Host != "", "db.host", "DB_HOST", "is required")
+	check(c.Database.Port > 0 && c.Database.Port <= 65535, "db.port", "DB_PORT", "must be between 1 and 65535")
+	check(c.Database.User != "", "db.user", "DB_USER", "is required")
+	check(c.Database.Name != "", "db.name", "DB_NAME", "is required")
+	check(slices.Contains([]string{"disable", "require", "verify-ca", "verify-full"}, c.Database.SSLMode),
+		"db.ssl_mode", "DB_SSLMODE", "must be disable, require, verify-ca or verify-full")
+
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "postgres",
+		"rate_limit.store", "RATE_LIMIT_STORE", "must be memory or postgres")
+
+	check(c.Lockout.UserThreshold > 0, "lockout.user_threshold", "LOCKOUT_USER_THRESHOLD", "must be positive")
+	check(c.Lockout.SessionThreshold > 0, "lockout.session_threshold", "LOCKOUT_SESSION_THRESHOLD", "must be positive")
+	check(c.Lockout.BaseBackoff > 0, "lockout.base_backoff", "LOCKOUT_BASE_BACKOFF", "must be positive")
+	check(c.Lockout.MaxBackoff >= c.Lockout.BaseBackoff, "lockout.max_backoff", "LOCKOUT_MAX_BACKOFF",
+		"must not be less than lockout.base_backoff")
+
+	check(len(c.ClientAuth.Methods) > 0, "client_auth.methods", "CLIENT_AUTH", "is required")
+	for _, method := range c.ClientAuth.Methods {
+		check(slices.Contains(clientAuthMethods, method), "client_auth.methods", "CLIENT_AUTH",
+			fmt.Sprintf("unknown method %q, expected %s", method, strings.Join(clientAuthMethods, ", ")))
+	}
+	check(!slices.Contains(c.ClientAuth.Methods, clientauth.MethodNone) || len(c.ClientAuth.Methods) == 1,
+		"client_auth.methods", "CLIENT_AUTH", "none can't be combined with other methods")
+	check(!slices.Contains(c.ClientAuth.Methods, clientauth.MethodAssertion) || c.ClientAuth.AssertionAudience != "",
+		"client_auth.assertion_audience", "CLIENT_ASSERTION_AUDIENCE", "is required for the assertion method")
+	check(c.ClientAuth.CertHeader == "" || len(c.Server.TrustedProxies) > 0,
+		"client_auth.cert_header", "CLIENT_CERT_HEADER", "requires server.trusted_proxies")
+
+	check(c.SMTP.Port > 0 && c.SMTP.Port <= 65535, "smtp.port", "SMTP_PORT", "must be between 1 and 65535")
+
+	check(c.OIDC.Issuer == "" || absoluteURL(c.OIDC.Issuer), "oidc.issuer", "OIDC_ISSUER", "must be an absolute URL")
+	check(c.DPoP.BaseURL == "" || absoluteURL(c.DPoP.BaseURL), "dpop.base_url", "DPOP_BASE_URL", "must be an absolute URL")
+	check(c.DPoP.ProofMaxAge > 0, "dpop.proof_max_age", "DPOP_PROOF_MAX_AGE", "must be positive")
+	check(c.DPoP.ReplayStore == "memory" || c.DPoP.ReplayStore == "postgres",
+		"dpop.replay_store", "DPOP_REPLAY_STORE", "must be memory or postgres")
+
+	check(slices.Contains([]string{"strict", "lax", "none"}, strings.ToLower(c.Cookies.SameSite)),
+		"cookies.same_site", "COOKIE_SAMESITE", "must be strict, lax or none")
+	check(!strings.EqualFold(c.Cookies.SameSite, "none") || !c.Cookies.Insecure,
+		"cookies.same_site", "COOKIE_SAMESITE", "none requires Secure cookies, unset cookies.insecure")
+	check(c.Cookies.MaxAge >= 0, "cookies.max_age", "COOKIE_MAX_AGE", "must not be negative")
+
+	check(c.Retention.Interval >= 0, "retention.interval", "RETENTION_INTERVAL", "must not be negative")
+	check(c.Retention.BatchSize > 0, "retention.batch_size", "RETENTION_BATCH_SIZE", "must be positive")
+
+	check(slices.Contains(tracing.Exporters, c.Tracing.Exporter), "tracing.exporter", "TRACING_EXPORTER",
+		"must be none, otlp or stdout")
+	check(c.Tracing.Endpoint == "" || absoluteURL(c.Tracing.Endpoint), "tracing.endpoint", "TRACING_ENDPOINT",
+		"must be an absolute URL")
+	check(c.Tracing.ServiceName != "", "tracing.service_name", "TRACING_SERVICE_NAME", "is required")
+
+	return errs
+}
+
+func absoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
@@ -1,9 +1,123 @@
 package database
 
-import "github.com/volchok96/auth-medods/internal/database/models"
+import (
+	"context"
+	"errors"
+	"time"
 
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserInactive = errors.New("user is locked or disabled")
+	// ErrRefreshConflict - refresh токен уже обменян параллельным запросом
+	ErrRefreshConflict = errors.New("refresh token was rotated concurrently")
+
+	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// DBInterface - хранилище пользователей и сессий. Методы этого и остальных хранилищ
+// принимают контекст вызова: его отмена прерывает запрос к базе, а span трассировки
+// из него становится родителем span запроса
 type DBInterface interface {
-	UpdateUser(user *models.User) error
-	GetUserByGUID(guid string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	UpdateUser(ctx context.Context, user *models.User) error
+	// UpdateUserEmail меняет только email пользователя, не затрагивая refresh токен
+	UpdateUserEmail(ctx context.Context, guid, email string) error
+	// RotateRefreshToken сохраняет пользователя с новым refresh токеном, только если
+	// хеш прежнего всё ещё previousHash; иначе возвращает ErrRefreshConflict
+	RotateRefreshToken(ctx context.Context, user *models.User, previousHash string) error
+	GetUserByGUID(ctx context.Context, guid string) (*models.User, error)
+	ListUsers(ctx context.Context, limit, offset int) ([]models.User, error)
+	DeleteUser(ctx context.Context, guid string) error
+	// SetUserStatus меняет статус пользователя; при блокировке или отключении
+	// аннулирует refresh токен и вносит невыгоревшие access токены в denylist
+	SetUserStatus(ctx context.Context, guid string, status models.UserStatus, reason string, until *time.Time) error
+	CreateSession(ctx context.Context, session *models.Session) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
 	Close() error
 }
+
+// SessionStore - просмотр и отзыв сессий. Отзыв вносит access токены сессии в denylist
+type SessionStore interface {
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	// ListSessions возвращает сессии пользователя от новых к старым; activeOnly
+	// оставляет только неотозванные и не истёкшие
+	ListSessions(ctx context.Context, guid string, activeOnly bool) ([]models.Session, error)
+	// RevokeSession отзывает одну сессию и возвращает GUID её пользователя; если
+	// сессия последняя, аннулируется и refresh токен
+	RevokeSession(ctx context.Context, id, reason string) (string, error)
+	// RevokeUserSessions отзывает все сессии пользователя и его refresh токен
+	RevokeUserSessions(ctx context.Context, guid, reason string) error
+}
+
+// AuditStore - хранилище журнала аудита, допускающее только добавление записей.
+// AppendAuditEvent вызывает chain с хешем последней записи и должна сохранить
+// событие в том же порядке, в котором выдавались хеши
+type AuditStore interface {
+	AppendAuditEvent(ctx context.Context, event *models.AuditEvent,
+		chain func(prevHash string, event *models.AuditEvent)) error
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// AuditChainReader читает журнал аудита в порядке добавления для проверки цепочки хешей
+type AuditChainReader interface {
+	AuditEventsAfter(ctx context.Context, afterID int64, limit int) ([]models.AuditEvent, error)
+}
+
+// RetentionStore удаляет устаревшие данные пачками не больше limit записей
+// и возвращает число затронутых записей
+type RetentionStore interface {
+	PruneRefreshTokens(ctx context.Context, issuedBefore time.Time, limit int) (int64, error)
+	PruneSessions(ctx context.Context, expiredBefore time.Time, limit int) (int64, error)
+	PruneDenylist(ctx context.Context, expiredBefore time.Time, limit int) (int64, error)
+	PruneAuditEvents(ctx context.Context, createdBefore time.Time, limit int) (int64, error)
+	PruneRateLimits(ctx context.Context, updatedBefore time.Time, limit int) (int64, error)
+	PruneRefreshLockouts(ctx context.Context, lastFailureBefore time.Time, limit int) (int64, error)
+	PruneReplayKeys(ctx context.Context, expiredBefore time.Time, limit int) (int64, error)
+	// TryLock пытается взять сессионный advisory lock; unlock освобождает его
+	TryLock(ctx context.Context, key int64) (unlock func(), ok bool, err error)
+}
+
+// RateLimitStore хранит состояние ограничителя запросов. TakeRateLimit вызывает
+// take с текущим состоянием ключа и сохраняет изменённое состояние так, чтобы
+// параллельные запросы с тем же ключом не видели промежуточных значений
+type RateLimitStore interface {
+	TakeRateLimit(ctx context.Context, key string, take func(bucket *models.RateLimitBucket)) error
+}
+
+// ReplayStore помнит одноразовые идентификаторы (jti) до истечения их срока действия
+type ReplayStore interface {
+	// AddReplayKey запоминает ключ до expiresAt и возвращает false, если ключ
+	// уже встречался и к моменту now ещё не истёк
+	AddReplayKey(ctx context.Context, key string, expiresAt, now time.Time) (bool, error)
+}
+
+// LockoutStore хранит счётчики неудачных попыток refresh
+type LockoutStore interface {
+	// UpdateRefreshLockout блокирует счётчик области до сохранения и передаёт его update;
+	// счётчик, которого ещё нет, приходит с нулевыми значениями
+	UpdateRefreshLockout(ctx context.Context, guid, scope, subject string,
+		update func(lockout *models.RefreshLockout)) error
+	ListRefreshLockouts(ctx context.Context, guid string) ([]models.RefreshLockout, error)
+	// ResetRefreshLockout сбрасывает счётчики пользователя и сессии subject после успешного refresh
+	ResetRefreshLockout(ctx context.Context, guid, subject string) error
+	// ClearRefreshLockouts снимает все блокировки пользователя и возвращает число сброшенных счётчиков
+	ClearRefreshLockouts(ctx context.Context, guid string) (int64, error)
+}
+
+// ClientStore хранит клиентов, которым разрешено запрашивать токены
+type ClientStore interface {
+	CreateClient(ctx context.Context, client *models.Client) error
+	// UpdateClient перезаписывает имя, хеш секрета, отпечаток сертификата и публичный ключ
+	UpdateClient(ctx context.Context, client *models.Client) error
+	GetClient(ctx context.Context, clientID string) (*models.Client, error)
+	GetClientByThumbprint(ctx context.Context, thumbprint string) (*models.Client, error)
+	ListClients(ctx context.Context) ([]models.Client, error)
+	DeleteClient(ctx context.Context, clientID string) error
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent - запись журнала аудита
+type AuditEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	Type      string
+	Actor     string
+	UserGUID  uuid.NullUUID
+	IP        string
+	UserAgent string
+	Outcome   string
+	Details   string
+	// PrevHash и Hash связывают записи в цепочку: Hash вычисляется от содержимого
+	// записи и PrevHash, который равен Hash предыдущей записи
+	PrevHash string
+	Hash     string
+}
+
+// AuditFilter - условия выборки из журнала аудита; пустые поля не ограничивают выборку
+type AuditFilter struct {
+	UserGUID string
+	Types    []string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Offset   int
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Client - зарегистрированный клиент (фронтенд или сервис), которому разрешено
+// запрашивать токены от имени пользователей. Пустое поле отключает соответствующий
+// способ аутентификации клиента
+type Client struct {
+	ID       int64
+	ClientID string
+	Name     string
+	// SecretHash - bcrypt хеш секрета клиента
+	SecretHash string
+	// CertThumbprint - SHA-256 отпечаток клиентского сертификата (x5t#S256, base64url)
+	CertThumbprint string
+	// PublicKey - PEM публичного ключа, которым клиент подписывает JWT assertion
+	PublicKey string
+	// AllowedScopes и AllowedAudiences - области доступа и получатели (aud),
+	// которые клиент может запрашивать для токенов
+	AllowedScopes    []string
+	AllowedAudiences []string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
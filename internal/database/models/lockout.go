@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Области, в которых считаются неудачные попытки refresh
+const (
+	// LockoutScopeUser - попытки с настоящим токеном пользователя из любой сессии
+	LockoutScopeUser = "user"
+	// LockoutScopeSession - попытки одной клиентской сессии, которая определяется
+	// отпечатком предъявленного refresh токена, а для чужих токенов - источником
+	// запросов (lockout.SourceID)
+	LockoutScopeSession = "session"
+)
+
+// RefreshLockout - счётчик неудачных попыток refresh в одной области.
+// Subject пуст для области пользователя и содержит отпечаток токена или источник для сессии
+type RefreshLockout struct {
+	UserGUID      uuid.UUID
+	Scope         string
+	Subject       string
+	Failures      int
+	Lockouts      int
+	LockedUntil   *time.Time
+	LastFailureAt time.Time
+}
+
+func (l *RefreshLockout) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && l.LockedUntil.After(now)
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RateLimitBucket - состояние token bucket для одного ключа ограничения запросов.
+// Новый ключ имеет нулевое UpdatedAt, то есть полный запас
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session - запись о выданной паре токенов; ID совпадает с jti access токена
+type Session struct {
+	ID        uuid.UUID
+	UserGUID  uuid.UUID
+	IP        string
+	UserAgent string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// Состояния сессии
+const (
+	SessionActive  = "active"
+	SessionExpired = "expired"
+	SessionRevoked = "revoked"
+)
+
+// State - состояние сессии на момент now; отзыв важнее истечения срока
+func (s *Session) State(now time.Time) string {
+	switch {
+	case s.RevokedAt != nil:
+		return SessionRevoked
+	case !now.Before(s.ExpiresAt):
+		return SessionExpired
+	default:
+		return SessionActive
+	}
+}
@@ -1,11 +1,62 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserStatus - состояние учётной записи
+type UserStatus string
+
+const (
+	UserStatusActive   UserStatus = "active"
+	UserStatusLocked   UserStatus = "locked"
+	UserStatusDisabled UserStatus = "disabled"
+)
+
+func (s UserStatus) Valid() bool {
+	switch s {
+	case UserStatusActive, UserStatusLocked, UserStatusDisabled:
+		return true
+	}
+	return false
+}
 
 type User struct {
-	ID               int
-	UserGUID         uuid.UUID
-	IP           string
+	ID                 int
+	UserGUID           uuid.UUID
+	IP                 string
 	HashedRefreshToken string
-	Email string
-}
\ No newline at end of file
+	// PreviousRefreshHash - хеш refresh токена, обменянного последним
+	PreviousRefreshHash string
+	Email               string
+	Status              UserStatus
+	StatusReason        string
+	StatusUntil         *time.Time
+	// AuthTime - когда пользователю последний раз выдали токены по GUID; refresh его сохраняет
+	AuthTime *time.Time
+	// RefreshScope и RefreshAudience - области доступа и получатели, выданные
+	// вместе с текущим refresh токеном
+	RefreshScope    []string
+	RefreshAudience []string
+	// RefreshJKT - отпечаток ключа DPoP, к которому привязан refresh токен
+	RefreshJKT string
+	// RefreshClientID - клиент, которому выдан refresh токен; пусто - выдан без аутентификации клиента
+	RefreshClientID string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// IsActive сообщает, может ли пользователь получать и обновлять токены.
+// Блокировка с истёкшим сроком StatusUntil считается снятой.
+func (u *User) IsActive(now time.Time) bool {
+	switch u.Status {
+	case UserStatusDisabled:
+		return false
+	case UserStatusLocked:
+		return u.StatusUntil != nil && !now.Before(*u.StatusUntil)
+	default:
+		return true
+	}
+}
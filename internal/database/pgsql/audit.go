@@ -0,0 +1,160 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// auditChainLock - ключ advisory lock, под которым записи журнала аудита
+// добавляются строго по одной, чтобы цепочка хешей не ветвилась
+const auditChainLock = 0x6d65646f6473_01
+
+const auditColumns = `id, created_at, event_type, actor, user_guid, ip, user_agent, outcome, details,
+		prev_hash, hash`
+
+func (db *DB) AppendAuditEvent(ctx context.Context, event *models.AuditEvent,
+	chain func(prevHash string, event *models.AuditEvent)) (err error) {
+	const fn = "database.pgsql.AppendAuditEvent"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLock); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	var prevHash string
+	err = tx.QueryRowContext(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	// Время задаётся до вычисления хеша с точностью, которую хранит PostgreSQL
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	chain(prevHash, event)
+
+	query := `
+		INSERT INTO audit_events (created_at, event_type, actor, user_guid, ip, user_agent, outcome, details,
+			prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query, event.CreatedAt, event.Type, event.Actor, event.UserGUID, event.IP,
+		event.UserAgent, event.Outcome, event.Details, event.PrevHash, event.Hash).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) ListAuditEvents(ctx context.Context, filter models.AuditFilter) (_ []models.AuditEvent, err error) {
+	const fn = "database.pgsql.ListAuditEvents"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	var (
+		conds []string
+		args  []any
+	)
+	where := func(cond string, arg any) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.UserGUID != "" {
+		where("user_guid = $%d", filter.UserGUID)
+	}
+	if len(filter.Types) > 0 {
+		where("event_type = ANY($%d)", pq.Array(filter.Types))
+	}
+	if filter.From != nil {
+		where("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at < $%d", *filter.To)
+	}
+
+	query := `SELECT ` + auditColumns + `
+		  FROM audit_events`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	events, err := scanAuditEvents(rows, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return events, nil
+}
+
+// AuditEventsAfter возвращает записи с id больше afterID в порядке добавления
+func (db *DB) AuditEventsAfter(ctx context.Context, afterID int64, limit int) (_ []models.AuditEvent, err error) {
+	const fn = "database.pgsql.AuditEventsAfter"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `SELECT ` + auditColumns + `
+		  FROM audit_events
+		  WHERE id > $1
+		  ORDER BY id
+		  LIMIT $2`
+
+	rows, err := db.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	events, err := scanAuditEvents(rows, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return events, nil
+}
+
+func scanAuditEvents(rows *sql.Rows, capacity int) ([]models.AuditEvent, error) {
+	events := make([]models.AuditEvent, 0, capacity)
+	for rows.Next() {
+		var e models.AuditEvent
+		err := rows.Scan(&e.ID, &e.CreatedAt, &e.Type, &e.Actor, &e.UserGUID, &e.IP, &e.UserAgent,
+			&e.Outcome, &e.Details, &e.PrevHash, &e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,169 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+const clientColumns = `id, client_id, name, COALESCE(secret_hash, ''), COALESCE(cert_thumbprint, ''),
+		COALESCE(public_key, ''), allowed_scopes, allowed_audiences, created_at, updated_at`
+
+func scanClient(row rowScanner) (*models.Client, error) {
+	client := &models.Client{}
+	err := row.Scan(&client.ID, &client.ClientID, &client.Name, &client.SecretHash, &client.CertThumbprint,
+		&client.PublicKey, (*pq.StringArray)(&client.AllowedScopes), (*pq.StringArray)(&client.AllowedAudiences),
+		&client.CreatedAt, &client.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// CreateClient сохраняет пустые поля как NULL, чтобы уникальность отпечатка сертификата
+// не мешала клиентам без сертификата
+func (db *DB) CreateClient(ctx context.Context, client *models.Client) (err error) {
+	const fn = "database.pgsql.CreateClient"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		INSERT INTO clients (client_id, name, secret_hash, cert_thumbprint, public_key,
+			allowed_scopes, allowed_audiences)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''),
+			COALESCE($6::text[], '{}'), COALESCE($7::text[], '{}'))
+		RETURNING id, created_at, updated_at
+	`
+
+	err = db.db.QueryRowContext(ctx, query, client.ClientID, client.Name, client.SecretHash, client.CertThumbprint,
+		client.PublicKey, pq.StringArray(client.AllowedScopes), pq.StringArray(client.AllowedAudiences)).
+		Scan(&client.ID, &client.CreatedAt, &client.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%s: %w", fn, database.ErrClientExists)
+		}
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) UpdateClient(ctx context.Context, client *models.Client) (err error) {
+	const fn = "database.pgsql.UpdateClient"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		UPDATE clients
+		SET name = $2, secret_hash = NULLIF($3, ''), cert_thumbprint = NULLIF($4, ''),
+			public_key = NULLIF($5, ''), allowed_scopes = COALESCE($6::text[], '{}'),
+			allowed_audiences = COALESCE($7::text[], '{}'), updated_at = NOW()
+		WHERE client_id = $1
+		RETURNING updated_at
+	`
+
+	err = db.db.QueryRowContext(ctx, query, client.ClientID, client.Name, client.SecretHash, client.CertThumbprint,
+		client.PublicKey, pq.StringArray(client.AllowedScopes), pq.StringArray(client.AllowedAudiences)).
+		Scan(&client.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", fn, database.ErrClientNotFound)
+	}
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%s: %w", fn, database.ErrClientExists)
+		}
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) GetClient(ctx context.Context, clientID string) (_ *models.Client, err error) {
+	const fn = "database.pgsql.GetClient"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	client, err := scanClient(db.db.QueryRowContext(ctx, `SELECT `+clientColumns+` FROM clients WHERE client_id = $1`,
+		clientID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", fn, database.ErrClientNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return client, nil
+}
+
+func (db *DB) GetClientByThumbprint(ctx context.Context, thumbprint string) (_ *models.Client, err error) {
+	const fn = "database.pgsql.GetClientByThumbprint"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	client, err := scanClient(db.db.QueryRowContext(ctx,
+		`SELECT `+clientColumns+` FROM clients WHERE cert_thumbprint = $1`, thumbprint))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", fn, database.ErrClientNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return client, nil
+}
+
+func (db *DB) ListClients(ctx context.Context) (_ []models.Client, err error) {
+	const fn = "database.pgsql.ListClients"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	rows, err := db.db.QueryContext(ctx, `SELECT `+clientColumns+` FROM clients ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	var clients []models.Client
+	for rows.Next() {
+		client, err := scanClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		clients = append(clients, *client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return clients, nil
+}
+
+func (db *DB) DeleteClient(ctx context.Context, clientID string) (err error) {
+	const fn = "database.pgsql.DeleteClient"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	n, err := db.execCount(ctx, fn, `DELETE FROM clients WHERE client_id = $1`, clientID)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", fn, database.ErrClientNotFound)
+	}
+
+	return nil
+}
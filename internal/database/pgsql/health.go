@@ -0,0 +1,40 @@
+package pgsql
+
+import (
+	"context"
+	"fmt"
+)
+
+// SchemaVersion - номер последней миграции из каталога migrations, с которой
+// совместим этот код
+const SchemaVersion = 16
+
+// Ping проверяет, что база данных доступна
+func (db *DB) Ping(ctx context.Context) (err error) {
+	const fn = "database.pgsql.Ping"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	if err = db.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// MigrationVersion возвращает версию схемы из таблицы schema_migrations, которую
+// ведёт migrate; dirty означает, что последняя миграция не применилась до конца
+func (db *DB) MigrationVersion(ctx context.Context) (version int64, dirty bool, err error) {
+	const fn = "database.pgsql.MigrationVersion"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	err = db.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return version, dirty, nil
+}
@@ -0,0 +1,113 @@
+package pgsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+const lockoutColumns = `user_guid, scope, subject, failures, lockouts, locked_until, last_failure_at`
+
+func (db *DB) UpdateRefreshLockout(ctx context.Context, guid, scope, subject string,
+	update func(lockout *models.RefreshLockout)) (err error) {
+	const fn = "database.pgsql.UpdateRefreshLockout"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_lockouts (user_guid, scope, subject, last_failure_at) VALUES ($1, $2, $3, 'epoch')
+		ON CONFLICT (user_guid, scope, subject) DO NOTHING
+	`, guid, scope, subject)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	lockout := &models.RefreshLockout{}
+	err = tx.QueryRowContext(ctx, `SELECT `+lockoutColumns+` FROM refresh_lockouts
+		WHERE user_guid = $1 AND scope = $2 AND subject = $3 FOR UPDATE`, guid, scope, subject).
+		Scan(&lockout.UserGUID, &lockout.Scope, &lockout.Subject, &lockout.Failures, &lockout.Lockouts,
+			&lockout.LockedUntil, &lockout.LastFailureAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	update(lockout)
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE refresh_lockouts SET failures = $4, lockouts = $5, locked_until = $6, last_failure_at = $7
+		WHERE user_guid = $1 AND scope = $2 AND subject = $3
+	`, guid, scope, subject, lockout.Failures, lockout.Lockouts, lockout.LockedUntil, lockout.LastFailureAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) ListRefreshLockouts(ctx context.Context, guid string) (_ []models.RefreshLockout, err error) {
+	const fn = "database.pgsql.ListRefreshLockouts"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	rows, err := db.db.QueryContext(ctx, `SELECT `+lockoutColumns+` FROM refresh_lockouts
+		WHERE user_guid = $1 ORDER BY scope DESC, subject`, guid)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	var lockouts []models.RefreshLockout
+	for rows.Next() {
+		var l models.RefreshLockout
+		if err := rows.Scan(&l.UserGUID, &l.Scope, &l.Subject, &l.Failures, &l.Lockouts,
+			&l.LockedUntil, &l.LastFailureAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		lockouts = append(lockouts, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return lockouts, nil
+}
+
+func (db *DB) ResetRefreshLockout(ctx context.Context, guid, subject string) (err error) {
+	const fn = "database.pgsql.ResetRefreshLockout"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		DELETE FROM refresh_lockouts
+		WHERE user_guid = $1 AND (scope = 'user' OR (scope = 'session' AND subject = $2))
+	`
+
+	if _, err := db.db.ExecContext(ctx, query, guid, subject); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) ClearRefreshLockouts(ctx context.Context, guid string) (_ int64, err error) {
+	const fn = "database.pgsql.ClearRefreshLockouts"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	return db.execCount(ctx, fn, `DELETE FROM refresh_lockouts WHERE user_guid = $1`, guid)
+}
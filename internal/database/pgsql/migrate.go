@@ -0,0 +1,109 @@
+package pgsql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/migrations"
+)
+
+// Migrator применяет миграции, встроенные в бинарник, и ведёт таблицу
+// schema_migrations так же, как утилита migrate
+type Migrator struct {
+	m *migrate.Migrate
+}
+
+// Migrator занимает соединение хранилища до вызова Close. Параллельный запуск
+// с другой репликой исключает advisory lock внутри migrate
+func (db *DB) Migrator(ctx context.Context) (*Migrator, error) {
+	const fn = "database.pgsql.Migrator"
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	// WithConnection закрывает только соединение, пул хранилища остаётся открытым
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		_ = driver.Close()
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	m.Log = migrateLogger{}
+
+	return &Migrator{m: m}, nil
+}
+
+// Up применяет все новые миграции. Актуальная схема ошибкой не считается
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("database.pgsql.Migrator.Up: %w", err)
+	}
+	return nil
+}
+
+// Down откатывает steps последних миграций
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("database.pgsql.Migrator.Down: steps must be positive, got %d", steps)
+	}
+	if err := m.m.Steps(-steps); err != nil {
+		return fmt.Errorf("database.pgsql.Migrator.Down: %w", err)
+	}
+	return nil
+}
+
+// Force записывает версию схемы без выполнения миграций и снимает признак dirty.
+// Нужен после ручного исправления миграции, упавшей на середине
+func (m *Migrator) Force(version int) error {
+	if err := m.m.Force(version); err != nil {
+		return fmt.Errorf("database.pgsql.Migrator.Force: %w", err)
+	}
+	return nil
+}
+
+// Version возвращает текущую версию схемы; 0 - миграции ещё не применялись
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("database.pgsql.Migrator.Version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// Close освобождает соединение
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// migrateLogger выводит сообщения migrate о применённых миграциях в лог сервиса
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) {
+	log.Info().Msg(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
@@ -1,13 +1,22 @@
 package pgsql
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/volchok96/auth-medods/internal/database"
 	"github.com/volchok96/auth-medods/internal/database/models"
 )
 
+// uniqueViolation - код ошибки PostgreSQL при нарушении уникального ограничения
+const uniqueViolation = "23505"
+
 // DB реализует интерфейс DBInterface
 type DB struct {
 	db *sql.DB
@@ -32,29 +41,353 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
-func (db *DB) UpdateUser(user *models.User) error {
+// StatsCollector - метрики пула соединений database/sql (go_sql_*) с меткой db_name
+func (db *DB) StatsCollector(dbName string) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db.db, dbName)
+}
+
+const userColumns = `id, user_guid, COALESCE(ip, ''), COALESCE(hashed_refresh_token, ''),
+		COALESCE(previous_refresh_hash, ''), email,
+		status, status_reason, status_until, auth_time, refresh_scope, refresh_audience,
+		COALESCE(refresh_jkt, ''), COALESCE(refresh_client_id, ''), created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(&user.ID, &user.UserGUID, &user.IP, &user.HashedRefreshToken, &user.PreviousRefreshHash, &user.Email,
+		&user.Status, &user.StatusReason, &user.StatusUntil, &user.AuthTime,
+		(*pq.StringArray)(&user.RefreshScope), (*pq.StringArray)(&user.RefreshAudience), &user.RefreshJKT,
+		&user.RefreshClientID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (db *DB) CreateUser(ctx context.Context, user *models.User) (err error) {
+	const fn = "database.pgsql.CreateUser"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
 	query := `
-		INSERT INTO users (user_guid, ip, hashed_refresh_token, email)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (user_guid) 
-		DO UPDATE SET ip = EXCLUDED.ip, hashed_refresh_token = EXCLUDED.hashed_refresh_token,
-		email = EXCLUDED.email
+		INSERT INTO users (user_guid, email, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at
 	`
 
-	_, err := db.db.Exec(query, user.UserGUID, user.IP, user.HashedRefreshToken, user.Email)
-	return err
+	if user.Status == "" {
+		user.Status = models.UserStatusActive
+	}
+
+	err = db.db.QueryRowContext(ctx, query, user.UserGUID, user.Email, user.Status).
+		Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return fmt.Errorf("%s: %w", fn, database.ErrUserExists)
+		}
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
 }
 
-func (db *DB) GetUserByGUID(guid string) (*models.User, error) {
-	user := &models.User{}
-	query := `SELECT id, user_guid, ip, hashed_refresh_token, email 
-		  FROM users 
+// UpdateUser обновляет существующего пользователя; неизвестный GUID не создаётся.
+// Статус меняется только через SetUserStatus, а новый refresh токен не сохраняется
+// для заблокированного или отключённого пользователя.
+func (db *DB) UpdateUser(ctx context.Context, user *models.User) (err error) {
+	const fn = "database.pgsql.UpdateUser"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	if err := db.updateUser(ctx, user, nil); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	return nil
+}
+
+// UpdateUserEmail меняет email пользователя. Остальные поля строки не
+// перезаписываются, поэтому параллельный обмен refresh токена не теряется
+func (db *DB) UpdateUserEmail(ctx context.Context, guid, email string) (err error) {
+	const fn = "database.pgsql.UpdateUserEmail"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	res, err := db.db.ExecContext(ctx, `UPDATE users SET email = $2, updated_at = NOW() WHERE user_guid = $1`,
+		guid, email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", fn, database.ErrUserNotFound)
+	}
+	return nil
+}
+
+// RotateRefreshToken сохраняет пользователя, как UpdateUser, только если хеш его
+// refresh токена всё ещё previousHash. Иначе токен уже обменял параллельный запрос
+// и возвращается ErrRefreshConflict: один токен нельзя обменять дважды
+func (db *DB) RotateRefreshToken(ctx context.Context, user *models.User, previousHash string) (err error) {
+	const fn = "database.pgsql.RotateRefreshToken"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	if err := db.updateUser(ctx, user, &previousHash); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	return nil
+}
+
+// updateUser сохраняет пользователя; с previousHash - только при совпадении текущего хеша
+func (db *DB) updateUser(ctx context.Context, user *models.User, previousHash *string) error {
+	query := `
+		UPDATE users
+		SET ip = $2, hashed_refresh_token = $3, email = $4, auth_time = $5,
+			refresh_scope = $6, refresh_audience = $7, refresh_jkt = NULLIF($8, ''),
+			refresh_client_id = NULLIF($9, ''), updated_at = NOW(),
+			refresh_issued_at = CASE
+				WHEN hashed_refresh_token IS DISTINCT FROM $3 THEN NOW()
+				ELSE refresh_issued_at
+			END,
+			previous_refresh_hash = CASE
+				WHEN hashed_refresh_token IS DISTINCT FROM $3 THEN hashed_refresh_token
+				ELSE previous_refresh_hash
+			END
+		WHERE user_guid = $1
+		  AND ($3 = '' OR status = 'active' OR (status = 'locked' AND status_until <= NOW()))
+		  AND ($10::text IS NULL OR COALESCE(hashed_refresh_token, '') = $10)
+		RETURNING updated_at
+	`
+
+	err := db.db.QueryRowContext(ctx, query, user.UserGUID, user.IP, user.HashedRefreshToken, user.Email, user.AuthTime,
+		pq.StringArray(user.RefreshScope), pq.StringArray(user.RefreshAudience), user.RefreshJKT, user.RefreshClientID,
+		previousHash).Scan(&user.UpdatedAt)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// Ни одна строка не обновлена: выясняем почему
+	var currentHash string
+	err = db.db.QueryRowContext(ctx, `SELECT COALESCE(hashed_refresh_token, '') FROM users WHERE user_guid = $1`,
+		user.UserGUID).Scan(&currentHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if previousHash != nil && currentHash != *previousHash {
+		return database.ErrRefreshConflict
+	}
+	return database.ErrUserInactive
+}
+
+func (db *DB) GetUserByGUID(ctx context.Context, guid string) (_ *models.User, err error) {
+	const fn = "database.pgsql.GetUserByGUID"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `SELECT ` + userColumns + `
+		  FROM users
 		  WHERE user_guid = $1`
 
-	err := db.db.QueryRow(query, guid).Scan(&user.ID, &user.UserGUID, &user.IP, &user.HashedRefreshToken, &user.Email)
+	user, err := scanUser(db.db.QueryRowContext(ctx, query, guid))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", fn, database.ErrUserNotFound)
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", fn, err)
 	}
 
 	return user, nil
 }
+
+func (db *DB) ListUsers(ctx context.Context, limit, offset int) (_ []models.User, err error) {
+	const fn = "database.pgsql.ListUsers"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `SELECT ` + userColumns + `
+		  FROM users
+		  ORDER BY id
+		  LIMIT $1 OFFSET $2`
+
+	rows, err := db.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0, limit)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return users, nil
+}
+
+func (db *DB) DeleteUser(ctx context.Context, guid string) (err error) {
+	const fn = "database.pgsql.DeleteUser"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	// Сессии удаляются вместе с пользователем, поэтому его действующие access
+	// токены вносятся в denylist до удаления строки
+	if err := revokeUserSessions(ctx, tx, guid, "deleted"); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE user_guid = $1`, guid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", fn, database.ErrUserNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) SetUserStatus(ctx context.Context, guid string, status models.UserStatus, reason string,
+	until *time.Time) (err error) {
+	const fn = "database.pgsql.SetUserStatus"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET status = $2, status_reason = $3, status_until = $4, updated_at = NOW()
+		WHERE user_guid = $1
+	`
+
+	res, err := tx.ExecContext(ctx, query, guid, status, reason, until)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", fn, database.ErrUserNotFound)
+	}
+
+	if status != models.UserStatusActive {
+		if err := revokeUserSessions(ctx, tx, guid, string(status)); err != nil {
+			return fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// revokeUserSessions аннулирует refresh токен пользователя и вносит в denylist
+// все access токены, срок действия которых ещё не истёк
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, guid, reason string) error {
+	query := `UPDATE users SET hashed_refresh_token = NULL, previous_refresh_hash = NULL WHERE user_guid = $1`
+	if _, err := tx.ExecContext(ctx, query, guid); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO token_denylist (jti, user_guid, reason, expires_at)
+		SELECT id, user_guid, $2, expires_at
+		FROM sessions
+		WHERE user_guid = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ON CONFLICT (jti) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, query, guid, reason); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_guid = $1 AND revoked_at IS NULL`,
+		guid)
+	return err
+}
+
+func (db *DB) CreateSession(ctx context.Context, session *models.Session) (err error) {
+	const fn = "database.pgsql.CreateSession"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		INSERT INTO sessions (id, user_guid, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`
+
+	err = db.db.QueryRowContext(ctx, query, session.ID, session.UserGUID, session.IP, session.UserAgent,
+		session.ExpiresAt).Scan(&session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+func (db *DB) IsTokenRevoked(ctx context.Context, jti string) (_ bool, err error) {
+	const fn = "database.pgsql.IsTokenRevoked"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	var revoked bool
+	err = db.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM token_denylist WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return revoked, nil
+}
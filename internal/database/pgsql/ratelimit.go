@@ -0,0 +1,53 @@
+package pgsql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// TakeRateLimit блокирует строку ключа до конца транзакции, поэтому реплики,
+// разделяющие базу, расходуют один общий запас запросов
+func (db *DB) TakeRateLimit(ctx context.Context, key string, take func(bucket *models.RateLimitBucket)) (err error) {
+	const fn = "database.pgsql.TakeRateLimit"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	// Новый ключ создаётся с давним updated_at, что соответствует полному запасу
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, 0, 'epoch')
+		ON CONFLICT (key) DO NOTHING
+	`, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	bucket := &models.RateLimitBucket{Key: key}
+	err = tx.QueryRowContext(ctx, `SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE`, key).
+		Scan(&bucket.Tokens, &bucket.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	take(bucket)
+
+	_, err = tx.ExecContext(ctx, `UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1`,
+		key, bucket.Tokens, bucket.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package pgsql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AddReplayKey запоминает ключ до expiresAt. Истёкший ключ перезаписывается тем же
+// запросом, поэтому из двух реплик, одновременно получивших один jti, ключ добавит одна
+func (db *DB) AddReplayKey(ctx context.Context, key string, expiresAt, now time.Time) (_ bool, err error) {
+	const fn = "database.pgsql.AddReplayKey"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	res, err := db.db.ExecContext(ctx, `
+		INSERT INTO replay_keys (key, expires_at) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE replay_keys.expires_at < $3
+	`, key, expiresAt, now)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return n == 1, nil
+}
@@ -0,0 +1,204 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RetentionLock - ключ advisory lock, который удерживает реплика, выполняющая очистку
+const RetentionLock = 0x6d65646f6473_02
+
+func (db *DB) PruneRefreshTokens(ctx context.Context, issuedBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneRefreshTokens"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		UPDATE users SET hashed_refresh_token = NULL, previous_refresh_hash = NULL
+		WHERE id IN (
+			SELECT id FROM users
+			WHERE hashed_refresh_token IS NOT NULL AND refresh_issued_at < $1
+			LIMIT $2
+		)
+	`
+
+	return db.execCount(ctx, fn, query, issuedBefore, limit)
+}
+
+func (db *DB) PruneSessions(ctx context.Context, expiredBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneSessions"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		DELETE FROM sessions
+		WHERE id IN (SELECT id FROM sessions WHERE expires_at < $1 LIMIT $2)
+	`
+
+	return db.execCount(ctx, fn, query, expiredBefore, limit)
+}
+
+func (db *DB) PruneDenylist(ctx context.Context, expiredBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneDenylist"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		DELETE FROM token_denylist
+		WHERE jti IN (SELECT jti FROM token_denylist WHERE expires_at < $1 LIMIT $2)
+	`
+
+	return db.execCount(ctx, fn, query, expiredBefore, limit)
+}
+
+// PruneRateLimits удаляет состояние давно не использованных ключей: к этому моменту
+// их запас всё равно восстановился полностью
+func (db *DB) PruneRateLimits(ctx context.Context, updatedBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneRateLimits"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		DELETE FROM rate_limits
+		WHERE key IN (SELECT key FROM rate_limits WHERE updated_at < $1 LIMIT $2)
+	`
+
+	return db.execCount(ctx, fn, query, updatedBefore, limit)
+}
+
+// PruneReplayKeys удаляет одноразовые идентификаторы, срок действия которых истёк:
+// повтор с ними и так отклоняется по времени
+func (db *DB) PruneReplayKeys(ctx context.Context, expiredBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneReplayKeys"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		DELETE FROM replay_keys
+		WHERE key IN (SELECT key FROM replay_keys WHERE expires_at < $1 LIMIT $2)
+	`
+
+	return db.execCount(ctx, fn, query, expiredBefore, limit)
+}
+
+// PruneRefreshLockouts удаляет счётчики неудачных попыток refresh, которые давно
+// не росли и не держат действующую блокировку
+func (db *DB) PruneRefreshLockouts(ctx context.Context, lastFailureBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneRefreshLockouts"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		DELETE FROM refresh_lockouts
+		WHERE (user_guid, scope, subject) IN (
+			SELECT user_guid, scope, subject FROM refresh_lockouts
+			WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < NOW())
+			LIMIT $2
+		)
+	`
+
+	return db.execCount(ctx, fn, query, lastFailureBefore, limit)
+}
+
+// PruneAuditEvents удаляет самые старые записи журнала по порядку id, чтобы оставшаяся
+// часть цепочки хешей не имела пропусков
+func (db *DB) PruneAuditEvents(ctx context.Context, createdBefore time.Time, limit int) (_ int64, err error) {
+	const fn = "database.pgsql.PruneAuditEvents"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	// Разрешает удаление в триггере audit_events_append_only только для этой транзакции
+	if _, err := tx.ExecContext(ctx, `SET LOCAL audit.retention = 'on'`); err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	query := `
+		DELETE FROM audit_events
+		WHERE id IN (SELECT id FROM audit_events WHERE created_at < $1 ORDER BY id LIMIT $2)
+	`
+
+	res, err := tx.ExecContext(ctx, query, createdBefore, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return n, nil
+}
+
+// TryLock берёт advisory lock на выделенном соединении: блокировка сессионная
+// и живёт, пока соединение не вернётся в пул после unlock. unlock снимает
+// блокировку и после отмены ctx
+func (db *DB) TryLock(ctx context.Context, key int64) (_ func(), _ bool, err error) {
+	const fn = "database.pgsql.TryLock"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("%s: %w", fn, err)
+	}
+	if !ok {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	unlockCtx := context.WithoutCancel(ctx)
+	unlock := func() {
+		if _, err := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			log.Error().Err(err).Int64("key", key).Msg("failed to release advisory lock")
+			// Соединение с неснятой блокировкой нельзя возвращать в пул: закрываем его,
+			// и PostgreSQL освобождает блокировку вместе с сессией
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+
+	return unlock, true, nil
+}
+
+func (db *DB) execCount(ctx context.Context, fn, query string, args ...any) (int64, error) {
+	res, err := db.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return n, nil
+}
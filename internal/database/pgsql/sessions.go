@@ -0,0 +1,168 @@
+package pgsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+const sessionColumns = `id, user_guid, ip, user_agent, created_at, expires_at, revoked_at`
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	session := &models.Session{}
+	err := row.Scan(&session.ID, &session.UserGUID, &session.IP, &session.UserAgent, &session.CreatedAt,
+		&session.ExpiresAt, &session.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *DB) GetSession(ctx context.Context, id string) (_ *models.Session, err error) {
+	const fn = "database.pgsql.GetSession"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	session, err := scanSession(db.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", fn, database.ErrSessionNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return session, nil
+}
+
+func (db *DB) ListSessions(ctx context.Context, guid string, activeOnly bool) (_ []models.Session, err error) {
+	const fn = "database.pgsql.ListSessions"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM sessions
+		WHERE user_guid = $1 AND (NOT $2 OR (revoked_at IS NULL AND expires_at > NOW()))
+		ORDER BY created_at DESC
+	`
+
+	rows, err := db.db.QueryContext(ctx, query, guid, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return sessions, nil
+}
+
+func (db *DB) RevokeSession(ctx context.Context, id, reason string) (_ string, err error) {
+	const fn = "database.pgsql.RevokeSession"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	var (
+		guid      string
+		createdAt time.Time
+		revokedAt *time.Time
+	)
+	err = tx.QueryRowContext(ctx, `SELECT user_guid, created_at, revoked_at FROM sessions WHERE id = $1 FOR UPDATE`, id).
+		Scan(&guid, &createdAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%s: %w", fn, database.ErrSessionNotFound)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+	if revokedAt != nil {
+		return guid, nil
+	}
+
+	query := `
+		INSERT INTO token_denylist (jti, user_guid, reason, expires_at)
+		SELECT id, user_guid, $2, expires_at
+		FROM sessions
+		WHERE id = $1 AND expires_at > NOW()
+		ON CONFLICT (jti) DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, query, id, reason); err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE id = $1`, id); err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	// У пользователя один refresh токен - он выдан вместе с последней сессией
+	query = `
+		UPDATE users SET hashed_refresh_token = NULL, previous_refresh_hash = NULL
+		WHERE user_guid = $1
+			AND NOT EXISTS (SELECT 1 FROM sessions WHERE user_guid = $1 AND created_at > $2)
+	`
+	if _, err := tx.ExecContext(ctx, query, guid, createdAt); err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return guid, nil
+}
+
+func (db *DB) RevokeUserSessions(ctx context.Context, guid, reason string) (err error) {
+	const fn = "database.pgsql.RevokeUserSessions"
+
+	ctx, span := startSpan(ctx, fn)
+	defer endSpan(span, &err)
+
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE user_guid = $1)`, guid).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+	if !exists {
+		return fmt.Errorf("%s: %w", fn, database.ErrUserNotFound)
+	}
+
+	if err := revokeUserSessions(ctx, tx, guid, reason); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package pgsql
+
+import (
+	"context"
+	"strings"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// startSpan открывает span операции с базой. Имя span - имя метода из fn,
+// например database.pgsql.GetUserByGUID
+func startSpan(ctx context.Context, fn string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, fn,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationName(strings.TrimPrefix(fn, "database.pgsql.")),
+		),
+	)
+}
+
+// endSpan закрывает span с ошибкой, которую вернул метод
+func endSpan(span trace.Span, err *error) {
+	tracing.End(span, *err)
+}
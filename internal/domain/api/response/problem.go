@@ -0,0 +1,132 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ProblemContentType - тип содержимого ответов об ошибках (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// ErrorCode - машиночитаемый код ошибки. Каждому коду соответствует ровно один HTTP статус
+type ErrorCode string
+
+const (
+	CodeInvalidRequest       ErrorCode = "invalid_request"
+	CodeInvalidGUID          ErrorCode = "invalid_guid"
+	CodeInvalidEmail         ErrorCode = "invalid_email"
+	CodeInvalidScope         ErrorCode = "invalid_scope"
+	CodeInvalidAudience      ErrorCode = "invalid_audience"
+	CodeInvalidDPoPProof     ErrorCode = "invalid_dpop_proof"
+	CodeRefreshTokenRequired ErrorCode = "refresh_token_required"
+	CodeInvalidRefreshToken  ErrorCode = "invalid_refresh_token"
+	CodeTokenReused          ErrorCode = "token_reused"
+	CodeUnauthorized         ErrorCode = "unauthorized"
+	CodeInvalidToken         ErrorCode = "invalid_token"
+	CodeInvalidClient        ErrorCode = "invalid_client"
+	CodeInsufficientScope    ErrorCode = "insufficient_scope"
+	CodeCSRFMismatch         ErrorCode = "csrf_mismatch"
+	CodeUserInactive         ErrorCode = "user_inactive"
+	CodeUserNotFound         ErrorCode = "user_not_found"
+	CodeClientNotFound       ErrorCode = "client_not_found"
+	CodeUserExists           ErrorCode = "user_exists"
+	CodeRequestTooLarge      ErrorCode = "request_too_large"
+	CodeRateLimited          ErrorCode = "rate_limited"
+	CodeIPBlocked            ErrorCode = "ip_blocked"
+	CodeRefreshLocked        ErrorCode = "refresh_locked"
+	CodeInternal             ErrorCode = "internal_error"
+)
+
+type codeInfo struct {
+	status int
+	title  string
+}
+
+var codes = map[ErrorCode]codeInfo{
+	CodeInvalidRequest:       {http.StatusBadRequest, "Invalid request"},
+	CodeInvalidGUID:          {http.StatusBadRequest, "Invalid user GUID"},
+	CodeInvalidEmail:         {http.StatusBadRequest, "Invalid email"},
+	CodeInvalidScope:         {http.StatusBadRequest, "Scope is not allowed"},
+	CodeInvalidAudience:      {http.StatusBadRequest, "Audience is not allowed"},
+	CodeInvalidDPoPProof:     {http.StatusBadRequest, "Invalid DPoP proof"},
+	CodeRefreshTokenRequired: {http.StatusBadRequest, "Refresh token is required"},
+	CodeInvalidRefreshToken:  {http.StatusUnauthorized, "Invalid refresh token"},
+	CodeTokenReused:          {http.StatusUnauthorized, "Refresh token has already been used"},
+	CodeUnauthorized:         {http.StatusUnauthorized, "Authentication required"},
+	CodeInvalidToken:         {http.StatusUnauthorized, "Invalid access token"},
+	CodeInvalidClient:        {http.StatusUnauthorized, "Client authentication failed"},
+	CodeInsufficientScope:    {http.StatusForbidden, "Access token lacks the required scope"},
+	CodeCSRFMismatch:         {http.StatusForbidden, "CSRF token does not match"},
+	CodeUserInactive:         {http.StatusForbidden, "User is locked or disabled"},
+	CodeUserNotFound:         {http.StatusNotFound, "User not found"},
+	CodeClientNotFound:       {http.StatusNotFound, "Client not found"},
+	CodeUserExists:           {http.StatusConflict, "User already exists"},
+	CodeRequestTooLarge:      {http.StatusRequestEntityTooLarge, "Request body is too large"},
+	CodeRateLimited:          {http.StatusTooManyRequests, "Too many requests"},
+	CodeIPBlocked:            {http.StatusTooManyRequests, "Too many requests from this IP address"},
+	CodeRefreshLocked:        {http.StatusTooManyRequests, "Refresh is temporarily locked"},
+	CodeInternal:             {http.StatusInternalServerError, "Internal server error"},
+}
+
+// Status возвращает HTTP статус кода; неизвестные коды считаются внутренней ошибкой
+func (c ErrorCode) Status() int {
+	if info, ok := codes[c]; ok {
+		return info.status
+	}
+	return http.StatusInternalServerError
+}
+
+func (c ErrorCode) Title() string {
+	if info, ok := codes[c]; ok {
+		return info.title
+	}
+	return codes[CodeInternal].title
+}
+
+// Problem - тело ответа об ошибке в формате application/problem+json
+type Problem struct {
+	Type     string    `json:"type"`
+	Title    string    `json:"title"`
+	Status   int       `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Instance string    `json:"instance,omitempty"`
+	Code     ErrorCode `json:"code"`
+}
+
+// Error - типизированная ошибка API. Detail попадает в ответ клиенту,
+// Err остаётся во внутренних логах
+type Error struct {
+	Code   ErrorCode
+	Detail string
+	Err    error
+	// RetryAfter - через сколько повторить запрос; попадает в заголовок Retry-After
+	RetryAfter time.Duration
+}
+
+func NewError(code ErrorCode, detail string) *Error {
+	return &Error{Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem формирует тело ответа; instance - путь запроса, вызвавшего ошибку
+func (e *Error) Problem(instance string) Problem {
+	return Problem{
+		Type:     "urn:auth-medods:problem:" + string(e.Code),
+		Title:    e.Code.Title(),
+		Status:   e.Code.Status(),
+		Detail:   e.Detail,
+		Instance: instance,
+		Code:     e.Code,
+	}
+}
@@ -1,11 +1,198 @@
 package response
 
+import "time"
+
 type UserResponse struct {
-	AccessToken  string `json:"access_token"`
-	GetRefreshToken string `json:"refresh_token"`
+	AccessToken     string `json:"access_token"`
+	GetRefreshToken string `json:"refresh_token,omitempty"`
+	IDToken         string `json:"id_token,omitempty"`
 }
 
+// RefreshToken - тело запроса /refresh. Scope и Audience сужают выданные ранее
+// области доступа и получателей; пустые сохраняют их
 type RefreshToken struct {
 	RefreshToken string `json:"refresh_token"`
 	GUID         string `json:"guid"`
-}
\ No newline at end of file
+	Scope        string `json:"scope,omitempty"`
+	Audience     string `json:"audience,omitempty"`
+}
+
+// CreateUser - тело запроса на создание пользователя; пустой GUID генерируется сервисом
+type CreateUser struct {
+	GUID  string `json:"guid"`
+	Email string `json:"email"`
+}
+
+// UpdateUser - тело запроса на изменение пользователя; обновляются только переданные поля
+type UpdateUser struct {
+	Email *string `json:"email"`
+}
+
+// UserStatusChange - тело запроса на блокировку или отключение пользователя.
+// Until задаёт срок временной блокировки; без него блокировка бессрочная
+type UserStatusChange struct {
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+type User struct {
+	GUID         string     `json:"guid"`
+	Email        string     `json:"email"`
+	IP           string     `json:"ip,omitempty"`
+	Status       string     `json:"status"`
+	StatusReason string     `json:"status_reason,omitempty"`
+	StatusUntil  *time.Time `json:"status_until,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Introspection - результат проверки access токена
+type Introspection struct {
+	Active    bool          `json:"active"`
+	JTI       string        `json:"jti,omitempty"`
+	Subject   string        `json:"sub,omitempty"`
+	GUID      string        `json:"guid,omitempty"`
+	ClientID  string        `json:"client_id,omitempty"`
+	Scope     string        `json:"scope,omitempty"`
+	Audience  []string      `json:"aud,omitempty"`
+	Cnf       *Confirmation `json:"cnf,omitempty"`
+	IssuedAt  int64         `json:"iat,omitempty"`
+	ExpiresAt int64         `json:"exp,omitempty"`
+}
+
+// Confirmation - ключ, к которому привязан токен (RFC 7800); JKT - отпечаток ключа DPoP
+type Confirmation struct {
+	JKT string `json:"jkt"`
+}
+
+type UserList struct {
+	Users  []User `json:"users"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+type AuditEvent struct {
+	ID        int64     `json:"id"`
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	GUID      string    `json:"guid,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Outcome   string    `json:"outcome"`
+	Details   string    `json:"details,omitempty"`
+}
+
+// AuditEvents - страница журнала аудита; NextOffset задан, если есть следующая страница
+type AuditEvents struct {
+	Events     []AuditEvent `json:"events"`
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+	NextOffset *int         `json:"next_offset,omitempty"`
+}
+
+// RefreshLockout - счётчик неудачных попыток refresh в одной области
+type RefreshLockout struct {
+	Scope         string     `json:"scope"`
+	Session       string     `json:"session,omitempty"`
+	Failures      int        `json:"failures"`
+	Lockouts      int        `json:"lockouts"`
+	Locked        bool       `json:"locked"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+}
+
+// RefreshLockouts - состояние защиты от подбора refresh токена пользователя
+type RefreshLockouts struct {
+	GUID     string           `json:"guid"`
+	Locked   bool             `json:"locked"`
+	Lockouts []RefreshLockout `json:"lockouts"`
+}
+
+// CreateClient - запрос на регистрацию клиента. Секрет выдаётся, если не указано no_secret
+type CreateClient struct {
+	Name             string   `json:"name"`
+	CertThumbprint   string   `json:"cert_thumbprint"`
+	PublicKey        string   `json:"public_key"`
+	NoSecret         bool     `json:"no_secret"`
+	AllowedScopes    []string `json:"allowed_scopes"`
+	AllowedAudiences []string `json:"allowed_audiences"`
+}
+
+// UpdateClient - изменение клиента; пустая строка удаляет отпечаток или ключ
+type UpdateClient struct {
+	Name             *string   `json:"name"`
+	CertThumbprint   *string   `json:"cert_thumbprint"`
+	PublicKey        *string   `json:"public_key"`
+	AllowedScopes    *[]string `json:"allowed_scopes"`
+	AllowedAudiences *[]string `json:"allowed_audiences"`
+}
+
+// Client - клиент в ответах административного API. ClientSecret заполняется
+// только при создании и смене секрета
+type Client struct {
+	ClientID         string    `json:"client_id"`
+	ClientSecret     string    `json:"client_secret,omitempty"`
+	Name             string    `json:"name"`
+	HasSecret        bool      `json:"has_secret"`
+	CertThumbprint   string    `json:"cert_thumbprint,omitempty"`
+	PublicKey        string    `json:"public_key,omitempty"`
+	AllowedScopes    []string  `json:"allowed_scopes"`
+	AllowedAudiences []string  `json:"allowed_audiences"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type ClientList struct {
+	Clients []Client `json:"clients"`
+}
+
+// TokenResponse - успешный ответ /oauth/token (RFC 6749, раздел 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OAuthError - ошибка /oauth/token (RFC 6749, раздел 5.2)
+type OAuthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// OpenIDConfiguration - метаданные провайдера OpenID Connect (OpenID Connect Discovery 1.0)
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// JWK - открытый RSA ключ подписи ID токенов (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// UserInfo - ответ /userinfo
+type UserInfo struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+}
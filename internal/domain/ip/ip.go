@@ -1,11 +1,127 @@
 package ip
 
-import "net/http"
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
 
-func GetIp(req *http.Request) string {
-	ip := req.Header.Get("X-FORWARDED-FOR")
-	if ip != "" {
-		return ip
+// Resolver определяет адрес клиента. X-Forwarded-For учитывается, только если
+// соединение пришло от доверенного прокси. Нулевой *Resolver не доверяет никому
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+func NewResolver(trusted []netip.Prefix) *Resolver {
+	return &Resolver{trusted: trusted}
+}
+
+// ParsePrefixes разбирает список подсетей CIDR; одиночный адрес считается подсетью из одного адреса
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("ip.ParsePrefixes: invalid address %q", v)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("ip.ParsePrefixes: invalid CIDR %q", v)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Trusted сообщает, пришёл ли запрос напрямую от доверенного прокси
+func (res *Resolver) Trusted(r *http.Request) bool {
+	addr, ok := parse(r.RemoteAddr)
+	return ok && res.trusts(addr)
+}
+
+// ClientIP возвращает адрес клиента без порта. X-Forwarded-For просматривается
+// справа налево от доверенного прокси; первый недоверенный адрес и есть клиент.
+// Левые элементы заголовка задаёт сам клиент, поэтому им не верим
+func (res *Resolver) ClientIP(r *http.Request) string {
+	remote, ok := parse(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !res.trusts(remote) {
+		return remote.String()
+	}
+
+	client := remote
+	hops := forwardedFor(r)
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parse(hops[i])
+		if !ok {
+			break
+		}
+		client = addr
+		if !res.trusts(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+func (res *Resolver) trusts(addr netip.Addr) bool {
+	if res == nil {
+		return false
+	}
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor собирает элементы всех заголовков X-Forwarded-For по порядку
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parse разбирает адрес с портом или без него
+func parse(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+type clientKey struct{}
+
+// WithClient сохраняет в контексте адрес клиента, определённый Resolver
+func WithClient(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, clientKey{}, addr)
+}
+
+// FromRequest возвращает адрес клиента, определённый для запроса middleware
+// с Resolver. Без него - адрес соединения без порта: X-Forwarded-For не учитывается
+func FromRequest(req *http.Request) string {
+	if addr, ok := req.Context().Value(clientKey{}).(string); ok {
+		return addr
 	}
-	return req.RemoteAddr
-}
\ No newline at end of file
+	return (*Resolver)(nil).ClientIP(req)
+}
@@ -0,0 +1,46 @@
+package jwt
+
+import (
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Decoded - заголовок и payload токена, разобранные без проверки подписи.
+// Числа остаются json.Number, чтобы время не печаталось в экспоненциальной записи
+type Decoded struct {
+	Header map[string]any
+	Claims map[string]any
+}
+
+// Decode разбирает токен, не проверяя подпись и срок действия. Содержимому можно
+// доверять, только если SigningKey нашёл ключ, которым токен подписан
+func Decode(tokenString string) (*Decoded, error) {
+	const fn = "domain.jwt.Decode"
+
+	token, _, err := jwt.NewParser(jwt.WithJSONNumber()).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return &Decoded{Header: token.Header, Claims: token.Claims.(jwt.MapClaims)}, nil
+}
+
+// SigningKey возвращает номер ключа из keys, подпись которым сходится, или -1.
+// Срок действия не проверяется, чтобы ключ определялся и у истёкшего токена.
+// Пустые ключи пропускаются
+func SigningKey(tokenString string, keys ...string) int {
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation(), jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}))
+	for i, key := range keys {
+		if key == "" {
+			continue
+		}
+		_, err := parser.Parse(tokenString, func(*jwt.Token) (any, error) {
+			return []byte(key), nil
+		})
+		if err == nil {
+			return i
+		}
+	}
+	return -1
+}
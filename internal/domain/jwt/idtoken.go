@@ -0,0 +1,112 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// IDTokenClaims - данные ID токена OpenID Connect. Пустые Nonce и AuthTime
+// в токен не попадают
+type IDTokenClaims struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	Email    string
+	Nonce    string
+	AuthTime *time.Time
+	TTL      time.Duration
+}
+
+// IDTokenSigner подписывает ID токены по RS256. В отличие от access токенов их
+// проверяют сами клиенты, поэтому нужен ключ, открытую часть которого можно опубликовать
+type IDTokenSigner struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+func NewIDTokenSigner(key *rsa.PrivateKey) (*IDTokenSigner, error) {
+	const fn = "domain.jwt.NewIDTokenSigner"
+
+	if key == nil {
+		return nil, fmt.Errorf("%s: key is required", fn)
+	}
+	if key.N.BitLen() < 2048 {
+		return nil, fmt.Errorf("%s: RSA key must be at least 2048 bits", fn)
+	}
+
+	keyID, err := thumbprint(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return &IDTokenSigner{key: key, keyID: keyID}, nil
+}
+
+// KeyID - идентификатор ключа (kid), отпечаток открытого ключа по RFC 7638
+func (s *IDTokenSigner) KeyID() string {
+	return s.keyID
+}
+
+func (s *IDTokenSigner) PublicKey() *rsa.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *IDTokenSigner) Sign(c IDTokenClaims) (string, error) {
+	const fn = "domain.jwt.IDTokenSigner.Sign"
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": c.Issuer,
+		"sub": c.Subject,
+		"aud": c.Audience,
+		"iat": now.Unix(),
+		"exp": now.Add(c.TTL).Unix(),
+	}
+	if c.Email != "" {
+		claims["email"] = c.Email
+	}
+	if c.Nonce != "" {
+		claims["nonce"] = c.Nonce
+	}
+	if c.AuthTime != nil {
+		claims["auth_time"] = c.AuthTime.Unix()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.keyID
+
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+	return signed, nil
+}
+
+// EncodeRSAPublicKey возвращает модуль и экспоненту ключа в base64url, как в JWK
+func EncodeRSAPublicKey(key *rsa.PublicKey) (n, e string) {
+	n = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	e = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	return n, e
+}
+
+// thumbprint вычисляет отпечаток RSA ключа по RFC 7638: SHA-256 от JWK
+// с обязательными полями в лексикографическом порядке
+func thumbprint(key *rsa.PublicKey) (string, error) {
+	n, e := EncodeRSAPublicKey(key)
+
+	// json.Marshal сортирует ключи map, что и требует RFC 7638
+	canonical, err := json.Marshal(map[string]string{"e": e, "kty": "RSA", "n": n})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -1,34 +1,267 @@
 package jwt
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/metrics"
+	"github.com/volchok96/auth-medods/internal/tracing"
 )
 
-func NewTokens(guid, ownKey string, ip string, expHours int) (string, string, string, error) {
+// Params - данные, которые попадают в payload access токена.
+// ClientID - клиент, запросивший токены; пусто для клиентов без аутентификации.
+// Scope и Audience ограничивают токен; пустые в payload не попадают.
+// JKT - отпечаток ключа DPoP, к которому привязан токен (claim cnf.jkt)
+type Params struct {
+	GUID     string
+	IP       string
+	ClientID string
+	Scope    []string
+	Audience []string
+	JKT      string
+	TTL      time.Duration
+}
+
+// Tokens - выданная пара токенов. ID - jti access токена, он же идентификатор сессии
+type Tokens struct {
+	Access      string
+	Refresh     string
+	RefreshHash string
+	ID          string
+	ExpiresAt   time.Time
+}
+
+// Claims - проверенный payload access токена. У токена клиента (client_credentials)
+// GUID и IP пусты, а Subject совпадает с ClientID
+type Claims struct {
+	ID        string
+	Subject   string
+	GUID      string
+	IP        string
+	ClientID  string
+	Scope     []string
+	Audience  []string
+	JKT       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// refreshSeparator отделяет GUID пользователя от случайной части refresh токена
+const refreshSeparator = ":"
+
+// NewTokens выдаёт пару токенов. Хеширование refresh токена bcrypt - самая
+// долгая часть - записывается в трассу отдельным span
+func NewTokens(ctx context.Context, ownKey string, p Params) (_ *Tokens, err error) {
 	const fn = "domain.jwt.NewTokens"
 
+	ctx, span := tracing.Start(ctx, fn)
+	defer func() { tracing.End(span, err) }()
+
+	now := time.Now()
+	tokens := &Tokens{
+		ID:        uuid.New().String(),
+		ExpiresAt: now.Add(p.TTL),
+	}
+
 	token := jwt.New(jwt.SigningMethodHS512)
 	claims := token.Claims.(jwt.MapClaims)
 
-	claims["guid"] = guid
-	claims["ip"] = ip
-	claims["exp"] = time.Now().Add(time.Duration(expHours) * time.Hour).Unix()
+	claims["jti"] = tokens.ID
+	claims["sub"] = p.GUID
+	claims["guid"] = p.GUID
+	claims["ip"] = p.IP
+	claims["iat"] = now.Unix()
+	claims["exp"] = tokens.ExpiresAt.Unix()
+	if p.ClientID != "" {
+		claims["client_id"] = p.ClientID
+	}
+	setGrantClaims(claims, p)
+
+	tokenString, err := token.SignedString([]byte(ownKey))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	tokens.Access = tokenString
+
+	// GUID в начале refresh токена позволяет найти пользователя по одному токену
+	// (grant_type=refresh_token). 69 байт укладываются в ограничение bcrypt в 72 байта
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	tokens.Refresh = p.GUID + refreshSeparator + hex.EncodeToString(random)
+
+	_, hashSpan := tracing.Start(ctx, "bcrypt.GenerateFromPassword")
+	start := time.Now()
+	hashedRefreshToken, err := bcrypt.GenerateFromPassword([]byte(tokens.Refresh), bcrypt.DefaultCost)
+	metrics.BcryptDuration.WithLabelValues("hash").Observe(time.Since(start).Seconds())
+	tracing.End(hashSpan, err)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	tokens.RefreshHash = string(hashedRefreshToken)
+
+	return tokens, nil
+}
+
+// NewClientToken выдаёт access токен самому клиенту p.ClientID (grant_type=client_credentials).
+// GUID и IP не используются; refresh токен и сессия для него не создаются
+func NewClientToken(ctx context.Context, ownKey string, p Params) (_ *Tokens, err error) {
+	const fn = "domain.jwt.NewClientToken"
+
+	_, span := tracing.Start(ctx, fn)
+	defer func() { tracing.End(span, err) }()
+
+	now := time.Now()
+	tokens := &Tokens{
+		ID:        uuid.New().String(),
+		ExpiresAt: now.Add(p.TTL),
+	}
+
+	claims := jwt.MapClaims{
+		"jti":       tokens.ID,
+		"sub":       p.ClientID,
+		"client_id": p.ClientID,
+		"iat":       now.Unix(),
+		"exp":       tokens.ExpiresAt.Unix(),
+	}
+	setGrantClaims(claims, p)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 
 	tokenString, err := token.SignedString([]byte(ownKey))
 	if err != nil {
-		return "", "", "", fmt.Errorf("%s: %w", fn, err)
+		return nil, fmt.Errorf("%s: %w", fn, err)
 	}
+	tokens.Access = tokenString
 
-	refreshToken := uuid.New().String()
-	hashedRefreshToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	return tokens, nil
+}
+
+// setGrantClaims записывает области доступа (scope, через пробел), получателей
+// токена (aud: строка для одного получателя, иначе массив) и ключ DPoP (cnf)
+func setGrantClaims(claims jwt.MapClaims, p Params) {
+	if len(p.Scope) > 0 {
+		claims["scope"] = strings.Join(p.Scope, " ")
+	}
+	switch len(p.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = p.Audience[0]
+	default:
+		claims["aud"] = p.Audience
+	}
+	if p.JKT != "" {
+		claims["cnf"] = map[string]string{"jkt": p.JKT}
+	}
+}
+
+// DecodeRefreshToken принимает refresh токен в любой из форм, которые выдаёт сервис:
+// как есть (/oauth/token) или в base64 (/access и /refresh)
+func DecodeRefreshToken(presented string) ([]byte, bool) {
+	if _, ok := RefreshTokenGUID(presented); ok {
+		return []byte(presented), true
+	}
+	decoded, err := base64.StdEncoding.DecodeString(presented)
+	if err != nil || len(decoded) == 0 {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// RefreshTokenGUID возвращает GUID пользователя из refresh токена. Токены,
+// выданные до появления префикса, GUID не содержат
+func RefreshTokenGUID(refresh string) (string, bool) {
+	guid, _, ok := strings.Cut(refresh, refreshSeparator)
+	if !ok {
+		return "", false
+	}
+	if _, err := uuid.Parse(guid); err != nil {
+		return "", false
+	}
+	return guid, true
+}
+
+// ParseAccessToken проверяет подпись и срок действия access токена. Подпись,
+// не сошедшаяся с ownKey, проверяется предыдущими ключами: после смены ключа
+// выданные им токены действуют до истечения срока
+func ParseAccessToken(tokenString, ownKey string, previousKeys ...string) (*Claims, error) {
+	const fn = "domain.jwt.ParseAccessToken"
+
+	token, err := parseSigned(tokenString, ownKey)
+	for _, key := range previousKeys {
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
+		}
+		// Пустой ключ подошёл бы к токену, подписанному пустым ключом
+		if key == "" {
+			continue
+		}
+		token, err = parseSigned(tokenString, key)
+	}
 	if err != nil {
-		return "", "", "", fmt.Errorf("%s: %w", fn, err)
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	mc, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", fn, errors.New("unexpected claims type"))
+	}
+
+	claims := &Claims{}
+	claims.ID, _ = mc["jti"].(string)
+	claims.Subject, _ = mc["sub"].(string)
+	claims.GUID, _ = mc["guid"].(string)
+	claims.IP, _ = mc["ip"].(string)
+	claims.ClientID, _ = mc["client_id"].(string)
+	if scope, ok := mc["scope"].(string); ok {
+		claims.Scope = strings.Fields(scope)
+	}
+	if cnf, ok := mc["cnf"].(map[string]any); ok {
+		claims.JKT, _ = cnf["jkt"].(string)
+	}
+	switch aud := mc["aud"].(type) {
+	case string:
+		claims.Audience = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				claims.Audience = append(claims.Audience, s)
+			}
+		}
 	}
+	if iat, ok := mc["iat"].(float64); ok {
+		claims.IssuedAt = time.Unix(int64(iat), 0)
+	}
+	if exp, ok := mc["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if claims.Subject == "" {
+		claims.Subject = claims.GUID
+	}
+	if claims.ID == "" || claims.Subject == "" {
+		return nil, fmt.Errorf("%s: %w", fn, errors.New("token has no jti or subject"))
+	}
+
+	return claims, nil
+}
 
-	return tokenString, refreshToken, string(hashedRefreshToken), nil
+func parseSigned(tokenString, key string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(t *jwt.Token) (any, error) {
+		if t.Method != jwt.SigningMethodHS512 {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+		}
+		return []byte(key), nil
+	})
 }
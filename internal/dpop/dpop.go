@@ -0,0 +1,275 @@
+package dpop
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/replay"
+)
+
+// Header - заголовок, в котором клиент передаёт DPoP proof (RFC 9449)
+const Header = "DPoP"
+
+// proofType - обязательный typ в заголовке proof
+const proofType = "dpop+jwt"
+
+var (
+	// ErrNoProof - запрос без заголовка DPoP
+	ErrNoProof = errors.New("dpop proof is missing")
+	// ErrInvalidProof - proof повреждён, просрочен, повторён или не подходит к запросу
+	ErrInvalidProof = errors.New("invalid dpop proof")
+)
+
+// proofMethods - асимметричные алгоритмы подписи proof; симметричные и none запрещены
+var proofMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Config - настройки проверки proof. BaseURL - внешний адрес сервиса, с которым
+// сравнивается htu; пустой адрес собирается из запроса
+type Config struct {
+	BaseURL string
+	// MaxAge - сколько после iat proof принимается; по умолчанию минута
+	MaxAge time.Duration
+	// Leeway - допустимое расхождение часов клиента и сервиса; по умолчанию 5 секунд
+	Leeway time.Duration
+	// Replay - где помнить использованные jti; по умолчанию в памяти процесса,
+	// и тогда повтор proof на другую реплику не обнаруживается
+	Replay database.ReplayStore
+}
+
+// Verifier проверяет DPoP proof: подпись ключом из заголовка jwk, метод и адрес
+// запроса, свежесть iat и одноразовость jti
+type Verifier struct {
+	baseURL string
+	maxAge  time.Duration
+	leeway  time.Duration
+	replay  database.ReplayStore
+	now     func() time.Time
+}
+
+func NewVerifier(cfg Config) *Verifier {
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = time.Minute
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = 5 * time.Second
+	}
+	if cfg.Replay == nil {
+		cfg.Replay = replay.NewCache()
+	}
+
+	return &Verifier{
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		maxAge:  cfg.MaxAge,
+		leeway:  cfg.Leeway,
+		replay:  cfg.Replay,
+		now:     time.Now,
+	}
+}
+
+// Verify проверяет proof из заголовка DPoP и возвращает отпечаток ключа (jkt) по RFC 7638.
+// Для запроса с access токеном proof должен содержать его хеш ath
+func (v *Verifier) Verify(r *http.Request, accessToken string) (string, error) {
+	const fn = "dpop.Verify"
+
+	values := r.Header.Values(Header)
+	if len(values) == 0 {
+		return "", ErrNoProof
+	}
+	if len(values) > 1 {
+		return "", fmt.Errorf("%s: several proofs: %w", fn, ErrInvalidProof)
+	}
+
+	var key crypto.PublicKey
+	var jkt string
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(values[0], claims, func(t *jwt.Token) (any, error) {
+		if typ, _ := t.Header["typ"].(string); typ != proofType {
+			return nil, errors.New("typ must be " + proofType)
+		}
+
+		jwk, ok := t.Header["jwk"].(map[string]any)
+		if !ok {
+			return nil, errors.New("jwk header is required")
+		}
+
+		var err error
+		key, jkt, err = parseJWK(jwk)
+		return key, err
+	}, jwt.WithValidMethods(proofMethods))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v: %w", fn, err, ErrInvalidProof)
+	}
+
+	if htm, _ := claims["htm"].(string); htm != r.Method {
+		return "", fmt.Errorf("%s: htm does not match the request: %w", fn, ErrInvalidProof)
+	}
+	if htu, _ := claims["htu"].(string); !sameURL(htu, v.requestURL(r)) {
+		return "", fmt.Errorf("%s: htu does not match the request: %w", fn, ErrInvalidProof)
+	}
+
+	now := v.now()
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return "", fmt.Errorf("%s: iat is required: %w", fn, ErrInvalidProof)
+	}
+	issuedAt := time.Unix(int64(iat), 0)
+	if issuedAt.After(now.Add(v.leeway)) || issuedAt.Before(now.Add(-v.maxAge)) {
+		return "", fmt.Errorf("%s: proof is not fresh: %w", fn, ErrInvalidProof)
+	}
+
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		if ath, _ := claims["ath"].(string); ath != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", fmt.Errorf("%s: ath does not match the access token: %w", fn, ErrInvalidProof)
+		}
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return "", fmt.Errorf("%s: jti is required: %w", fn, ErrInvalidProof)
+	}
+	added, err := v.replay.AddReplayKey(r.Context(), jkt+":"+jti, issuedAt.Add(v.maxAge+v.leeway), now)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", fn, err)
+	}
+	if !added {
+		return "", fmt.Errorf("%s: proof replayed: %w", fn, ErrInvalidProof)
+	}
+
+	return jkt, nil
+}
+
+// requestURL - адрес запроса без строки запроса и фрагмента, как в htu
+func (v *Verifier) requestURL(r *http.Request) string {
+	if v.baseURL != "" {
+		return v.baseURL + r.URL.Path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// sameURL сравнивает htu с адресом запроса без учёта строки запроса и регистра схемы и хоста
+func sameURL(htu, expected string) bool {
+	if i := strings.IndexAny(htu, "?#"); i >= 0 {
+		htu = htu[:i]
+	}
+
+	htuScheme, htuRest, ok := strings.Cut(htu, "://")
+	if !ok {
+		return false
+	}
+	scheme, rest, _ := strings.Cut(expected, "://")
+	htuHost, htuPath, _ := strings.Cut(htuRest, "/")
+	host, path, _ := strings.Cut(rest, "/")
+
+	return strings.EqualFold(htuScheme, scheme) && strings.EqualFold(htuHost, host) && htuPath == path
+}
+
+// parseJWK разбирает открытый ключ из заголовка proof и вычисляет его отпечаток.
+// Ключ с закрытой частью отклоняется
+func parseJWK(jwk map[string]any) (crypto.PublicKey, string, error) {
+	field := func(name string) string {
+		s, _ := jwk[name].(string)
+		return s
+	}
+	if field("d") != "" {
+		return nil, "", errors.New("jwk must not contain a private key")
+	}
+
+	var (
+		key       crypto.PublicKey
+		canonical map[string]string
+	)
+	switch kty := field("kty"); kty {
+	case "RSA":
+		n, err := decodeBigInt(field("n"))
+		if err != nil {
+			return nil, "", err
+		}
+		e, err := decodeBigInt(field("e"))
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, "", errors.New("invalid RSA exponent")
+		}
+		if n.BitLen() < 2048 {
+			return nil, "", errors.New("RSA key must be at least 2048 bits")
+		}
+		key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		canonical = map[string]string{"e": field("e"), "kty": kty, "n": field("n")}
+
+	case "EC":
+		var curve elliptic.Curve
+		switch field("crv") {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, "", errors.New("unsupported curve")
+		}
+		x, err := decodeBigInt(field("x"))
+		if err != nil {
+			return nil, "", err
+		}
+		y, err := decodeBigInt(field("y"))
+		if err != nil {
+			return nil, "", err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, "", errors.New("point is not on the curve")
+		}
+		key = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		canonical = map[string]string{"crv": field("crv"), "kty": kty, "x": field("x"), "y": field("y")}
+
+	case "OKP":
+		if field("crv") != "Ed25519" {
+			return nil, "", errors.New("unsupported curve")
+		}
+		x, err := base64.RawURLEncoding.DecodeString(field("x"))
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, "", errors.New("invalid Ed25519 key")
+		}
+		key = ed25519.PublicKey(x)
+		canonical = map[string]string{"crv": field("crv"), "kty": kty, "x": field("x")}
+
+	default:
+		return nil, "", errors.New("unsupported key type")
+	}
+
+	// json.Marshal сортирует ключи map, как требует RFC 7638
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(data)
+
+	return key, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -6,83 +6,126 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/rs/zerolog/log"
-
+	"github.com/volchok96/auth-medods/internal/audit"
 	"github.com/volchok96/auth-medods/internal/database"
-	"github.com/volchok96/auth-medods/internal/database/models"
 	"github.com/volchok96/auth-medods/internal/domain/api/response"
 	"github.com/volchok96/auth-medods/internal/domain/ip"
-	"github.com/volchok96/auth-medods/internal/domain/jwt"
 )
 
 // AccessHandler - хендлер для получения токенов доступа
-func AccessHandler(db database.DBInterface, ownKey string, tokenTTL time.Duration) http.HandlerFunc {
+func AccessHandler(db database.DBInterface, ownKey string, tokenTTL time.Duration, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+	issuer := &tokenIssuer{db: db, ownKey: ownKey, tokenTTL: tokenTTL, o: o}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		guid := r.URL.Query().Get("guid")
-		if guid == "" {
-			log.Error().Msg("no guid provided")
-			http.Error(w, "user_id is required", http.StatusBadRequest)
+
+		// Клиент проверяется раньше GUID, чтобы анонимный запрос не узнал, есть ли пользователь
+		client, apiErr := o.authenticateClient(r)
+		if apiErr != nil {
+			o.audit.Record(r, audit.Event{
+				Type:     audit.EventTokenIssued,
+				Actor:    audit.ActorClient,
+				UserGUID: guid,
+				Outcome:  audit.OutcomeFailure,
+				Details:  "client authentication failed",
+			})
+			writeClientError(w, r, apiErr)
 			return
 		}
 
-		if _, err := uuid.Parse(guid); err != nil {
-			log.Error().Err(err).Msg("invalid guid")
-			http.Error(w, "invalid guid", http.StatusBadRequest)
-			return
+		actor, clientID := audit.ActorClient, ""
+		if client != nil {
+			actor, clientID = audit.ActorClientID(client.ClientID), client.ClientID
 		}
 
-		clientIP := ip.GetIp(r)
-		if clientIP == "" {
-			log.Error().Msg("failed to get IP")
-			http.Error(w, "failed to get IP", http.StatusInternalServerError)
-			return
+		fail := func(details string) {
+			o.audit.Record(r, audit.Event{
+				Type:     audit.EventTokenIssued,
+				Actor:    actor,
+				UserGUID: guid,
+				Outcome:  audit.OutcomeFailure,
+				Details:  details,
+			})
 		}
 
-		token, refreshToken, hashedRefreshToken, err := jwt.NewTokens(guid, ownKey, clientIP, int(tokenTTL.Hours()))
-		if err != nil {
-			log.Error().Err(err).Msg("failed to generate access token")
-			http.Error(w, "failed to generate tokens", http.StatusBadRequest)
+		// Области доступа и получатели токена; scope=openid добавляет в ответ ID токен
+		scope := newScopeRequest(client, r.URL.Query().Get("scope"), r.URL.Query().Get("audience"))
+		if scope.jkt, apiErr = o.dpopKey(r); apiErr != nil {
+			fail("invalid DPoP proof")
+			writeProblem(w, r, apiErr)
 			return
 		}
 
-		GUID, err := uuid.Parse(guid)
-		if err != nil {
-			log.Error().Err(err).Msg("failed to parse guid")
-			http.Error(w, "failed to parse guid", http.StatusInternalServerError)
+		g, apiErr := o.grantFor(scope)
+		if apiErr != nil {
+			fail("requested grant is not allowed")
+			writeProblem(w, r, apiErr)
 			return
 		}
 
-		user := &models.User{
-			UserGUID:           GUID,
-			IP:                 clientIP,
-			HashedRefreshToken: hashedRefreshToken,
+		clientIP := ip.FromRequest(r)
+		if clientIP == "" {
+			requestLog(r).Error().Msg("failed to get IP")
+			writeError(w, r, response.CodeInternal, "failed to get IP")
+			return
 		}
 
-		if err := db.UpdateUser(user); err != nil {
-			log.Error().Err(err).Msg("failed to save hash")
-			http.Error(w, "failed to save data", http.StatusInternalServerError)
+		user, tokens, apiErr := issuer.issueForGUID(r, guid, clientIP, clientID, g, fail)
+		if apiErr != nil {
+			writeProblem(w, r, apiErr)
 			return
 		}
 
-		refreshBase64 := base64.StdEncoding.EncodeToString([]byte(refreshToken))
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventTokenIssued,
+			Actor:    actor,
+			UserGUID: guid,
+			Outcome:  audit.OutcomeSuccess,
+			Details:  "session " + tokens.ID,
+		})
+
+		var idToken string
+		if g.openID() {
+			if idToken, apiErr = issuer.idToken(r, user, clientID, r.URL.Query().Get("nonce")); apiErr != nil {
+				writeProblem(w, r, apiErr)
+				return
+			}
+		}
+
+		refreshBase64 := base64.StdEncoding.EncodeToString([]byte(tokens.Refresh))
 
 		response := response.UserResponse{
-			AccessToken:     token,
+			AccessToken:     tokens.Access,
 			GetRefreshToken: refreshBase64,
+			IDToken:         idToken,
+		}
+
+		// В cookie refresh токен недоступен JavaScript и не попадает в тело
+		if o.cookieDelivery(r, false) {
+			if !o.setRefreshCookie(w, r, refreshBase64) {
+				return
+			}
+			response.GetRefreshToken = ""
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Error().Err(err).Msg("failed to encode response")
+			requestLog(r).Error().Err(err).Msg("failed to encode response")
 		}
 
-		log.Info().
-			Str("hashed_refresh_token", string(user.HashedRefreshToken)).
-			Str("new_refresh_token", refreshBase64).
+		requestLog(r).Info().
 			Str("status", "success").
 			Int("code", http.StatusOK).
 			Msg("Successfully sent response")
 	}
 }
+
+// writeClientError отвечает на отказ в аутентификации клиента
+func writeClientError(w http.ResponseWriter, r *http.Request, apiErr *response.Error) {
+	if apiErr.Code == response.CodeInvalidClient {
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-medods"`)
+	}
+	writeProblem(w, r, apiErr)
+}
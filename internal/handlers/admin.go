@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+)
+
+// AdminAuth - middleware, пропускающий только запросы с административным токеном
+// в заголовке Authorization: Bearer <token>
+func AdminAuth(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+				requestLog(r).Error().Str("path", r.URL.Path).Msg("admin authentication failed")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				writeError(w, r, response.CodeUnauthorized, "permission denied")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("failed to encode response")
+	}
+}
+
+// IntrospectHandler - хендлер для проверки access токена сервисами-потребителями:
+// токен активен, если подпись и срок действия верны и он не внесён в denylist
+func IntrospectHandler(db database.DBInterface, ownKey string, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeError(w, r, response.CodeInvalidRequest, "token is required")
+			return
+		}
+
+		claims, err := jwt.ParseAccessToken(token, ownKey, o.previousKeys...)
+		if err != nil {
+			requestLog(r).Info().Err(err).Msg("introspected token is invalid")
+			writeJSON(w, http.StatusOK, response.Introspection{Active: false})
+			return
+		}
+
+		revoked, err := db.IsTokenRevoked(r.Context(), claims.ID)
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to check token denylist")
+			writeError(w, r, response.CodeInternal, "failed to check token")
+			return
+		}
+		if revoked {
+			writeJSON(w, http.StatusOK, response.Introspection{Active: false})
+			return
+		}
+
+		var cnf *response.Confirmation
+		if claims.JKT != "" {
+			cnf = &response.Confirmation{JKT: claims.JKT}
+		}
+
+		writeJSON(w, http.StatusOK, response.Introspection{
+			Active:    true,
+			JTI:       claims.ID,
+			Subject:   claims.Subject,
+			GUID:      claims.GUID,
+			ClientID:  claims.ClientID,
+			Scope:     strings.Join(claims.Scope, " "),
+			Audience:  claims.Audience,
+			Cnf:       cnf,
+			IssuedAt:  claims.IssuedAt.Unix(),
+			ExpiresAt: claims.ExpiresAt.Unix(),
+		})
+	}
+}
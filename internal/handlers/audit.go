@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// ListAuditEventsHandler - хендлер для выборки из журнала аудита по пользователю,
+// типу события и интервалу времени (from включительно, to - нет)
+func ListAuditEventsHandler(store database.AuditStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := models.AuditFilter{}
+
+		if guid := q.Get("guid"); guid != "" {
+			if _, err := uuid.Parse(guid); err != nil {
+				writeError(w, r, response.CodeInvalidGUID, "invalid guid")
+				return
+			}
+			filter.UserGUID = guid
+		}
+
+		for _, value := range q["type"] {
+			for _, t := range strings.Split(value, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					filter.Types = append(filter.Types, t)
+				}
+			}
+		}
+
+		var err error
+		if filter.From, err = queryTime(r, "from"); err != nil {
+			writeError(w, r, response.CodeInvalidRequest, "invalid from")
+			return
+		}
+		if filter.To, err = queryTime(r, "to"); err != nil {
+			writeError(w, r, response.CodeInvalidRequest, "invalid to")
+			return
+		}
+
+		filter.Limit, err = queryInt(r, "limit", defaultAuditLimit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxAuditLimit {
+			writeError(w, r, response.CodeInvalidRequest, "invalid limit")
+			return
+		}
+
+		filter.Offset, err = queryInt(r, "offset", 0)
+		if err != nil || filter.Offset < 0 {
+			writeError(w, r, response.CodeInvalidRequest, "invalid offset")
+			return
+		}
+
+		events, err := store.ListAuditEvents(r.Context(), filter)
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to list audit events")
+			writeError(w, r, response.CodeInternal, "failed to list audit events")
+			return
+		}
+
+		page := response.AuditEvents{
+			Events: make([]response.AuditEvent, 0, len(events)),
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+		}
+		for _, e := range events {
+			event := response.AuditEvent{
+				ID:        e.ID,
+				Time:      e.CreatedAt,
+				Type:      e.Type,
+				Actor:     e.Actor,
+				IP:        e.IP,
+				UserAgent: e.UserAgent,
+				Outcome:   e.Outcome,
+				Details:   e.Details,
+			}
+			if e.UserGUID.Valid {
+				event.GUID = e.UserGUID.UUID.String()
+			}
+			page.Events = append(page.Events, event)
+		}
+		if len(events) == filter.Limit {
+			next := filter.Offset + filter.Limit
+			page.NextOffset = &next
+		}
+
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+func queryTime(r *http.Request, key string) (*time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+)
+
+const maxClientNameLength = 100
+
+// CreateClientHandler - хендлер для регистрации клиента
+func CreateClientHandler(store database.ClientStore, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req response.CreateClient
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to decode body")
+			writeError(w, r, response.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		client := &models.Client{
+			Name:             strings.TrimSpace(req.Name),
+			CertThumbprint:   req.CertThumbprint,
+			PublicKey:        req.PublicKey,
+			AllowedScopes:    dedupe(req.AllowedScopes),
+			AllowedAudiences: dedupe(req.AllowedAudiences),
+		}
+		if detail := validateClient(client); detail != "" {
+			writeError(w, r, response.CodeInvalidRequest, detail)
+			return
+		}
+		if req.NoSecret && client.CertThumbprint == "" && client.PublicKey == "" {
+			writeError(w, r, response.CodeInvalidRequest, "client without secret needs a certificate or a public key")
+			return
+		}
+
+		clientID, err := clientauth.GenerateClientID()
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to generate client id")
+			writeError(w, r, response.CodeInternal, "failed to create client")
+			return
+		}
+		client.ClientID = clientID
+
+		var secret string
+		if !req.NoSecret {
+			secret, client.SecretHash, err = clientauth.GenerateSecret()
+			if err != nil {
+				requestLog(r).Error().Err(err).Msg("failed to generate client secret")
+				writeError(w, r, response.CodeInternal, "failed to create client")
+				return
+			}
+		}
+
+		err = store.CreateClient(r.Context(), client)
+		if errors.Is(err, database.ErrClientExists) {
+			writeError(w, r, response.CodeInvalidRequest, "certificate is already registered")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to create client")
+			writeError(w, r, response.CodeInternal, "failed to create client")
+			return
+		}
+
+		requestLog(r).Info().Str("client_id", client.ClientID).Str("name", client.Name).Msg("client created")
+		o.audit.Record(r, audit.Event{
+			Type:    audit.EventClientCreated,
+			Actor:   audit.ActorAdmin,
+			Outcome: audit.OutcomeSuccess,
+			Details: "client " + client.ClientID,
+		})
+
+		resp := clientResponse(client)
+		resp.ClientSecret = secret
+		writeJSON(w, http.StatusCreated, resp)
+	}
+}
+
+// ListClientsHandler - хендлер для списка клиентов
+func ListClientsHandler(store database.ClientStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		clients, err := store.ListClients(r.Context())
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to list clients")
+			writeError(w, r, response.CodeInternal, "failed to list clients")
+			return
+		}
+
+		list := response.ClientList{Clients: make([]response.Client, 0, len(clients))}
+		for i := range clients {
+			list.Clients = append(list.Clients, clientResponse(&clients[i]))
+		}
+
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// GetClientHandler - хендлер для получения клиента
+func GetClientHandler(store database.ClientStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := loadClient(w, r, store)
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, clientResponse(client))
+	}
+}
+
+// UpdateClientHandler - хендлер для изменения имени, сертификата, ключа и разрешённых
+// клиенту областей доступа. Сужение списков действует и на выданные ранее refresh токены
+func UpdateClientHandler(store database.ClientStore, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req response.UpdateClient
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to decode body")
+			writeError(w, r, response.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		client, ok := loadClient(w, r, store)
+		if !ok {
+			return
+		}
+
+		if req.Name != nil {
+			client.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.CertThumbprint != nil {
+			client.CertThumbprint = *req.CertThumbprint
+		}
+		if req.PublicKey != nil {
+			client.PublicKey = *req.PublicKey
+		}
+		if req.AllowedScopes != nil {
+			client.AllowedScopes = dedupe(*req.AllowedScopes)
+		}
+		if req.AllowedAudiences != nil {
+			client.AllowedAudiences = dedupe(*req.AllowedAudiences)
+		}
+		if detail := validateClient(client); detail != "" {
+			writeError(w, r, response.CodeInvalidRequest, detail)
+			return
+		}
+
+		if !saveClient(w, r, store, client) {
+			return
+		}
+
+		o.audit.Record(r, audit.Event{
+			Type:    audit.EventClientUpdated,
+			Actor:   audit.ActorAdmin,
+			Outcome: audit.OutcomeSuccess,
+			Details: "client " + client.ClientID,
+		})
+		writeJSON(w, http.StatusOK, clientResponse(client))
+	}
+}
+
+// RotateClientSecretHandler - хендлер для выдачи клиенту нового секрета; старый перестаёт действовать сразу
+func RotateClientSecretHandler(store database.ClientStore, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := loadClient(w, r, store)
+		if !ok {
+			return
+		}
+
+		secret, hash, err := clientauth.GenerateSecret()
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to generate client secret")
+			writeError(w, r, response.CodeInternal, "failed to rotate secret")
+			return
+		}
+		client.SecretHash = hash
+
+		if !saveClient(w, r, store, client) {
+			return
+		}
+
+		requestLog(r).Info().Str("client_id", client.ClientID).Msg("client secret rotated")
+		o.audit.Record(r, audit.Event{
+			Type:    audit.EventClientSecretReset,
+			Actor:   audit.ActorAdmin,
+			Outcome: audit.OutcomeSuccess,
+			Details: "client " + client.ClientID,
+		})
+
+		resp := clientResponse(client)
+		resp.ClientSecret = secret
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// DeleteClientHandler - хендлер для удаления клиента
+func DeleteClientHandler(store database.ClientStore, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		clientID := chi.URLParam(r, "client_id")
+
+		err := store.DeleteClient(r.Context(), clientID)
+		if errors.Is(err, database.ErrClientNotFound) {
+			writeError(w, r, response.CodeClientNotFound, "client not found")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to delete client")
+			writeError(w, r, response.CodeInternal, "failed to delete client")
+			return
+		}
+
+		requestLog(r).Info().Str("client_id", clientID).Msg("client deleted")
+		o.audit.Record(r, audit.Event{
+			Type:    audit.EventClientDeleted,
+			Actor:   audit.ActorAdmin,
+			Outcome: audit.OutcomeSuccess,
+			Details: "client " + clientID,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func loadClient(w http.ResponseWriter, r *http.Request, store database.ClientStore) (*models.Client, bool) {
+	client, err := store.GetClient(r.Context(), chi.URLParam(r, "client_id"))
+	if errors.Is(err, database.ErrClientNotFound) {
+		writeError(w, r, response.CodeClientNotFound, "client not found")
+		return nil, false
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to get client")
+		writeError(w, r, response.CodeInternal, "failed to get client")
+		return nil, false
+	}
+
+	return client, true
+}
+
+func saveClient(w http.ResponseWriter, r *http.Request, store database.ClientStore, client *models.Client) bool {
+	err := store.UpdateClient(r.Context(), client)
+	if errors.Is(err, database.ErrClientNotFound) {
+		writeError(w, r, response.CodeClientNotFound, "client not found")
+		return false
+	}
+	if errors.Is(err, database.ErrClientExists) {
+		writeError(w, r, response.CodeInvalidRequest, "certificate is already registered")
+		return false
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to update client")
+		writeError(w, r, response.CodeInternal, "failed to update client")
+		return false
+	}
+
+	return true
+}
+
+// validateClient возвращает описание ошибки или пустую строку
+func validateClient(client *models.Client) string {
+	if client.Name == "" || len(client.Name) > maxClientNameLength {
+		return "name is required and must be at most 100 characters"
+	}
+	if client.CertThumbprint != "" {
+		if raw, err := base64.RawURLEncoding.DecodeString(client.CertThumbprint); err != nil || len(raw) != 32 {
+			return "cert_thumbprint must be a base64url SHA-256 digest"
+		}
+	}
+	if client.PublicKey != "" && !clientauth.ValidPublicKey(client.PublicKey) {
+		return "public_key must be a PEM encoded RSA, ECDSA or Ed25519 key"
+	}
+	for _, scope := range client.AllowedScopes {
+		if !validScopeToken(scope) || scope == ScopeOpenID {
+			return "allowed_scopes must be non-empty tokens without spaces; openid is implied"
+		}
+	}
+	for _, audience := range client.AllowedAudiences {
+		if !validScopeToken(audience) {
+			return "allowed_audiences must be non-empty tokens without spaces"
+		}
+	}
+	return ""
+}
+
+func clientResponse(client *models.Client) response.Client {
+	return response.Client{
+		ClientID:         client.ClientID,
+		Name:             client.Name,
+		HasSecret:        client.SecretHash != "",
+		CertThumbprint:   client.CertThumbprint,
+		PublicKey:        client.PublicKey,
+		AllowedScopes:    nonNil(client.AllowedScopes),
+		AllowedAudiences: nonNil(client.AllowedAudiences),
+		CreatedAt:        client.CreatedAt,
+		UpdatedAt:        client.UpdatedAt,
+	}
+}
+
+// validScopeToken проверяет область доступа или получателя по грамматике scope-token из RFC 6749
+func validScopeToken(s string) bool {
+	if s == "" || len(s) > maxScopeToken {
+		return false
+	}
+	for _, c := range s {
+		if c < 0x21 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// nonNil подставляет пустой список, чтобы в JSON было [] вместо null
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+)
+
+// Имена cookie и заголовка для выдачи токенов браузерным клиентам
+const (
+	// RefreshCookie - HttpOnly cookie с refresh токеном, видна только маршрутам refresh
+	RefreshCookie = "refresh_token"
+	// CSRFCookie - cookie с CSRF токеном, доступная JavaScript
+	CSRFCookie = "csrf_token"
+	// CSRFHeader - заголовок, в котором клиент повторяет значение CSRFCookie
+	CSRFHeader = "X-CSRF-Token"
+	// TokenDeliveryHeader - заголовок, которым браузерный клиент просит выдать
+	// refresh токен в cookie; значение TokenDeliveryCookie
+	TokenDeliveryHeader = "X-Token-Delivery"
+	TokenDeliveryCookie = "cookie"
+)
+
+// refreshCookiePaths ограничивают отправку refresh cookie маршрутами refresh: текущей
+// версии API и устаревшим без версии. Cookie с одним именем ставится на каждый путь
+var refreshCookiePaths = []string{APIPrefix + "/refresh", "/refresh"}
+
+// Cookies - настройки выдачи refresh токена в cookie. Запрос к /refresh с cookie
+// защищён от CSRF двойной отправкой: значение CSRFCookie должно совпасть с CSRFHeader
+type Cookies struct {
+	// Domain - домен cookie; пустой привязывает их к хосту сервиса
+	Domain string
+	// SameSite - политика отправки с других сайтов; по умолчанию Strict
+	SameSite http.SameSite
+	// MaxAge - срок жизни cookie; ноль делает их сессионными
+	MaxAge time.Duration
+	// Insecure снимает флаг Secure для локальной разработки по http
+	Insecure bool
+}
+
+// cookieDelivery сообщает, выдавать ли refresh токен в cookie. Режим cookie должен быть
+// включён, а клиент - попросить об этом заголовком TokenDeliveryHeader или предъявить
+// токен из cookie. Остальные клиенты получают токен в теле, как раньше
+func (o *options) cookieDelivery(r *http.Request, fromCookie bool) bool {
+	if o.cookies == nil {
+		return false
+	}
+	return fromCookie || strings.EqualFold(r.Header.Get(TokenDeliveryHeader), TokenDeliveryCookie)
+}
+
+// setRefreshCookie кладёт refresh токен в cookie. При ошибке отвечает клиенту сам
+// и возвращает false
+func (o *options) setRefreshCookie(w http.ResponseWriter, r *http.Request, refresh string) bool {
+	if err := o.cookies.set(w, refresh); err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to generate CSRF token")
+		writeError(w, r, response.CodeInternal, "failed to generate tokens")
+		return false
+	}
+	return true
+}
+
+// set кладёт refresh токен в HttpOnly cookie и выдаёт новый CSRF токен
+func (c *Cookies) set(w http.ResponseWriter, refresh string) error {
+	csrf := make([]byte, 32)
+	if _, err := rand.Read(csrf); err != nil {
+		return err
+	}
+
+	for _, path := range refreshCookiePaths {
+		http.SetCookie(w, c.cookie(RefreshCookie, refresh, path, true))
+	}
+	http.SetCookie(w, c.cookie(CSRFCookie, hex.EncodeToString(csrf), "/", false))
+	return nil
+}
+
+// clear удаляет cookie, например после предъявления уже использованного токена
+func (c *Cookies) clear(w http.ResponseWriter) {
+	cookies := []*http.Cookie{c.cookie(CSRFCookie, "", "/", false)}
+	for _, path := range refreshCookiePaths {
+		cookies = append(cookies, c.cookie(RefreshCookie, "", path, true))
+	}
+	for _, cookie := range cookies {
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+func (c *Cookies) cookie(name, value, path string, httpOnly bool) *http.Cookie {
+	sameSite := c.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteStrictMode
+	}
+
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   c.Domain,
+		MaxAge:   int(c.MaxAge.Seconds()),
+		Secure:   !c.Insecure,
+		HttpOnly: httpOnly,
+		SameSite: sameSite,
+	}
+}
+
+// refreshToken возвращает refresh токен из cookie после проверки CSRF токена.
+// Пустая строка без ошибки - в запросе нет refresh cookie
+func (c *Cookies) refreshToken(r *http.Request) (string, *response.Error) {
+	refresh, err := r.Cookie(RefreshCookie)
+	if err != nil || refresh.Value == "" {
+		return "", nil
+	}
+
+	csrf, err := r.Cookie(CSRFCookie)
+	header := r.Header.Get(CSRFHeader)
+	if err != nil || csrf.Value == "" || header == "" ||
+		subtle.ConstantTimeCompare([]byte(csrf.Value), []byte(header)) != 1 {
+		return "", response.NewError(response.CodeCSRFMismatch, "CSRF token is missing or does not match")
+	}
+
+	return refresh.Value, nil
+}
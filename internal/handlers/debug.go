@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/inspect"
+)
+
+// DebugTokenHandler разбирает access токен из формы (token): заголовок, claims,
+// ключ подписи, срок действия, отзыв и сессию. Отладочный хендлер, включается
+// WithDebugEndpoints только в среде разработки
+func DebugTokenHandler(db database.DBInterface, sessions database.SessionStore, ownKey string,
+	opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+	inspector := inspect.NewInspector(db, sessions, ownKey, o.previousKeys...)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeError(w, r, response.CodeInvalidRequest, "token is required")
+			return
+		}
+
+		report, err := inspector.Inspect(r.Context(), token)
+		if errors.Is(err, inspect.ErrMalformed) {
+			requestLog(r).Info().Err(err).Msg("debug token is malformed")
+			writeError(w, r, response.CodeInvalidRequest, "token is malformed")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to inspect token")
+			writeError(w, r, response.CodeInternal, "failed to inspect token")
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, report)
+	}
+}
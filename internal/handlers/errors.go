@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+)
+
+// writeError отвечает клиенту ошибкой в формате application/problem+json
+func writeError(w http.ResponseWriter, r *http.Request, code response.ErrorCode, detail string) {
+	writeProblem(w, r, response.NewError(code, detail))
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, apiErr *response.Error) {
+	problem := apiErr.Problem(r.URL.Path)
+	setFailure(r, string(apiErr.Code))
+
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(apiErr.RetryAfter)))
+	}
+	w.Header().Set("Content-Type", response.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to encode problem")
+	}
+}
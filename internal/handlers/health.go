@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/volchok96/auth-medods/internal/health"
+)
+
+// HealthHandler - проверка живости: процесс запущен и обрабатывает запросы.
+// Зависимости не проверяются, чтобы сбой базы не приводил к перезапуску сервиса
+func HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, health.Report{Status: health.StatusOK})
+	}
+}
+
+// ReadyHandler - проверка готовности принимать запросы. Отвечает 503, если не
+// прошла обязательная проверка или сервис останавливается. Тексты ошибок
+// проверок пишутся в лог, а в ответ попадают только с details
+func ReadyHandler(checker *health.Checker, details bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Check(r.Context())
+
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+			requestLog(r).Warn().Str("status", report.Status).Interface("checks", report.Checks).Msg("service is not ready")
+		} else if report.Failed() {
+			requestLog(r).Warn().Interface("checks", report.Checks).Msg("optional readiness check failed")
+		}
+
+		if !details {
+			report = report.Redacted()
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, status, report)
+	}
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/lockout"
+	"github.com/volchok96/auth-medods/internal/metrics"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// Темы предупреждений, которые получает пользователь
+const (
+	ipChangedSubject     = "WARNING! IP ADDRESS TO ACCESS MEDODS HAS JUST CHANGED"
+	refreshLockedSubject = "WARNING! TOKEN REFRESH FOR MEDODS HAS BEEN LOCKED"
+)
+
+// Виды предупреждений для метрик доставки
+const (
+	notifyIPChanged     = "ip_changed"
+	notifyRefreshLocked = "refresh_locked"
+)
+
+// tokenIssuer выдаёт и обновляет пары токенов пользователя. Общая часть
+// /access, /refresh и /oauth/token; ответ клиенту формирует вызывающий хендлер
+type tokenIssuer struct {
+	db       database.DBInterface
+	ownKey   string
+	tokenTTL time.Duration
+	o        *options
+}
+
+// issueForGUID выдаёт токены с областями доступа g существующему активному пользователю
+// по GUID и отмечает время аутентификации. fail записывает в журнал аудита причину отказа
+func (ti *tokenIssuer) issueForGUID(r *http.Request, guid, clientIP, clientID string, g grant,
+	fail func(details string)) (*models.User, *jwt.Tokens, *response.Error) {
+	if guid == "" {
+		requestLog(r).Error().Msg("no guid provided")
+		return nil, nil, response.NewError(response.CodeInvalidGUID, "guid is required")
+	}
+
+	if _, err := uuid.Parse(guid); err != nil {
+		requestLog(r).Error().Err(err).Msg("invalid guid")
+		fail("invalid guid")
+		return nil, nil, response.NewError(response.CodeInvalidGUID, "invalid guid")
+	}
+
+	// Токены выдаются только заранее заведённым и активным пользователям
+	user, err := ti.db.GetUserByGUID(r.Context(), guid)
+	if errors.Is(err, database.ErrUserNotFound) {
+		requestLog(r).Error().Str("guid", guid).Msg("unknown user")
+		fail("user not found")
+		return nil, nil, response.NewError(response.CodeUserNotFound, "user not found")
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to get user")
+		return nil, nil, &response.Error{Code: response.CodeInternal, Detail: "failed to get user", Err: err}
+	}
+
+	if apiErr := inactiveError(r, user); apiErr != nil {
+		fail("user is " + string(user.Status))
+		return nil, nil, apiErr
+	}
+
+	now := time.Now()
+	user.AuthTime = &now
+
+	tokens, apiErr := ti.issue(r, user, clientIP, clientID, g, false)
+	if apiErr != nil {
+		if apiErr.Code == response.CodeUserInactive {
+			fail("user became inactive during issuance")
+		}
+		return nil, nil, apiErr
+	}
+	metrics.TokensIssued.WithLabelValues("guid").Inc()
+	return user, tokens, nil
+}
+
+// refresh проверяет предъявленный refresh токен и выдаёт пользователю новую пару
+// с теми же или более узкими, чем прежде, областями доступа. Привязанный к ключу
+// DPoP токен принимается только с proof этого ключа, выданный клиенту - только от
+// этого клиента. Неудачные попытки учитываются
+// в блокировке refresh
+func (ti *tokenIssuer) refresh(r *http.Request, user *models.User, presented []byte, clientIP string,
+	req scopeRequest, fail func(details string)) (*jwt.Tokens, grant, *response.Error) {
+	guid := user.UserGUID.String()
+	session := lockout.SessionID(presented)
+
+	if apiErr := inactiveError(r, user); apiErr != nil {
+		fail("user is " + string(user.Status))
+		return nil, grant{}, apiErr
+	}
+
+	// После серии неудачных попыток refresh заблокирован даже для правильного токена
+	if state, err := ti.o.lockout.Check(r.Context(), guid, session, lockout.SourceID(clientIP)); err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to check refresh lockout")
+	} else if state.Locked {
+		retryAfter := time.Until(state.Until)
+		requestLog(r).Warn().Str("guid", guid).Str("scope", state.Scope).Time("until", state.Until).Msg("refresh is locked")
+		fail("refresh is locked")
+		return nil, grant{}, &response.Error{
+			Code:       response.CodeRefreshLocked,
+			Detail:     fmt.Sprintf("too many failed refresh attempts, retry in %d seconds", ceilSeconds(retryAfter)),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	// genuine - предъявлен настоящий токен пользователя, см. lockout.Guard.Failure
+	reject := func(code response.ErrorCode, detail, reason string, genuine bool) *response.Error {
+		fail(reason)
+		ti.o.refreshFailed(r, user, clientIP, session, genuine)
+		return response.NewError(code, detail)
+	}
+
+	if len(presented) == 0 {
+		requestLog(r).Error().Msg("decoded token is empty")
+		return nil, grant{}, reject(response.CodeInvalidRefreshToken, "invalid refresh token", "malformed refresh token", false)
+	}
+
+	err := compareHash(r, user.HashedRefreshToken, presented)
+	if err != nil {
+		// Токен, совпавший с прежним хешем, уже был однажды обменян
+		if user.PreviousRefreshHash != "" && compareHash(r, user.PreviousRefreshHash, presented) == nil {
+			requestLog(r).Warn().Str("guid", guid).Msg("refresh token reuse detected")
+			return nil, grant{}, reject(response.CodeTokenReused, "refresh token has already been used", "refresh token reused", true)
+		}
+
+		requestLog(r).Error().Err(err).Msg("invalid refresh token")
+		return nil, grant{}, reject(response.CodeInvalidRefreshToken, "invalid refresh token", "refresh token mismatch", false)
+	}
+
+	// Refresh токен, привязанный к ключу DPoP, принимается только с доказательством этого ключа
+	if user.RefreshJKT != "" && user.RefreshJKT != req.jkt {
+		requestLog(r).Warn().Str("guid", guid).Msg("refresh without a matching DPoP proof")
+		return nil, grant{}, reject(response.CodeInvalidDPoPProof,
+			"refresh token is bound to a DPoP key", "DPoP key mismatch", true)
+	}
+
+	// Обменять refresh токен может только клиент, которому он выдан
+	if user.RefreshClientID != clientIDOf(req.client) {
+		requestLog(r).Warn().Str("guid", guid).Str("client_id", clientIDOf(req.client)).
+			Msg("refresh by a client the token was not issued to")
+		return nil, grant{}, reject(response.CodeInvalidRefreshToken, "invalid refresh token", "client mismatch", true)
+	}
+
+	// Уведомление на email при смене IP. Проверяется только после сверки refresh
+	// токена, чтобы запросы без него не вызывали предупреждений
+	if user.IP != clientIP {
+		requestLog(r).Warn().
+			Str("User email", user.Email).
+			Str("Old IP", user.IP).
+			Str("New IP", clientIP).
+			Msg("IP address changed")
+		metrics.IPChanges.Inc()
+
+		ti.o.audit.Record(r, audit.Event{
+			Type:     audit.EventIPChanged,
+			Actor:    audit.ActorUser(guid),
+			UserGUID: guid,
+			Outcome:  audit.OutcomeSuccess,
+			Details:  fmt.Sprintf("previous ip %s", user.IP),
+		})
+
+		ti.o.warn(r, notifyIPChanged, user.Email, ipChangedSubject,
+			fmt.Sprintf("Query from a new IP address (%s). Was it you?", clientIP))
+	}
+
+	// Области доступа проверяются после токена, чтобы их не перебирали без него
+	g, apiErr := ti.o.narrowGrant(req, grant{Scope: user.RefreshScope, Audience: user.RefreshAudience})
+	if apiErr != nil {
+		fail("refresh requested a wider grant")
+		return nil, grant{}, apiErr
+	}
+
+	tokens, apiErr := ti.issue(r, user, clientIP, clientIDOf(req.client), g, true)
+	if apiErr != nil {
+		switch apiErr.Code {
+		case response.CodeUserInactive:
+			fail("user became inactive during refresh")
+		case response.CodeTokenReused:
+			// Тот же токен одновременно обменял другой запрос
+			requestLog(r).Warn().Str("guid", guid).Msg("concurrent refresh token reuse detected")
+			return nil, grant{}, reject(apiErr.Code, apiErr.Detail, "refresh token reused concurrently", true)
+		}
+		return nil, grant{}, apiErr
+	}
+
+	if err := ti.o.lockout.Success(r.Context(), guid, session); err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to reset refresh lockout")
+	}
+
+	ti.o.audit.Record(r, audit.Event{
+		Type:     audit.EventTokenRefreshed,
+		Actor:    audit.ActorUser(guid),
+		UserGUID: guid,
+		Outcome:  audit.OutcomeSuccess,
+		Details:  "session " + tokens.ID,
+	})
+	metrics.TokensRefreshed.Inc()
+
+	return tokens, g, nil
+}
+
+// issue выдаёт новую пару токенов, сохраняет хеш refresh токена вместе с выданными
+// областями доступа и открывает сессию. rotate - обмен refresh токена: новый
+// сохраняется, только если прежний ещё никто не обменял
+func (ti *tokenIssuer) issue(r *http.Request, user *models.User, clientIP, clientID string,
+	g grant, rotate bool) (*jwt.Tokens, *response.Error) {
+	tokens, err := jwt.NewTokens(r.Context(), ti.ownKey, jwt.Params{
+		GUID:     user.UserGUID.String(),
+		IP:       clientIP,
+		ClientID: clientID,
+		Scope:    g.Scope,
+		Audience: g.Audience,
+		JKT:      g.JKT,
+		TTL:      ti.tokenTTL,
+	})
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to generate tokens")
+		return nil, &response.Error{Code: response.CodeInternal, Detail: "failed to generate tokens", Err: err}
+	}
+
+	previousHash := user.HashedRefreshToken
+	user.IP = clientIP
+	user.HashedRefreshToken = tokens.RefreshHash
+	user.RefreshScope = g.Scope
+	user.RefreshAudience = g.Audience
+	user.RefreshJKT = g.JKT
+	user.RefreshClientID = clientID
+
+	if rotate {
+		err = ti.db.RotateRefreshToken(r.Context(), user, previousHash)
+	} else {
+		err = ti.db.UpdateUser(r.Context(), user)
+	}
+	if errors.Is(err, database.ErrRefreshConflict) {
+		return nil, response.NewError(response.CodeTokenReused, "refresh token has already been used")
+	}
+	if errors.Is(err, database.ErrUserInactive) {
+		requestLog(r).Error().Str("guid", user.UserGUID.String()).Msg("user was locked during issuance")
+		return nil, response.NewError(response.CodeUserInactive, "user is not active")
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to save hash")
+		return nil, &response.Error{Code: response.CodeInternal, Detail: "failed to save data", Err: err}
+	}
+
+	if err := createSession(ti.db, r, user, tokens); err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to save session")
+		return nil, &response.Error{Code: response.CodeInternal, Detail: "failed to save data", Err: err}
+	}
+
+	return tokens, nil
+}
+
+// inactiveError возвращает ошибку, если пользователю нельзя выдавать токены
+func inactiveError(r *http.Request, user *models.User) *response.Error {
+	if user.IsActive(time.Now()) {
+		return nil
+	}
+
+	requestLog(r).Error().
+		Str("guid", user.UserGUID.String()).
+		Str("status", string(user.Status)).
+		Msg("user is not active")
+	return response.NewError(response.CodeUserInactive, "user is "+string(user.Status))
+}
+
+// compareHash сравнивает refresh токен с bcrypt хешем и учитывает время сравнения
+// в метриках и трассе. Несовпадение не считается ошибкой span
+func compareHash(r *http.Request, hash string, token []byte) error {
+	_, span := tracing.Start(r.Context(), "bcrypt.CompareHashAndPassword")
+	defer span.End()
+
+	start := time.Now()
+	err := bcrypt.CompareHashAndPassword([]byte(hash), token)
+	metrics.BcryptDuration.WithLabelValues("compare").Observe(time.Since(start).Seconds())
+	span.SetAttributes(attribute.Bool("bcrypt.match", err == nil))
+	return err
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/lockout"
+	"github.com/volchok96/auth-medods/internal/metrics"
+)
+
+// refreshFailed учитывает неудачную попытку refresh в сессии session и, если она привела
+// к блокировке, сообщает о ней в журнал аудита, а при настоящем токене и пользователю
+func (o *options) refreshFailed(r *http.Request, user *models.User, clientIP, session string, genuine bool) {
+	guid := user.UserGUID.String()
+
+	state, err := o.lockout.Failure(r.Context(), guid, session, lockout.SourceID(clientIP), genuine)
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to record refresh failure")
+		return
+	}
+	if !state.Locked {
+		return
+	}
+
+	metrics.RefreshLockouts.WithLabelValues(state.Scope).Inc()
+	requestLog(r).Warn().
+		Str("guid", guid).
+		Str("scope", state.Scope).
+		Str("ip", clientIP).
+		Time("until", state.Until).
+		Msg("refresh locked after repeated failures")
+
+	o.audit.Record(r, audit.Event{
+		Type:     audit.EventRefreshLocked,
+		Actor:    audit.ActorSystem,
+		UserGUID: guid,
+		Outcome:  audit.OutcomeSuccess,
+		Details:  fmt.Sprintf("%s locked until %s, lockout %d", state.Scope, state.Until.UTC().Format(time.RFC3339), state.Lockouts),
+	})
+
+	// Блокировка сессии, которую открыли чужим токеном, пользователя не касается
+	if !genuine {
+		return
+	}
+	o.warn(r, notifyRefreshLocked, user.Email, refreshLockedSubject, fmt.Sprintf(
+		"Too many failed attempts to refresh your tokens from IP address %s. "+
+			"Token refresh is locked until %s. If it wasn't you, contact support.",
+		clientIP, state.Until.UTC().Format(time.RFC1123)))
+}
+
+// GetLockoutHandler - хендлер для просмотра счётчиков неудачных попыток refresh пользователя
+func GetLockoutHandler(db database.DBInterface, store database.LockoutStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadUser(w, r, db, chi.URLParam(r, "guid"))
+		if !ok {
+			return
+		}
+
+		lockouts, err := store.ListRefreshLockouts(r.Context(), user.UserGUID.String())
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to list refresh lockouts")
+			writeError(w, r, response.CodeInternal, "failed to load lockouts")
+			return
+		}
+
+		now := time.Now()
+		resp := response.RefreshLockouts{
+			GUID:     user.UserGUID.String(),
+			Lockouts: make([]response.RefreshLockout, 0, len(lockouts)),
+		}
+		for i := range lockouts {
+			l := &lockouts[i]
+			locked := l.IsLocked(now)
+			resp.Locked = resp.Locked || locked
+			resp.Lockouts = append(resp.Lockouts, response.RefreshLockout{
+				Scope:         l.Scope,
+				Session:       l.Subject,
+				Failures:      l.Failures,
+				Lockouts:      l.Lockouts,
+				Locked:        locked,
+				LockedUntil:   l.LockedUntil,
+				LastFailureAt: l.LastFailureAt,
+			})
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// ClearLockoutHandler - хендлер для снятия блокировки refresh и сброса счётчиков пользователя
+func ClearLockoutHandler(db database.DBInterface, store database.LockoutStore, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadUser(w, r, db, chi.URLParam(r, "guid"))
+		if !ok {
+			return
+		}
+		guid := user.UserGUID.String()
+
+		n, err := store.ClearRefreshLockouts(r.Context(), guid)
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to clear refresh lockouts")
+			writeError(w, r, response.CodeInternal, "failed to clear lockouts")
+			return
+		}
+
+		requestLog(r).Info().Str("guid", guid).Int64("cleared", n).Msg("refresh lockout cleared")
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventLockoutCleared,
+			Actor:    audit.ActorAdmin,
+			UserGUID: guid,
+			Outcome:  audit.OutcomeSuccess,
+			Details:  fmt.Sprintf("%d counters cleared", n),
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volchok96/auth-medods/internal/metrics"
+)
+
+// Операции выдачи токенов в метрике отказов
+const (
+	operationIssue   = "issue"
+	operationRefresh = "refresh"
+	operationOAuth   = "oauth"
+)
+
+// unmatchedRoute - метка маршрута для запросов мимо маршрутов, чтобы случайные
+// адреса не раздували число рядов метрики
+const unmatchedRoute = "unmatched"
+
+type failureKey struct{}
+
+// failure - код ошибки ответа, который хендлер передаёт метрикам
+type failure struct {
+	reason string
+}
+
+// setFailure запоминает код ошибки ответа и добавляет его в span запроса.
+// Сохраняется первый код: он точнее кодов, в которые его переводят при ответе
+func setFailure(r *http.Request, reason string) {
+	if f, ok := r.Context().Value(failureKey{}).(*failure); ok && f.reason == "" {
+		f.reason = reason
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("auth.failure_reason", reason))
+	}
+}
+
+// wrapWriter возвращает обёртку, которая помнит статус ответа, переиспользуя
+// обёртку внешнего middleware
+func wrapWriter(w http.ResponseWriter, r *http.Request) middleware.WrapResponseWriter {
+	if ww, ok := w.(middleware.WrapResponseWriter); ok {
+		return ww
+	}
+	return middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+}
+
+// routePattern возвращает шаблон маршрута, который обработал запрос, или пустую
+// строку, если маршрут не найден
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		return rctx.RoutePattern()
+	}
+	return ""
+}
+
+// responseStatus - статус ответа; хендлер, который ничего не записал, ответил 200
+func responseStatus(ww middleware.WrapResponseWriter) int {
+	if status := ww.Status(); status != 0 {
+		return status
+	}
+	return http.StatusOK
+}
+
+// Metrics измеряет время обработки запросов по шаблону маршрута, методу и статусу
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := wrapWriter(w, r)
+		start := time.Now()
+
+		defer func() {
+			route := routePattern(r)
+			if route == "" {
+				route = unmatchedRoute
+			}
+			metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(responseStatus(ww))).
+				Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// TokenMetrics считает отклонённые запросы операции выдачи токенов по коду
+// ошибки ответа. Ответ без кода учитывается по HTTP статусу
+func TokenMetrics(operation string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			f := &failure{}
+			ww := wrapWriter(w, r)
+
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), failureKey{}, f)))
+
+			if ww.Status() < http.StatusBadRequest {
+				return
+			}
+			reason := f.reason
+			if reason == "" {
+				reason = strconv.Itoa(ww.Status())
+			}
+			metrics.TokenFailures.WithLabelValues(operation, reason).Inc()
+		})
+	}
+}
+
+// MetricsHandler отдаёт метрики в формате Prometheus
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+)
+
+// RequestIDHeader - заголовок с идентификатором запроса. Значение клиента или
+// прокси сохраняется, иначе сервис создаёт новое; ответ всегда его содержит
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen - более длинный идентификатор от клиента заменяется своим
+const maxRequestIDLen = 128
+
+// refreshBodyLimit - наибольшее тело /refresh и /oauth/token. Запрос с GUID,
+// refresh токеном и параметрами OAuth занимает несколько сотен байт
+const refreshBodyLimit = 4 << 10
+
+type requestIDKey struct{}
+
+// RequestIDFromContext возвращает идентификатор запроса или пустую строку
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID принимает X-Request-ID запроса или создаёт новый, добавляет его в
+// контекст и в ответ
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// ClientIP определяет адрес клиента с учётом доверенных прокси и кладёт его в
+// контекст; лимиты, журнал аудита и логи берут его через ip.FromRequest
+func ClientIP(resolver *ip.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(ip.WithClient(r.Context(), resolver.ClientIP(r))))
+		})
+	}
+}
+
+// validRequestID допускает только печатные символы без пробелов и кавычек,
+// чтобы чужое значение не ломало логи и заголовки
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestLogger кладёт в контекст логгер с идентификатором запроса и трассы и после
+// ответа пишет access лог: метод, маршрут, статус, размер и время обработки.
+// Строка запроса не пишется: в ней могут быть токены
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logCtx := log.With().Str("request_id", RequestIDFromContext(r.Context()))
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logCtx = logCtx.Str("trace_id", sc.TraceID().String())
+		}
+		logger := logCtx.Logger()
+		r = r.WithContext(logger.WithContext(r.Context()))
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		defer func() {
+			status := responseStatus(ww)
+			event := logger.Info()
+			if status >= http.StatusInternalServerError {
+				event = logger.Error()
+			}
+			if route := routePattern(r); route != "" {
+				event = event.Str("route", route)
+			}
+			event.
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Int("status", status).
+				Int("bytes", ww.BytesWritten()).
+				Dur("latency", time.Since(start)).
+				Str("ip", ip.FromRequest(r)).
+				Str("user_agent", r.UserAgent()).
+				Msg("request completed")
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// Recoverer перехватывает панику хендлера, пишет её со стеком в лог и отвечает
+// 500 problem+json, если ответ ещё не начат
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := wrapWriter(w, r)
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// http.ErrAbortHandler - штатный способ прервать ответ, его обрабатывает сервер
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			requestLog(r).Error().
+				Interface("panic", rec).
+				Str("stack", string(debug.Stack())).
+				Msg("handler panicked")
+
+			if ww.Status() == 0 {
+				writeError(ww, r, response.CodeInternal, "internal error")
+			}
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// MaxBodySize ограничивает размер тела запроса. Чтение сверх предела возвращает
+// *http.MaxBytesError, хендлер отвечает на неё 413
+func MaxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// requestLog - логгер запроса из контекста. Вне RequestLogger, например в
+// тестах хендлеров, используется общий логгер
+func requestLog(r *http.Request) *zerolog.Logger {
+	if l := zerolog.Ctx(r.Context()); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/metrics"
+)
+
+// Типы grant, которые принимает /oauth/token
+const (
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	// GrantGUID - выдача токенов пользователю по GUID, как GET /access
+	GrantGUID = "urn:medods:params:oauth:grant-type:guid"
+)
+
+// Коды ошибок RFC 6749, раздел 5.2
+const (
+	oauthInvalidRequest       = "invalid_request"
+	oauthInvalidClient        = "invalid_client"
+	oauthInvalidGrant         = "invalid_grant"
+	oauthUnauthorizedClient   = "unauthorized_client"
+	oauthUnsupportedGrantType = "unsupported_grant_type"
+	oauthInvalidScope         = "invalid_scope"
+	// oauthInvalidTarget - недопустимый получатель токена (RFC 8707)
+	oauthInvalidTarget = "invalid_target"
+	// oauthInvalidDPoPProof - неверный DPoP proof (RFC 9449)
+	oauthInvalidDPoPProof = "invalid_dpop_proof"
+	oauthServerError      = "server_error"
+	// oauthTemporarilyUnavailable - превышен лимит запросов; RFC 6749 описывает код
+	// для authorization endpoint, вместе с 429 и Retry-After он говорит повторить позже
+	oauthTemporarilyUnavailable = "temporarily_unavailable"
+)
+
+// TokenHandler - хендлер OAuth 2.0 token endpoint. Параметры принимаются только
+// из тела application/x-www-form-urlencoded
+func TokenHandler(db database.DBInterface, ownKey string, tokenTTL time.Duration, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+	issuer := &tokenIssuer{db: db, ownKey: ownKey, tokenTTL: tokenTTL, o: o}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+
+		if err := r.ParseForm(); bodyTooLarge(err) {
+			writeOAuthError(w, r, http.StatusRequestEntityTooLarge, oauthInvalidRequest, "request body is too large")
+			return
+		} else if err != nil {
+			writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "invalid form body")
+			return
+		}
+
+		grantType := r.PostForm.Get("grant_type")
+		if grantType == "" {
+			writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "grant_type is required")
+			return
+		}
+
+		client, apiErr := o.authenticateClient(r)
+		if apiErr != nil {
+			o.audit.Record(r, audit.Event{
+				Type:    audit.EventTokenIssued,
+				Actor:   audit.ActorClient,
+				Outcome: audit.OutcomeFailure,
+				Details: "client authentication failed",
+			})
+			writeOAuthProblem(w, r, apiErr)
+			return
+		}
+
+		scope := newScopeRequest(client, r.PostForm.Get("scope"), r.PostForm.Get("audience"))
+		if scope.jkt, apiErr = o.dpopKey(r); apiErr != nil {
+			writeOAuthProblem(w, r, apiErr)
+			return
+		}
+
+		clientIP := ip.FromRequest(r)
+		if clientIP == "" {
+			requestLog(r).Error().Msg("failed to get IP")
+			writeOAuthError(w, r, http.StatusInternalServerError, oauthServerError, "failed to get IP")
+			return
+		}
+
+		switch grantType {
+		case GrantClientCredentials:
+			clientCredentialsGrant(w, r, o, client, scope, ownKey, tokenTTL)
+		case GrantRefreshToken:
+			refreshTokenGrant(w, r, o, issuer, client, clientIP, scope)
+		case GrantGUID:
+			guidGrant(w, r, o, issuer, client, clientIP, scope)
+		default:
+			writeOAuthError(w, r, http.StatusBadRequest, oauthUnsupportedGrantType, "unsupported grant_type")
+		}
+	}
+}
+
+// clientCredentialsGrant выдаёт access токен самому клиенту
+func clientCredentialsGrant(w http.ResponseWriter, r *http.Request, o *options, client *models.Client,
+	scope scopeRequest, ownKey string, tokenTTL time.Duration) {
+	if client == nil {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthUnauthorizedClient,
+			"client_credentials requires client authentication")
+		return
+	}
+
+	if slices.Contains(scope.scope, ScopeOpenID) {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidScope, "openid requires a user")
+		return
+	}
+
+	g, apiErr := o.grantFor(scope)
+	if apiErr != nil {
+		writeOAuthProblem(w, r, apiErr)
+		return
+	}
+
+	tokens, err := jwt.NewClientToken(r.Context(), ownKey, jwt.Params{
+		ClientID: client.ClientID,
+		Scope:    g.Scope,
+		Audience: g.Audience,
+		JKT:      g.JKT,
+		TTL:      tokenTTL,
+	})
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to generate client token")
+		writeOAuthError(w, r, http.StatusInternalServerError, oauthServerError, "failed to generate tokens")
+		return
+	}
+
+	o.audit.Record(r, audit.Event{
+		Type:    audit.EventTokenIssued,
+		Actor:   audit.ActorClientID(client.ClientID),
+		Outcome: audit.OutcomeSuccess,
+		Details: "client token " + tokens.ID,
+	})
+	metrics.TokensIssued.WithLabelValues(GrantClientCredentials).Inc()
+	writeJSON(w, http.StatusOK, response.TokenResponse{
+		AccessToken: tokens.Access,
+		TokenType:   g.tokenType(),
+		ExpiresIn:   int64(tokenTTL.Seconds()),
+		Scope:       strings.Join(g.Scope, " "),
+	})
+}
+
+// refreshTokenGrant обменивает refresh токен на новую пару; пользователь
+// определяется по GUID в начале токена. Токен принимается как есть или в base64,
+// как его выдают /access и /refresh
+func refreshTokenGrant(w http.ResponseWriter, r *http.Request, o *options, issuer *tokenIssuer,
+	client *models.Client, clientIP string, scope scopeRequest) {
+	refreshToken := r.PostForm.Get("refresh_token")
+	if refreshToken == "" {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidRequest, "refresh_token is required")
+		return
+	}
+
+	presented, ok := jwt.DecodeRefreshToken(refreshToken)
+	guid, hasGUID := jwt.RefreshTokenGUID(string(presented))
+	if !ok || !hasGUID {
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidGrant, "invalid refresh token")
+		return
+	}
+
+	fail := func(details string) {
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventTokenRefreshed,
+			Actor:    audit.ActorUser(guid),
+			UserGUID: guid,
+			Outcome:  audit.OutcomeFailure,
+			Details:  details,
+		})
+	}
+
+	user, err := issuer.db.GetUserByGUID(r.Context(), guid)
+	if errors.Is(err, database.ErrUserNotFound) {
+		fail("user not found")
+		writeOAuthError(w, r, http.StatusBadRequest, oauthInvalidGrant, "invalid refresh token")
+		return
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to get user")
+		writeOAuthError(w, r, http.StatusInternalServerError, oauthServerError, "failed to get user")
+		return
+	}
+
+	tokens, g, apiErr := issuer.refresh(r, user, presented, clientIP, scope, fail)
+	if apiErr != nil {
+		writeOAuthProblem(w, r, apiErr)
+		return
+	}
+
+	writeTokenResponse(w, r, issuer, user, tokens, clientIDOf(client), g)
+}
+
+// guidGrant выдаёт токены пользователю по GUID от имени клиента
+func guidGrant(w http.ResponseWriter, r *http.Request, o *options, issuer *tokenIssuer,
+	client *models.Client, clientIP string, scope scopeRequest) {
+	guid := r.PostForm.Get("guid")
+
+	actor := audit.ActorClient
+	if client != nil {
+		actor = audit.ActorClientID(client.ClientID)
+	}
+	fail := func(details string) {
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventTokenIssued,
+			Actor:    actor,
+			UserGUID: guid,
+			Outcome:  audit.OutcomeFailure,
+			Details:  details,
+		})
+	}
+
+	g, apiErr := o.grantFor(scope)
+	if apiErr != nil {
+		fail("requested grant is not allowed")
+		writeOAuthProblem(w, r, apiErr)
+		return
+	}
+
+	user, tokens, apiErr := issuer.issueForGUID(r, guid, clientIP, clientIDOf(client), g, fail)
+	if apiErr != nil {
+		writeOAuthProblem(w, r, apiErr)
+		return
+	}
+
+	o.audit.Record(r, audit.Event{
+		Type:     audit.EventTokenIssued,
+		Actor:    actor,
+		UserGUID: guid,
+		Outcome:  audit.OutcomeSuccess,
+		Details:  "session " + tokens.ID,
+	})
+	writeTokenResponse(w, r, issuer, user, tokens, clientIDOf(client), g)
+}
+
+func clientIDOf(client *models.Client) string {
+	if client == nil {
+		return ""
+	}
+	return client.ClientID
+}
+
+// writeTokenResponse отвечает парой токенов пользователя с выданными областями
+// доступа; при выданной openid добавляет ID токен с nonce из формы
+func writeTokenResponse(w http.ResponseWriter, r *http.Request, issuer *tokenIssuer, user *models.User,
+	tokens *jwt.Tokens, clientID string, g grant) {
+	resp := response.TokenResponse{
+		AccessToken:  tokens.Access,
+		TokenType:    g.tokenType(),
+		ExpiresIn:    int64(issuer.tokenTTL.Seconds()),
+		RefreshToken: tokens.Refresh,
+		Scope:        strings.Join(g.Scope, " "),
+	}
+
+	if g.openID() {
+		idToken, apiErr := issuer.idToken(r, user, clientID, r.PostForm.Get("nonce"))
+		if apiErr != nil {
+			writeOAuthProblem(w, r, apiErr)
+			return
+		}
+		resp.IDToken = idToken
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeOAuthProblem переводит ошибку выдачи токенов в код RFC 6749
+func writeOAuthProblem(w http.ResponseWriter, r *http.Request, apiErr *response.Error) {
+	status, code := http.StatusBadRequest, oauthInvalidRequest
+
+	switch apiErr.Code {
+	case response.CodeInvalidClient:
+		status, code = http.StatusUnauthorized, oauthInvalidClient
+		w.Header().Set("WWW-Authenticate", `Basic realm="auth-medods"`)
+	case response.CodeInvalidRefreshToken, response.CodeTokenReused, response.CodeRefreshLocked,
+		response.CodeUserInactive, response.CodeUserNotFound:
+		code = oauthInvalidGrant
+	case response.CodeInvalidScope:
+		code = oauthInvalidScope
+	case response.CodeInvalidAudience:
+		code = oauthInvalidTarget
+	case response.CodeInvalidDPoPProof:
+		code = oauthInvalidDPoPProof
+	case response.CodeRateLimited, response.CodeIPBlocked:
+		status, code = http.StatusTooManyRequests, oauthTemporarilyUnavailable
+	case response.CodeInternal:
+		status, code = http.StatusInternalServerError, oauthServerError
+	}
+
+	if apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(apiErr.RetryAfter)))
+	}
+	// В метриках остаётся исходный код ошибки, он точнее кода RFC 6749
+	setFailure(r, string(apiErr.Code))
+	writeOAuthError(w, r, status, code, apiErr.Detail)
+}
+
+func writeOAuthError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	setFailure(r, code)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response.OAuthError{Error: code, ErrorDescription: description}); err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to encode oauth error")
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/dpop"
+)
+
+// OIDC - настройки OpenID Connect. Issuer - внешний адрес сервиса, от него
+// строятся адреса эндпоинтов в метаданных
+type OIDC struct {
+	Issuer string
+	Signer *jwt.IDTokenSigner
+	// AuthMethods - способы аутентификации клиентов в терминах OAuth
+	// (client_secret_basic, tls_client_auth, ...)
+	AuthMethods []string
+}
+
+// DiscoveryHandler - хендлер /.well-known/openid-configuration
+func DiscoveryHandler(cfg *OIDC) http.HandlerFunc {
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	doc := response.OpenIDConfiguration{
+		Issuer:                            issuer,
+		TokenEndpoint:                     issuer + APIPrefix + "/oauth/token",
+		UserInfoEndpoint:                  issuer + APIPrefix + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   []string{ScopeOpenID},
+		GrantTypesSupported:               []string{GrantRefreshToken, GrantClientCredentials, GrantGUID},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: cfg.AuthMethods,
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "email"},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+// JWKSHandler публикует открытый ключ, которым клиенты проверяют ID токены
+func JWKSHandler(cfg *OIDC) http.HandlerFunc {
+	n, e := jwt.EncodeRSAPublicKey(cfg.Signer.PublicKey())
+	keys := response.JWKS{Keys: []response.JWK{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     cfg.Signer.KeyID(),
+		N:         n,
+		E:         e,
+	}}}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		writeJSON(w, http.StatusOK, keys)
+	}
+}
+
+// UserInfoHandler возвращает сведения о пользователе по access токену из
+// заголовка Authorization: Bearer (RFC 6750) или, для привязанного к ключу
+// токена, Authorization: DPoP вместе с proof (RFC 9449)
+func UserInfoHandler(db database.DBInterface, ownKey string, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if token == "" || (scheme != "Bearer" && scheme != "DPoP") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="auth-medods"`)
+			writeError(w, r, response.CodeInvalidToken, "access token is required")
+			return
+		}
+
+		claims, err := jwt.ParseAccessToken(token, ownKey, o.previousKeys...)
+		if err != nil {
+			requestLog(r).Info().Err(err).Msg("userinfo token is invalid")
+			rejectBearer(w, r, "invalid access token")
+			return
+		}
+
+		if detail := o.checkDPoP(r, scheme, token, claims.JKT); detail != "" {
+			rejectBearer(w, r, detail)
+			return
+		}
+
+		// У токена клиента (client_credentials) нет пользователя
+		if claims.GUID == "" {
+			rejectBearer(w, r, "token is not issued to a user")
+			return
+		}
+
+		// UserInfo отдаёт сведения только токену с областью openid (OpenID Connect Core 5.3)
+		if !slices.Contains(claims.Scope, ScopeOpenID) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="auth-medods", error="insufficient_scope", scope="`+ScopeOpenID+`"`)
+			writeError(w, r, response.CodeInsufficientScope, "access token requires the openid scope")
+			return
+		}
+
+		revoked, err := db.IsTokenRevoked(r.Context(), claims.ID)
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to check token denylist")
+			writeError(w, r, response.CodeInternal, "failed to check token")
+			return
+		}
+		if revoked {
+			rejectBearer(w, r, "access token has been revoked")
+			return
+		}
+
+		user, err := db.GetUserByGUID(r.Context(), claims.GUID)
+		if errors.Is(err, database.ErrUserNotFound) {
+			rejectBearer(w, r, "invalid access token")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to get user")
+			writeError(w, r, response.CodeInternal, "failed to get user")
+			return
+		}
+
+		if apiErr := inactiveError(r, user); apiErr != nil {
+			writeProblem(w, r, apiErr)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, response.UserInfo{
+			Subject: user.UserGUID.String(),
+			Email:   user.Email,
+		})
+	}
+}
+
+// checkDPoP проверяет, что привязанный к ключу токен предъявлен по схеме DPoP
+// с proof этого ключа, а обычный - по схеме Bearer. Возвращает причину отказа
+func (o *options) checkDPoP(r *http.Request, scheme, token, jkt string) string {
+	if jkt == "" {
+		if scheme != "Bearer" {
+			return "access token is not bound to a DPoP key"
+		}
+		return ""
+	}
+
+	if scheme != "DPoP" || o.dpop == nil {
+		return "access token requires a DPoP proof"
+	}
+	proofKey, err := o.dpop.Verify(r, token)
+	if err != nil && !errors.Is(err, dpop.ErrInvalidProof) {
+		// Без проверки одноразовости proof не принимается
+		requestLog(r).Error().Err(err).Msg("failed to check userinfo DPoP proof")
+		return "invalid DPoP proof"
+	}
+	if err != nil {
+		requestLog(r).Info().Err(err).Msg("userinfo DPoP proof is invalid")
+		return "invalid DPoP proof"
+	}
+	if proofKey != jkt {
+		return "DPoP proof key does not match the access token"
+	}
+	return ""
+}
+
+func rejectBearer(w http.ResponseWriter, r *http.Request, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="auth-medods", error="invalid_token"`)
+	writeError(w, r, response.CodeInvalidToken, detail)
+}
+
+// idToken выдаёт пользователю ID токен. Получатель (aud) - клиент, запросивший
+// токены, а без аутентификации клиентов - сам сервис
+func (ti *tokenIssuer) idToken(r *http.Request, user *models.User, clientID, nonce string) (string, *response.Error) {
+	cfg := ti.o.oidc
+
+	audience := clientID
+	if audience == "" {
+		audience = strings.TrimSuffix(cfg.Issuer, "/")
+	}
+
+	token, err := cfg.Signer.Sign(jwt.IDTokenClaims{
+		Issuer:   strings.TrimSuffix(cfg.Issuer, "/"),
+		Subject:  user.UserGUID.String(),
+		Audience: audience,
+		Email:    user.Email,
+		Nonce:    nonce,
+		AuthTime: user.AuthTime,
+		TTL:      ti.tokenTTL,
+	})
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to sign id token")
+		return "", &response.Error{Code: response.CodeInternal, Detail: "failed to generate tokens", Err: err}
+	}
+
+	return token, nil
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/openapi"
+)
+
+// APIPrefix - префикс текущей версии API. Те же маршруты без префикса остаются
+// для старых клиентов и помечаются заголовком Deprecation
+const APIPrefix = "/api/v1"
+
+// Типы содержимого тел запросов и ответов
+const (
+	contentJSON    = "application/json"
+	contentProblem = "application/problem+json"
+	contentForm    = "application/x-www-form-urlencoded"
+)
+
+// APIDocument описывает API версии v1 в формате OpenAPI 3. Пути указаны
+// относительно APIPrefix; маршруты OpenID Connect и административный API
+// доступны, только если включены при запуске
+func APIDocument() *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:   "auth-medods",
+		Version: "1.0.0",
+		Description: "Сервис аутентификации: выдача и обновление access/refresh токенов, " +
+			"OAuth 2.0 token endpoint, OpenID Connect и административный API",
+	})
+	d.Servers = []openapi.Server{{URL: APIPrefix}}
+	d.Components.SecuritySchemes["client"] = openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "basic",
+		Description: "client_id и секрет клиента; другие способы задаются CLIENT_AUTH",
+	}
+	d.Components.SecuritySchemes["bearer"] = openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "access токен; привязанный к ключу токен передаётся по схеме DPoP",
+	}
+	d.Components.SecuritySchemes["admin"] = openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "ADMIN_TOKEN",
+	}
+
+	client := []map[string][]string{{"client": {}}}
+	admin := []map[string][]string{{"admin": {}}}
+	problem := problemResponses(d)
+	str := &openapi.Schema{Type: "string"}
+	integer := &openapi.Schema{Type: "integer"}
+	dpopHeader := openapi.Parameter{Name: "DPoP", In: "header", Schema: str,
+		Description: "DPoP proof (RFC 9449), привязывает токены к ключу клиента"}
+	deliveryHeader := openapi.Parameter{Name: TokenDeliveryHeader, In: "header", Schema: str,
+		Description: "значение cookie выдаёт refresh токен в cookie вместо тела (при TOKEN_COOKIES)"}
+
+	d.Add(http.MethodGet, "/access", &openapi.Operation{
+		OperationID: "issueTokens",
+		Summary:     "Выдать пару токенов пользователю по GUID",
+		Tags:        []string{"tokens"},
+		Parameters: []openapi.Parameter{
+			{Name: "guid", In: "query", Required: true, Schema: str},
+			{Name: "scope", In: "query", Schema: str, Description: "области доступа через пробел"},
+			{Name: "audience", In: "query", Schema: str, Description: "получатели токена через пробел"},
+			{Name: "nonce", In: "query", Schema: str, Description: "копируется в ID токен"},
+			dpopHeader,
+			deliveryHeader,
+		},
+		Responses: problem(map[string]openapi.Response{
+			"200": jsonResponse(d, "Пара токенов", response.UserResponse{}),
+		}, "400", "401", "403", "404", "429", "500"),
+		Security: client,
+	})
+
+	d.Add(http.MethodPost, "/refresh", &openapi.Operation{
+		OperationID: "refreshTokens",
+		Summary:     "Обменять refresh токен на новую пару",
+		Tags:        []string{"tokens"},
+		Parameters: []openapi.Parameter{
+			dpopHeader,
+			{Name: CSRFHeader, In: "header", Schema: str, Description: "CSRF токен при refresh по cookie"},
+			deliveryHeader,
+		},
+		RequestBody: &openapi.RequestBody{Content: map[string]openapi.MediaType{
+			contentJSON: {Schema: d.Schema(response.RefreshToken{})},
+		}},
+		Responses: problem(map[string]openapi.Response{
+			"200": jsonResponse(d, "Новая пара токенов", response.UserResponse{}),
+		}, "400", "401", "403", "413", "429", "500"),
+	})
+
+	oauthError := map[string]openapi.MediaType{contentJSON: {Schema: d.Schema(response.OAuthError{})}}
+	d.Add(http.MethodPost, "/oauth/token", &openapi.Operation{
+		OperationID: "oauthToken",
+		Summary:     "OAuth 2.0 token endpoint",
+		Tags:        []string{"oauth"},
+		Parameters:  []openapi.Parameter{dpopHeader},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			contentForm: {Schema: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"grant_type": {Type: "string", Enum: []string{
+						GrantRefreshToken, GrantClientCredentials, GrantGUID}},
+					"refresh_token": str,
+					"guid":          str,
+					"scope":         str,
+					"audience":      str,
+					"nonce":         str,
+					"client_id":     str,
+					"client_secret": str,
+				},
+				Required: []string{"grant_type"},
+			}},
+		}},
+		Responses: map[string]openapi.Response{
+			"200": jsonResponse(d, "Выданные токены", response.TokenResponse{}),
+			"400": {Description: "Ошибка запроса (RFC 6749, раздел 5.2)", Content: oauthError},
+			"401": {Description: "Клиент не прошёл аутентификацию", Content: oauthError},
+			"413": {Description: "Тело запроса больше допустимого", Content: oauthError},
+			"429": {Description: "Превышен лимит запросов (temporarily_unavailable)", Content: oauthError},
+			"500": {Description: "Внутренняя ошибка", Content: oauthError},
+		},
+		Security: client,
+	})
+
+	userInfo := func(id string) *openapi.Operation {
+		return &openapi.Operation{
+			OperationID: id,
+			Summary:     "Сведения о пользователе по access токену (OpenID Connect)",
+			Tags:        []string{"oidc"},
+			Parameters:  []openapi.Parameter{dpopHeader},
+			Responses: problem(map[string]openapi.Response{
+				"200": jsonResponse(d, "Сведения о пользователе", response.UserInfo{}),
+			}, "401", "403", "500"),
+			Security: []map[string][]string{{"bearer": {}}},
+		}
+	}
+	d.Add(http.MethodGet, "/userinfo", userInfo("getUserInfo"))
+	d.Add(http.MethodPost, "/userinfo", userInfo("postUserInfo"))
+
+	guid := openapi.Parameter{Name: "guid", In: "path", Required: true, Schema: str}
+	clientID := openapi.Parameter{Name: "client_id", In: "path", Required: true, Schema: str}
+	page := []openapi.Parameter{
+		{Name: "limit", In: "query", Schema: integer},
+		{Name: "offset", In: "query", Schema: integer},
+	}
+	noContent := map[string]openapi.Response{"204": {Description: "Выполнено"}}
+
+	adminOp := func(id, summary string, params []openapi.Parameter, body any,
+		ok map[string]openapi.Response, errors ...string) *openapi.Operation {
+		op := &openapi.Operation{
+			OperationID: id,
+			Summary:     summary,
+			Tags:        []string{"admin"},
+			Parameters:  params,
+			Responses:   problem(ok, append([]string{"401", "500"}, errors...)...),
+			Security:    admin,
+		}
+		if body != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+				contentJSON: {Schema: d.Schema(body)},
+			}}
+		}
+		return op
+	}
+	user := map[string]openapi.Response{"200": jsonResponse(d, "Пользователь", response.User{})}
+	status := []openapi.Parameter{guid}
+
+	d.Add(http.MethodPost, "/admin/users", adminOp("createUser", "Создать пользователя", nil,
+		response.CreateUser{}, map[string]openapi.Response{
+			"201": jsonResponse(d, "Созданный пользователь", response.User{}),
+		}, "400", "409"))
+	d.Add(http.MethodGet, "/admin/users", adminOp("listUsers", "Список пользователей", page, nil,
+		map[string]openapi.Response{"200": jsonResponse(d, "Страница пользователей", response.UserList{})}, "400"))
+	d.Add(http.MethodGet, "/admin/users/{guid}", adminOp("getUser", "Пользователь по GUID",
+		status, nil, user, "400", "404"))
+	d.Add(http.MethodPatch, "/admin/users/{guid}", adminOp("updateUser", "Изменить пользователя",
+		status, response.UpdateUser{}, user, "400", "404"))
+	d.Add(http.MethodDelete, "/admin/users/{guid}", adminOp("deleteUser", "Удалить пользователя",
+		status, nil, noContent, "400", "404"))
+	d.Add(http.MethodPost, "/admin/users/{guid}/lock", adminOp("lockUser", "Заблокировать пользователя",
+		status, response.UserStatusChange{}, user, "400", "404"))
+	d.Add(http.MethodPost, "/admin/users/{guid}/disable", adminOp("disableUser", "Отключить пользователя",
+		status, response.UserStatusChange{}, user, "400", "404"))
+	d.Add(http.MethodPost, "/admin/users/{guid}/enable", adminOp("enableUser", "Разблокировать пользователя",
+		status, nil, user, "400", "404"))
+	d.Add(http.MethodGet, "/admin/users/{guid}/lockout", adminOp("getLockout",
+		"Состояние защиты от подбора refresh токена", status, nil, map[string]openapi.Response{
+			"200": jsonResponse(d, "Счётчики неудачных попыток", response.RefreshLockouts{}),
+		}, "400", "404"))
+	d.Add(http.MethodDelete, "/admin/users/{guid}/lockout", adminOp("clearLockout",
+		"Снять блокировку refresh", status, nil, noContent, "400", "404"))
+
+	client200 := map[string]openapi.Response{"200": jsonResponse(d, "Клиент", response.Client{})}
+	d.Add(http.MethodPost, "/admin/clients", adminOp("createClient", "Зарегистрировать клиента", nil,
+		response.CreateClient{}, map[string]openapi.Response{
+			"201": jsonResponse(d, "Клиент с секретом", response.Client{}),
+		}, "400"))
+	d.Add(http.MethodGet, "/admin/clients", adminOp("listClients", "Список клиентов", nil, nil,
+		map[string]openapi.Response{"200": jsonResponse(d, "Клиенты", response.ClientList{})}))
+	d.Add(http.MethodGet, "/admin/clients/{client_id}", adminOp("getClient", "Клиент по client_id",
+		[]openapi.Parameter{clientID}, nil, client200, "404"))
+	d.Add(http.MethodPatch, "/admin/clients/{client_id}", adminOp("updateClient", "Изменить клиента",
+		[]openapi.Parameter{clientID}, response.UpdateClient{}, client200, "400", "404"))
+	d.Add(http.MethodDelete, "/admin/clients/{client_id}", adminOp("deleteClient", "Удалить клиента",
+		[]openapi.Parameter{clientID}, nil, noContent, "404"))
+	d.Add(http.MethodPost, "/admin/clients/{client_id}/secret", adminOp("rotateClientSecret",
+		"Выдать клиенту новый секрет", []openapi.Parameter{clientID}, nil, client200, "404"))
+
+	introspect := adminOp("introspectToken", "Проверить access токен", nil, nil, map[string]openapi.Response{
+		"200": jsonResponse(d, "Результат проверки", response.Introspection{}),
+	}, "400")
+	introspect.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+		contentForm: {Schema: &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{"token": str},
+			Required:   []string{"token"},
+		}},
+	}}
+	d.Add(http.MethodPost, "/admin/introspect", introspect)
+
+	d.Add(http.MethodGet, "/admin/audit", adminOp("listAuditEvents", "Журнал аудита", append([]openapi.Parameter{
+		{Name: "guid", In: "query", Schema: str},
+		{Name: "type", In: "query", Schema: str, Description: "типы событий через запятую"},
+		{Name: "from", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "to", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	}, page...), nil, map[string]openapi.Response{
+		"200": jsonResponse(d, "Страница журнала", response.AuditEvents{}),
+	}, "400"))
+
+	return d
+}
+
+// OpenAPIHandler отдаёт описание API в формате JSON
+func OpenAPIHandler(doc *openapi.Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+func jsonResponse(d *openapi.Document, description string, v any) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Content:     map[string]openapi.MediaType{contentJSON: {Schema: d.Schema(v)}},
+	}
+}
+
+// problemResponses возвращает функцию, которая дополняет успешные ответы операции
+// ошибками в формате RFC 7807 для перечисленных статусов
+func problemResponses(d *openapi.Document) func(ok map[string]openapi.Response, statuses ...string) map[string]openapi.Response {
+	content := map[string]openapi.MediaType{contentProblem: {Schema: d.Schema(response.Problem{})}}
+
+	return func(ok map[string]openapi.Response, statuses ...string) map[string]openapi.Response {
+		responses := make(map[string]openapi.Response, len(ok)+len(statuses))
+		for status, resp := range ok {
+			responses[status] = resp
+		}
+		for _, status := range statuses {
+			responses[status] = openapi.Response{Description: problemDescriptions[status], Content: content}
+		}
+		return responses
+	}
+}
+
+var problemDescriptions = map[string]string{
+	"400": "Некорректный запрос",
+	"401": "Нет или неверные учётные данные",
+	"403": "Доступ запрещён",
+	"404": "Не найдено",
+	"409": "Конфликт",
+	"413": "Тело запроса больше допустимого",
+	"429": "Превышен лимит запросов",
+	"500": "Внутренняя ошибка",
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/dpop"
+	"github.com/volchok96/auth-medods/internal/health"
+	"github.com/volchok96/auth-medods/internal/lockout"
+	"github.com/volchok96/auth-medods/internal/metrics"
+	"github.com/volchok96/auth-medods/internal/notify"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// Option настраивает необязательные зависимости хендлеров
+type Option func(*options)
+
+type options struct {
+	audit      *audit.Recorder
+	lockout    *lockout.Guard
+	notifier   notify.Notifier
+	clientAuth clientauth.Authenticator
+	oidc       *OIDC
+	dpop       *dpop.Verifier
+	cookies    *Cookies
+	readiness  *health.Checker
+	proxies    *ip.Resolver
+	// previousKeys - ключи подписи до смены OWN_KEY
+	previousKeys []string
+	// internalListener - /admin и /metrics обслуживает SetupInternalRoutes
+	internalListener bool
+	debugEndpoints   bool
+}
+
+// WithAudit включает запись событий в журнал аудита
+func WithAudit(rec *audit.Recorder) Option {
+	return func(o *options) {
+		o.audit = rec
+	}
+}
+
+// WithLockout включает блокировку refresh после серии неудачных попыток
+func WithLockout(guard *lockout.Guard) Option {
+	return func(o *options) {
+		o.lockout = guard
+	}
+}
+
+// WithNotifier задаёт, как пользователю доставляются предупреждения о безопасности
+func WithNotifier(n notify.Notifier) Option {
+	return func(o *options) {
+		o.notifier = n
+	}
+}
+
+// WithClientAuth требует, чтобы токены запрашивал зарегистрированный клиент
+func WithClientAuth(auth clientauth.Authenticator) Option {
+	return func(o *options) {
+		o.clientAuth = auth
+	}
+}
+
+// WithOIDC включает выдачу ID токенов OpenID Connect; nil оставляет её выключенной
+func WithOIDC(cfg *OIDC) Option {
+	return func(o *options) {
+		o.oidc = cfg
+	}
+}
+
+// WithDPoP включает привязку токенов к ключу клиента по DPoP proof (RFC 9449)
+func WithDPoP(verifier *dpop.Verifier) Option {
+	return func(o *options) {
+		o.dpop = verifier
+	}
+}
+
+// WithCookies выдаёт браузерным клиентам refresh токен в HttpOnly cookie; nil оставляет
+// его в теле ответа
+func WithCookies(cfg *Cookies) Option {
+	return func(o *options) {
+		o.cookies = cfg
+	}
+}
+
+// WithReadiness задаёт проверки готовности для /readyz; без него сервис готов,
+// пока обрабатывает запросы
+func WithReadiness(checker *health.Checker) Option {
+	return func(o *options) {
+		o.readiness = checker
+	}
+}
+
+// WithTrustedProxies задаёт прокси, чьему X-Forwarded-For можно верить; без него
+// адресом клиента считается адрес соединения
+func WithTrustedProxies(resolver *ip.Resolver) Option {
+	return func(o *options) {
+		o.proxies = resolver
+	}
+}
+
+// WithInternalListener убирает административный API и /metrics из основных
+// маршрутов: их обслуживает отдельный listener с SetupInternalRoutes
+func WithInternalListener() Option {
+	return func(o *options) {
+		o.internalListener = true
+	}
+}
+
+// WithDebugEndpoints включает отладочный разбор токенов /debug/token. Он показывает
+// сессию владельца токена, поэтому доступен только с ADMIN_TOKEN и предназначен для разработки
+func WithDebugEndpoints() Option {
+	return func(o *options) {
+		o.debugEndpoints = true
+	}
+}
+
+// WithPreviousKeys принимает access токены, подписанные ключами до смены OWN_KEY;
+// пустые ключи пропускаются
+func WithPreviousKeys(keys ...string) Option {
+	return func(o *options) {
+		for _, key := range keys {
+			if key != "" {
+				o.previousKeys = append(o.previousKeys, key)
+			}
+		}
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// warn отправляет пользователю предупреждение. Ошибка доставки не прерывает
+// обработку запроса
+func (o *options) warn(r *http.Request, kind, email, subject, body string) {
+	if o.notifier == nil || email == "" {
+		return
+	}
+
+	_, span := tracing.Start(r.Context(), "notify.Notify",
+		trace.WithAttributes(attribute.String("notify.kind", kind)))
+	err := o.notifier.Notify(email, subject, body)
+	tracing.End(span, err)
+	if err != nil {
+		metrics.Notifications.WithLabelValues(kind, "failed").Inc()
+		requestLog(r).Error().
+			Err(err).
+			Msg("failed to send message to email")
+		return
+	}
+	metrics.Notifications.WithLabelValues(kind, "sent").Inc()
+}
+
+// authenticateClient определяет клиента запроса. Если аутентификация клиентов
+// не включена, возвращает nil без ошибки
+func (o *options) authenticateClient(r *http.Request) (*models.Client, *response.Error) {
+	if o.clientAuth == nil {
+		return nil, nil
+	}
+
+	client, err := o.clientAuth.Authenticate(r)
+	if err == nil {
+		return client, nil
+	}
+
+	if errors.Is(err, clientauth.ErrNoCredentials) || errors.Is(err, clientauth.ErrInvalidCredentials) {
+		requestLog(r).Warn().Err(err).Msg("client authentication failed")
+		return nil, &response.Error{Code: response.CodeInvalidClient, Detail: "client authentication failed", Err: err}
+	}
+
+	requestLog(r).Error().Err(err).Msg("failed to authenticate client")
+	return nil, &response.Error{Code: response.CodeInternal, Detail: "failed to authenticate client", Err: err}
+}
+
+// dpopKey проверяет DPoP proof запроса и возвращает отпечаток ключа клиента.
+// Без proof или без включённого DPoP возвращает пустую строку
+func (o *options) dpopKey(r *http.Request) (string, *response.Error) {
+	if o.dpop == nil {
+		return "", nil
+	}
+
+	jkt, err := o.dpop.Verify(r, "")
+	if errors.Is(err, dpop.ErrNoProof) {
+		return "", nil
+	}
+	if err != nil && !errors.Is(err, dpop.ErrInvalidProof) {
+		requestLog(r).Error().Err(err).Msg("failed to check DPoP proof")
+		return "", &response.Error{Code: response.CodeInternal, Detail: "failed to check DPoP proof", Err: err}
+	}
+	if err != nil {
+		requestLog(r).Warn().Err(err).Msg("invalid DPoP proof")
+		return "", &response.Error{Code: response.CodeInvalidDPoPProof, Detail: "invalid DPoP proof", Err: err}
+	}
+
+	return jkt, nil
+}
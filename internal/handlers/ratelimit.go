@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/metrics"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+// maxPeekBody - сколько байт тела запроса читается, чтобы узнать GUID для лимита
+const maxPeekBody = 64 << 10
+
+// rateLimitRule - лимит по одному признаку запроса. Пустой ключ правило пропускает.
+// code - код ошибки при исчерпании лимита, по умолчанию rate_limited
+type rateLimitRule struct {
+	name  string
+	key   func(r *http.Request) string
+	limit ratelimit.Limit
+	code  response.ErrorCode
+}
+
+// ipRule - лимит по IP клиента; при его исчерпании адрес временно блокируется
+func ipRule(limit ratelimit.Limit) rateLimitRule {
+	return rateLimitRule{name: "ip", key: ip.FromRequest, limit: limit, code: response.CodeIPBlocked}
+}
+
+// problemWriter отвечает клиенту ошибкой в формате маршрута
+type problemWriter func(w http.ResponseWriter, r *http.Request, apiErr *response.Error)
+
+// AccessRateLimit ограничивает /access по IP клиента и по GUID из строки запроса
+func AccessRateLimit(limiter *ratelimit.Limiter, limits ratelimit.RouteLimits) func(http.Handler) http.Handler {
+	return rateLimit(limiter, "access", writeProblem,
+		ipRule(limits.IP),
+		rateLimitRule{name: "guid", key: queryGUID, limit: limits.GUID},
+	)
+}
+
+// RefreshRateLimit ограничивает /refresh по IP клиента и по GUID из тела запроса
+func RefreshRateLimit(limiter *ratelimit.Limiter, limits ratelimit.RouteLimits) func(http.Handler) http.Handler {
+	return rateLimit(limiter, "refresh", writeProblem,
+		ipRule(limits.IP),
+		rateLimitRule{name: "guid", key: bodyGUID, limit: limits.GUID},
+	)
+}
+
+// TokenRateLimit ограничивает /oauth/token: grant refresh_token подчиняется лимитам
+// /refresh, остальные grant - лимитам /access. GUID берётся из формы или из refresh токена.
+// Отказ, как и остальные ошибки token endpoint, возвращается в формате RFC 6749
+func TokenRateLimit(limiter *ratelimit.Limiter, cfg ratelimit.Config) func(http.Handler) http.Handler {
+	access := rateLimit(limiter, "token", writeOAuthProblem,
+		ipRule(cfg.Access.IP),
+		rateLimitRule{name: "guid", key: formGUID, limit: cfg.Access.GUID},
+	)
+	refresh := rateLimit(limiter, "token_refresh", writeOAuthProblem,
+		ipRule(cfg.Refresh.IP),
+		rateLimitRule{name: "guid", key: formGUID, limit: cfg.Refresh.GUID},
+	)
+
+	return func(next http.Handler) http.Handler {
+		accessNext, refreshNext := access(next), refresh(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if peekForm(r).Get("grant_type") == GrantRefreshToken {
+				refreshNext.ServeHTTP(w, r)
+				return
+			}
+			accessNext.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimit проверяет правила по порядку и отклоняет запрос на первом исчерпанном
+// лимите, не расходуя запас следующих, и отвечает через write. Ошибка хранилища не
+// блокирует выдачу токенов
+func rateLimit(limiter *ratelimit.Limiter, route string, write problemWriter,
+	rules ...rateLimitRule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var (
+				tightest ratelimit.Result
+				applied  bool
+			)
+
+			for _, rule := range rules {
+				if !rule.limit.Enabled() {
+					continue
+				}
+				value := rule.key(r)
+				if value == "" {
+					continue
+				}
+
+				res, err := limiter.Allow(r.Context(), route+":"+rule.name+":"+value, rule.limit)
+				if err != nil {
+					requestLog(r).Error().Err(err).Str("route", route).Str("key", rule.name).Msg("rate limit check failed")
+					metrics.RateLimitRequests.WithLabelValues(route, rule.name, "error").Inc()
+					continue
+				}
+
+				if !res.Allowed {
+					metrics.RateLimitRequests.WithLabelValues(route, rule.name, "limited").Inc()
+					requestLog(r).Warn().Str("route", route).Str("key", rule.name).Str("value", value).Msg("rate limit exceeded")
+
+					setRateLimitHeaders(w, res)
+					code := rule.code
+					if code == "" {
+						code = response.CodeRateLimited
+					}
+					apiErr := response.NewError(code,
+						fmt.Sprintf("too many requests, retry in %d seconds", ceilSeconds(res.RetryAfter)))
+					apiErr.RetryAfter = res.RetryAfter
+					write(w, r, apiErr)
+					return
+				}
+
+				metrics.RateLimitRequests.WithLabelValues(route, rule.name, "allowed").Inc()
+				if !applied || res.Remaining < tightest.Remaining {
+					tightest = res
+					applied = true
+				}
+			}
+
+			if applied {
+				setRateLimitHeaders(w, tightest)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders выставляет заголовки RateLimit-* по самому строгому из лимитов
+func setRateLimitHeaders(w http.ResponseWriter, res ratelimit.Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// limitGUID приводит GUID к каноническому виду, чтобы разные записи одного GUID
+// (регистр, фигурные скобки, urn:uuid:) попадали в одну корзину. Не GUID - пустой ключ
+func limitGUID(s string) string {
+	guid, err := uuid.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return guid.String()
+}
+
+// refreshGUID возвращает GUID из префикса refresh токена, закодированного или нет
+func refreshGUID(refresh string) string {
+	decoded, ok := jwt.DecodeRefreshToken(refresh)
+	if !ok {
+		return ""
+	}
+	guid, _ := jwt.RefreshTokenGUID(string(decoded))
+	return limitGUID(guid)
+}
+
+func queryGUID(r *http.Request) string {
+	return limitGUID(r.URL.Query().Get("guid"))
+}
+
+// bodyGUID читает GUID из JSON тела. Без токена в теле GUID берётся из refresh cookie,
+// как это делает RefreshHandler
+func bodyGUID(r *http.Request) string {
+	var body response.RefreshToken
+	if peeked, ok := peekBody(r); ok && len(bytes.TrimSpace(peeked)) > 0 {
+		if err := json.Unmarshal(peeked, &body); err != nil {
+			return ""
+		}
+	}
+	if body.GUID != "" {
+		return limitGUID(body.GUID)
+	}
+	if body.RefreshToken != "" {
+		return ""
+	}
+
+	cookie, err := r.Cookie(RefreshCookie)
+	if err != nil {
+		return ""
+	}
+	return refreshGUID(cookie.Value)
+}
+
+// formGUID читает GUID из формы /oauth/token: параметр guid или префикс refresh токена
+func formGUID(r *http.Request) string {
+	form := peekForm(r)
+	if guid := form.Get("guid"); guid != "" {
+		return limitGUID(guid)
+	}
+	return refreshGUID(form.Get("refresh_token"))
+}
+
+// peekForm разбирает form-encoded тело, не расходуя r.Body
+func peekForm(r *http.Request) url.Values {
+	peeked, ok := peekBody(r)
+	if !ok {
+		return url.Values{}
+	}
+
+	form, err := url.ParseQuery(string(peeked))
+	if err != nil {
+		return url.Values{}
+	}
+	return form
+}
+
+// peekBody читает начало тела запроса и возвращает прочитанное обратно в r.Body
+func peekBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil {
+		return nil, false
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	return peeked, err == nil
+}
@@ -3,143 +3,145 @@ package handlers
 import (
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"net/http"
 	"time"
 
-	"github.com/rs/zerolog/log"
-	"gopkg.in/mail.v2"
-
+	"github.com/volchok96/auth-medods/internal/audit"
 	"github.com/volchok96/auth-medods/internal/database"
 	"github.com/volchok96/auth-medods/internal/domain/api/response"
 	"github.com/volchok96/auth-medods/internal/domain/ip"
 	"github.com/volchok96/auth-medods/internal/domain/jwt"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func RefreshHandler(db database.DBInterface, ownKey string, tokenTTL time.Duration) http.HandlerFunc {
+func RefreshHandler(db database.DBInterface, ownKey string, tokenTTL time.Duration, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+	issuer := &tokenIssuer{db: db, ownKey: ownKey, tokenTTL: tokenTTL, o: o}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var resp response.RefreshToken
-		if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
-			log.Error().Err(err).Msg("failed to decode body")
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		err := json.NewDecoder(r.Body).Decode(&resp)
+		// В режиме cookie тело можно не передавать: токен придёт в cookie
+		if bodyTooLarge(err) {
+			writeError(w, r, response.CodeRequestTooLarge, "request body is too large")
 			return
 		}
+		if err != nil && !(o.cookies != nil && errors.Is(err, io.EOF)) {
+			requestLog(r).Error().Err(err).Msg("failed to decode body")
+			writeError(w, r, response.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		fromCookie := false
+		if resp.RefreshToken == "" && o.cookies != nil {
+			token, apiErr := o.cookies.refreshToken(r)
+			if apiErr != nil {
+				requestLog(r).Warn().Msg("refresh cookie without a matching CSRF token")
+				writeProblem(w, r, apiErr)
+				return
+			}
+			resp.RefreshToken, fromCookie = token, token != ""
+		}
+		// Пользователь refresh токена из cookie определяется по GUID в начале токена
+		if resp.GUID == "" && fromCookie {
+			if decoded, ok := jwt.DecodeRefreshToken(resp.RefreshToken); ok {
+				resp.GUID, _ = jwt.RefreshTokenGUID(string(decoded))
+			}
+		}
 
-		log.Info().
+		requestLog(r).Info().
 			Str("GUID", resp.GUID).
-			Str("RefreshToken", resp.RefreshToken).
+			Bool("from_cookie", fromCookie).
 			Msg("Received refresh token request")
 
-		if len(resp.RefreshToken) == 0 {
-			log.Error().Msg("Refresh token is empty")
-			http.Error(w, "refresh token is required", http.StatusBadRequest)
-			return
+		fail := func(details string) {
+			o.audit.Record(r, audit.Event{
+				Type:     audit.EventTokenRefreshed,
+				Actor:    audit.ActorUser(resp.GUID),
+				UserGUID: resp.GUID,
+				Outcome:  audit.OutcomeFailure,
+				Details:  details,
+			})
 		}
 
-		user, err := db.GetUserByGUID(resp.GUID)
-		if err != nil {
-			log.Error().Err(err).Msg("user not found or invalid refresh token")
-			http.Error(w, "permission denied", http.StatusUnauthorized)
+		if len(resp.RefreshToken) == 0 {
+			requestLog(r).Error().Msg("Refresh token is empty")
+			writeError(w, r, response.CodeRefreshTokenRequired, "refresh token is required")
 			return
 		}
 
-		clientIP := ip.GetIp(r)
-		if clientIP == "" {
-			log.Error().Msg("failed to get IP")
-			http.Error(w, "failed to get IP", http.StatusInternalServerError)
+		// Клиент предъявляет те же учётные данные, что и при выдаче токена через /access
+		client, apiErr := o.authenticateClient(r)
+		if apiErr != nil {
+			fail("client authentication failed")
+			writeClientError(w, r, apiErr)
 			return
 		}
 
-		// Email notification when IP changes
-		if user.IP != clientIP {
-			log.Warn().
-				Str("User email", user.Email).
-				Str("Old IP", user.IP).
-				Str("New IP", clientIP).
-				Msg("IP address changed")
-
-			emailBody := fmt.Sprintf("Query from a new IP address (%s). Was it you?", clientIP)
-			if err := emailWarning(user.Email, emailBody); err != nil {
-				log.Error().
-					Err(err).
-					Msg("failed to send message to email")
-			}
-		}
-
-		// Decode the token
-		log.Info().Str("RefreshToken", resp.RefreshToken).Msg("Decoding refresh token")
-
-		decodedToken, err := base64.StdEncoding.DecodeString(resp.RefreshToken)
+		user, err := db.GetUserByGUID(r.Context(), resp.GUID)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to decode refresh token")
-			http.Error(w, "invalid refresh token", http.StatusBadRequest)
+			requestLog(r).Error().Err(err).Msg("user not found or invalid refresh token")
+			fail("user not found")
+			writeError(w, r, response.CodeInvalidRefreshToken, "permission denied")
 			return
 		}
 
-		if len(decodedToken) == 0 {
-			log.Error().Msg("decoded token is empty")
-			http.Error(w, "invalid refresh token", http.StatusBadRequest)
+		clientIP := ip.FromRequest(r)
+		if clientIP == "" {
+			requestLog(r).Error().Msg("failed to get IP")
+			writeError(w, r, response.CodeInternal, "failed to get IP")
 			return
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(user.HashedRefreshToken), decodedToken)
-		if err != nil {
-			log.Error().Err(err).Msg("invalid refresh token")
-			http.Error(w, "invalid refresh token", http.StatusUnauthorized)
-			return
+		// Декодируем токен; повреждённый считается неудачной попыткой
+		decodedToken, ok := jwt.DecodeRefreshToken(resp.RefreshToken)
+		if !ok {
+			requestLog(r).Error().Msg("failed to decode refresh token")
 		}
 
-		newAccessToken, newRefreshToken, newRefreshTokenHash, err := jwt.NewTokens(user.UserGUID.String(), ownKey, clientIP, int(tokenTTL.Hours()))
-		if err != nil {
-			log.Error().Err(err).Msg("failed to generate new tokens")
-			http.Error(w, "failed to generate tokens", http.StatusInternalServerError)
+		jkt, apiErr := o.dpopKey(r)
+		if apiErr != nil {
+			fail("invalid DPoP proof")
+			writeProblem(w, r, apiErr)
 			return
 		}
 
-		user.IP = clientIP
-		user.HashedRefreshToken = newRefreshTokenHash
-		if err := db.UpdateUser(user); err != nil {
-			log.Error().Err(err).Msg("failed to update user")
-			http.Error(w, "failed to update", http.StatusInternalServerError)
+		scope := newScopeRequest(client, resp.Scope, resp.Audience)
+		scope.jkt = jkt
+		tokens, _, apiErr := issuer.refresh(r, user, decodedToken, clientIP, scope, fail)
+		if apiErr != nil {
+			// Повторно предъявленный токен больше не пригодится, cookie удаляются
+			if fromCookie && apiErr.Code == response.CodeTokenReused {
+				o.cookies.clear(w)
+			}
+			writeProblem(w, r, apiErr)
 			return
 		}
 
-		// Convert token to base64
-		refreshBase64 := base64.StdEncoding.EncodeToString([]byte(newRefreshToken))
+		// Кодируем токен в base64
+		refreshBase64 := base64.StdEncoding.EncodeToString([]byte(tokens.Refresh))
 		response := response.UserResponse{
-			AccessToken:     newAccessToken,
+			AccessToken:     tokens.Access,
 			GetRefreshToken: refreshBase64,
 		}
 
+		if o.cookieDelivery(r, fromCookie) {
+			if !o.setRefreshCookie(w, r, refreshBase64) {
+				return
+			}
+			response.GetRefreshToken = ""
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(response); err != nil {
-			log.Error().Err(err).Msg("failed to encode response")
+			requestLog(r).Error().Err(err).Msg("failed to encode response")
 		}
 
-		log.Info().
+		requestLog(r).Info().
 			Str("status", "success").
 			Int("code", http.StatusOK).
 			Msg("Successfully sent response")
 	}
 }
-
-func emailWarning(email, bodyString string) error {
-	const op = "handlers.refreshHandler.emailWarning"
-	m := mail.NewMessage()
-
-	m.SetHeader("From", "your_login@gmail.com")
-	m.SetHeader("To", email)
-	m.SetHeader("Subject", "WARNING! IP ADDRESS TO ACCESS MEDODS HAS JUST CHANGED")
-	m.SetBody("text/plain", bodyString)
-
-	// Use environment variables for login credentials.
-	d := mail.NewDialer("smtp.gmail.com", 587, "your_login@gmail.com", "your_password")
-
-	if err := d.DialAndSend(m); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	return nil
-}
@@ -5,14 +5,179 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
 	"github.com/volchok96/auth-medods/internal/database/pgsql"
+	"github.com/volchok96/auth-medods/internal/health"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
 )
 
-func SetupRoutes(storage *pgsql.DB, ownKey string, tokenTTL time.Duration) http.Handler {
-	r := chi.NewRouter()
+// SetupRoutes собирает маршруты сервиса; extra дополняет зависимости хендлеров,
+// которые настраиваются при запуске (блокировка refresh, доставка предупреждений).
+// С WithInternalListener административный API и /metrics сюда не входят,
+// их собирает SetupInternalRoutes
+func SetupRoutes(storage *pgsql.DB, ownKey string, tokenTTL time.Duration, adminToken string,
+	rateLimits ratelimit.Config, extra ...Option) http.Handler {
+	opts := withAudit(storage, extra)
+	o := newOptions(opts)
+	r := newRouter(o)
+
+	var limitStore database.RateLimitStore = ratelimit.NewMemoryStore(rateLimits.MaxPeriod())
+	if rateLimits.Store == ratelimit.StorePostgres {
+		limitStore = storage
+	}
+	limiter := ratelimit.NewLimiter(limitStore)
+
+	oidc := o.oidc
+
+	api := func(r chi.Router) {
+		r.With(TokenMetrics(operationIssue), AccessRateLimit(limiter, rateLimits.Access)).
+			Get("/access", AccessHandler(storage, ownKey, tokenTTL, opts...))
+		r.With(TokenMetrics(operationRefresh), MaxBodySize(refreshBodyLimit), RefreshRateLimit(limiter, rateLimits.Refresh)).
+			Post("/refresh", RefreshHandler(storage, ownKey, tokenTTL, opts...))
+		r.With(TokenMetrics(operationOAuth), MaxBodySize(refreshBodyLimit), TokenRateLimit(limiter, rateLimits)).
+			Post("/oauth/token", TokenHandler(storage, ownKey, tokenTTL, opts...))
 
-	r.Get("/access", AccessHandler(storage, ownKey, tokenTTL))
-	r.Post("/refresh", RefreshHandler(storage, ownKey, tokenTTL))
+		// OpenID Connect включается только при заданном издателе ID токенов
+		if oidc != nil {
+			r.Get("/userinfo", UserInfoHandler(storage, ownKey, opts...))
+			r.Post("/userinfo", UserInfoHandler(storage, ownKey, opts...))
+		}
 
+		if !o.internalListener {
+			adminAPI(r, storage, ownKey, adminToken, opts)
+		}
+	}
+	versioned(r, api)
+
+	r.Get("/openapi.json", OpenAPIHandler(APIDocument()))
+	if !o.internalListener {
+		r.Method(http.MethodGet, "/metrics", MetricsHandler())
+	}
+	probes(r, o, false)
+	if o.debugEndpoints && !o.internalListener {
+		debugRoutes(r, storage, ownKey, adminToken, opts)
+	}
+
+	// Адреса метаданных OpenID Connect фиксированы стандартом и не версионируются
+	if oidc != nil {
+		r.Get("/.well-known/openid-configuration", DiscoveryHandler(oidc))
+		r.Get("/.well-known/jwks.json", JWKSHandler(oidc))
+	}
+
+	return r
+}
+
+// SetupInternalRoutes собирает маршруты служебного listener: административный API,
+// /metrics, проверки состояния и отладочные маршруты. Пути те же, что на основном сервере
+func SetupInternalRoutes(storage *pgsql.DB, ownKey, adminToken string, extra ...Option) http.Handler {
+	opts := withAudit(storage, extra)
+	o := newOptions(opts)
+	r := newRouter(o)
+
+	versioned(r, func(r chi.Router) {
+		adminAPI(r, storage, ownKey, adminToken, opts)
+	})
+	r.Method(http.MethodGet, "/metrics", MetricsHandler())
+	probes(r, o, true)
+	if o.debugEndpoints {
+		debugRoutes(r, storage, ownKey, adminToken, opts)
+	}
+
+	return r
+}
+
+func newRouter(o *options) chi.Router {
+	r := chi.NewRouter()
+	r.Use(RequestID, ClientIP(o.proxies), Tracing, RequestLogger, Metrics, Recoverer)
 	return r
 }
+
+// withAudit добавляет к зависимостям запись в журнал аудита; extra может её заменить
+func withAudit(storage *pgsql.DB, extra []Option) []Option {
+	return append([]Option{
+		WithAudit(audit.NewRecorder(storage)),
+	}, extra...)
+}
+
+// versioned регистрирует маршруты под APIPrefix и без версии для старых клиентов
+func versioned(r chi.Router, routes func(chi.Router)) {
+	r.Route(APIPrefix, routes)
+	r.Group(func(r chi.Router) {
+		r.Use(deprecatedRoute)
+		routes(r)
+	})
+}
+
+// adminAPI включает административный API, только если задан ADMIN_TOKEN
+func adminAPI(r chi.Router, storage *pgsql.DB, ownKey, adminToken string, opts []Option) {
+	if adminToken == "" {
+		return
+	}
+	r.Route("/admin", adminRoutes(storage, ownKey, adminToken, opts))
+}
+
+// probes регистрирует проверки для оркестратора и балансировщика; они не версионируются.
+// details показывает в /readyz тексты ошибок проверок - только на служебном listener
+func probes(r chi.Router, o *options, details bool) {
+	readiness := o.readiness
+	if readiness == nil {
+		readiness = health.NewChecker(0)
+	}
+	r.Get("/healthz", HealthHandler())
+	r.Get("/readyz", ReadyHandler(readiness, details))
+}
+
+// debugRoutes регистрирует отладочные маршруты; они не версионируются и не входят в API.
+// Отчёт раскрывает данные сессии, поэтому маршруты, как и административный API,
+// требуют ADMIN_TOKEN и без него не включаются
+func debugRoutes(r chi.Router, storage *pgsql.DB, ownKey, adminToken string, opts []Option) {
+	if adminToken == "" {
+		return
+	}
+	r.With(AdminAuth(adminToken), MaxBodySize(refreshBodyLimit)).
+		Post("/debug/token", DebugTokenHandler(storage, storage, ownKey, opts...))
+}
+
+func adminRoutes(storage *pgsql.DB, ownKey, adminToken string, opts []Option) func(chi.Router) {
+	return func(r chi.Router) {
+		r.Use(AdminAuth(adminToken))
+
+		r.Route("/users", func(r chi.Router) {
+			r.Post("/", CreateUserHandler(storage, opts...))
+			r.Get("/", ListUsersHandler(storage))
+			r.Get("/{guid}", GetUserHandler(storage))
+			r.Patch("/{guid}", UpdateUserHandler(storage, opts...))
+			r.Delete("/{guid}", DeleteUserHandler(storage, opts...))
+			r.Post("/{guid}/lock", SetUserStatusHandler(storage, models.UserStatusLocked, opts...))
+			r.Post("/{guid}/disable", SetUserStatusHandler(storage, models.UserStatusDisabled, opts...))
+			r.Post("/{guid}/enable", SetUserStatusHandler(storage, models.UserStatusActive, opts...))
+			r.Get("/{guid}/lockout", GetLockoutHandler(storage, storage))
+			r.Delete("/{guid}/lockout", ClearLockoutHandler(storage, storage, opts...))
+		})
+
+		r.Route("/clients", func(r chi.Router) {
+			r.Post("/", CreateClientHandler(storage, opts...))
+			r.Get("/", ListClientsHandler(storage))
+			r.Get("/{client_id}", GetClientHandler(storage))
+			r.Patch("/{client_id}", UpdateClientHandler(storage, opts...))
+			r.Delete("/{client_id}", DeleteClientHandler(storage, opts...))
+			r.Post("/{client_id}/secret", RotateClientSecretHandler(storage, opts...))
+		})
+
+		r.Post("/introspect", IntrospectHandler(storage, ownKey, opts...))
+		r.Get("/audit", ListAuditEventsHandler(storage))
+	}
+}
+
+// deprecatedRoute помечает маршруты без версии как устаревшие и указывает их
+// замену под APIPrefix
+func deprecatedRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+APIPrefix+r.URL.Path+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
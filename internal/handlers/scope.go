@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+)
+
+// ScopeOpenID - область доступа, при запросе которой вместе с токенами выдаётся ID токен.
+// Она не требует разрешения клиенту, достаточно включённого OpenID Connect
+const ScopeOpenID = "openid"
+
+// maxScopeToken ограничивает длину одной области доступа или получателя
+const maxScopeToken = 200
+
+// grant - области доступа и получатели (aud), на которые выдаются токены.
+// JKT - отпечаток ключа DPoP, к которому привязаны токены
+type grant struct {
+	Scope    []string
+	Audience []string
+	JKT      string
+}
+
+func (g grant) openID() bool {
+	return slices.Contains(g.Scope, ScopeOpenID)
+}
+
+// tokenType - тип access токена в ответе: DPoP для привязанного к ключу
+func (g grant) tokenType() string {
+	if g.JKT != "" {
+		return "DPoP"
+	}
+	return "Bearer"
+}
+
+// scopeRequest - области доступа и получатели, запрошенные клиентом через пробел.
+// client - аутентифицированный клиент или nil без аутентификации клиентов,
+// jkt - отпечаток ключа из DPoP proof запроса
+type scopeRequest struct {
+	client   *models.Client
+	scope    []string
+	audience []string
+	jkt      string
+}
+
+func newScopeRequest(client *models.Client, scope, audience string) scopeRequest {
+	return scopeRequest{
+		client:   client,
+		scope:    dedupe(strings.Fields(scope)),
+		audience: dedupe(strings.Fields(audience)),
+	}
+}
+
+// grantFor проверяет запрос по спискам, разрешённым клиенту. Без запроса клиент
+// получает всё, что ему разрешено; без аутентификации клиентов доступна только openid
+func (o *options) grantFor(req scopeRequest) (grant, *response.Error) {
+	if apiErr := o.checkAllowed(req); apiErr != nil {
+		return grant{}, apiErr
+	}
+
+	g := grant{Scope: req.scope, Audience: req.audience, JKT: req.jkt}
+	if req.client != nil {
+		if len(g.Scope) == 0 {
+			g.Scope = slices.Clone(req.client.AllowedScopes)
+		}
+		if len(g.Audience) == 0 {
+			g.Audience = slices.Clone(req.client.AllowedAudiences)
+		}
+	}
+	return g, nil
+}
+
+// narrowGrant выдаёт при refresh те же или более узкие области доступа и получателей,
+// чем previous. Без запроса сохраняется previous за вычетом того, что клиенту
+// с тех пор запретили; если не осталось ничего, refresh отклоняется
+func (o *options) narrowGrant(req scopeRequest, previous grant) (grant, *response.Error) {
+	for _, s := range req.scope {
+		if !slices.Contains(previous.Scope, s) {
+			return grant{}, response.NewError(response.CodeInvalidScope, "scope "+s+" exceeds the original grant")
+		}
+	}
+	for _, a := range req.audience {
+		if !slices.Contains(previous.Audience, a) {
+			return grant{}, response.NewError(response.CodeInvalidAudience,
+				"audience "+a+" exceeds the original grant")
+		}
+	}
+	// Без аутентификации клиента (POST /refresh) достаточно того, что было выдано раньше
+	if req.client != nil {
+		if apiErr := o.checkAllowed(req); apiErr != nil {
+			return grant{}, apiErr
+		}
+	}
+
+	// Пустой список в токене означает отсутствие ограничения, поэтому если от
+	// выданного раньше ничего не осталось, refresh отклоняется, а не расширяет доступ
+	g := grant{Scope: req.scope, Audience: req.audience, JKT: req.jkt}
+	if len(g.Scope) == 0 {
+		g.Scope = o.stillAllowedScopes(req.client, previous.Scope)
+		if len(previous.Scope) > 0 && len(g.Scope) == 0 {
+			return grant{}, response.NewError(response.CodeInvalidScope,
+				"none of the originally granted scopes is allowed anymore")
+		}
+	}
+	if len(g.Audience) == 0 {
+		g.Audience = previous.Audience
+		if req.client != nil {
+			g.Audience = intersect(previous.Audience, req.client.AllowedAudiences)
+		}
+		if len(previous.Audience) > 0 && len(g.Audience) == 0 {
+			return grant{}, response.NewError(response.CodeInvalidAudience,
+				"none of the originally granted audiences is allowed anymore")
+		}
+	}
+	return g, nil
+}
+
+func (o *options) checkAllowed(req scopeRequest) *response.Error {
+	for _, s := range req.scope {
+		if len(s) > maxScopeToken {
+			return response.NewError(response.CodeInvalidScope, "scope is too long")
+		}
+		if s == ScopeOpenID {
+			if o.oidc == nil {
+				return response.NewError(response.CodeInvalidScope, "openid is not supported")
+			}
+			continue
+		}
+		if req.client == nil || !slices.Contains(req.client.AllowedScopes, s) {
+			return response.NewError(response.CodeInvalidScope, "scope "+s+" is not allowed for the client")
+		}
+	}
+
+	for _, a := range req.audience {
+		if len(a) > maxScopeToken {
+			return response.NewError(response.CodeInvalidAudience, "audience is too long")
+		}
+		if req.client == nil || !slices.Contains(req.client.AllowedAudiences, a) {
+			return response.NewError(response.CodeInvalidAudience, "audience "+a+" is not allowed for the client")
+		}
+	}
+
+	return nil
+}
+
+// stillAllowedScopes убирает из выданных ранее областей доступа те, что клиенту
+// больше не разрешены; openid остаётся, пока включён OpenID Connect
+func (o *options) stillAllowedScopes(client *models.Client, scope []string) []string {
+	var kept []string
+	for _, s := range scope {
+		switch {
+		case s == ScopeOpenID:
+			if o.oidc != nil {
+				kept = append(kept, s)
+			}
+		case client == nil || slices.Contains(client.AllowedScopes, s):
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+func intersect(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if slices.Contains(b, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func dedupe(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
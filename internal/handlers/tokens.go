@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+)
+
+// createSession сохраняет сведения о выданной паре токенов, чтобы access токен
+// можно было отозвать до истечения его срока действия
+func createSession(db database.DBInterface, r *http.Request, user *models.User, tokens *jwt.Tokens) error {
+	id, err := uuid.Parse(tokens.ID)
+	if err != nil {
+		return err
+	}
+
+	return db.CreateSession(r.Context(), &models.Session{
+		ID:        id,
+		UserGUID:  user.UserGUID,
+		IP:        user.IP,
+		UserAgent: r.UserAgent(),
+		ExpiresAt: tokens.ExpiresAt,
+	})
+}
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+)
+
+const (
+	defaultUsersLimit = 50
+	maxUsersLimit     = 500
+	maxEmailLength    = 100
+)
+
+// CreateUserHandler - хендлер для создания пользователя
+func CreateUserHandler(db database.DBInterface, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req response.CreateUser
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to decode body")
+			writeError(w, r, response.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		guid := uuid.New()
+		if req.GUID != "" {
+			parsed, err := uuid.Parse(req.GUID)
+			if err != nil {
+				requestLog(r).Error().Err(err).Msg("invalid guid")
+				writeError(w, r, response.CodeInvalidGUID, "invalid guid")
+				return
+			}
+			guid = parsed
+		}
+
+		if !ValidEmail(req.Email) {
+			requestLog(r).Error().Msg("invalid email")
+			writeError(w, r, response.CodeInvalidEmail, "invalid email")
+			return
+		}
+
+		user := &models.User{
+			UserGUID: guid,
+			Email:    req.Email,
+		}
+
+		err := db.CreateUser(r.Context(), user)
+		if errors.Is(err, database.ErrUserExists) {
+			requestLog(r).Error().Str("guid", guid.String()).Msg("user already exists")
+			writeError(w, r, response.CodeUserExists, "user already exists")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to create user")
+			writeError(w, r, response.CodeInternal, "failed to create user")
+			return
+		}
+
+		requestLog(r).Info().Str("guid", guid.String()).Msg("user created")
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventUserCreated,
+			Actor:    audit.ActorAdmin,
+			UserGUID: guid.String(),
+			Outcome:  audit.OutcomeSuccess,
+		})
+		writeJSON(w, http.StatusCreated, userResponse(user))
+	}
+}
+
+// GetUserHandler - хендлер для получения пользователя по GUID
+func GetUserHandler(db database.DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadUser(w, r, db, chi.URLParam(r, "guid"))
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, userResponse(user))
+	}
+}
+
+// ListUsersHandler - хендлер для постраничного списка пользователей
+func ListUsersHandler(db database.DBInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := queryInt(r, "limit", defaultUsersLimit)
+		if err != nil || limit <= 0 || limit > maxUsersLimit {
+			writeError(w, r, response.CodeInvalidRequest, "invalid limit")
+			return
+		}
+
+		offset, err := queryInt(r, "offset", 0)
+		if err != nil || offset < 0 {
+			writeError(w, r, response.CodeInvalidRequest, "invalid offset")
+			return
+		}
+
+		users, err := db.ListUsers(r.Context(), limit, offset)
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to list users")
+			writeError(w, r, response.CodeInternal, "failed to list users")
+			return
+		}
+
+		list := response.UserList{
+			Users:  make([]response.User, 0, len(users)),
+			Limit:  limit,
+			Offset: offset,
+		}
+		for i := range users {
+			list.Users = append(list.Users, userResponse(&users[i]))
+		}
+
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+// UpdateUserHandler - хендлер для изменения email пользователя
+func UpdateUserHandler(db database.DBInterface, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req response.UpdateUser
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to decode body")
+			writeError(w, r, response.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		if req.Email != nil && !ValidEmail(*req.Email) {
+			requestLog(r).Error().Msg("invalid email")
+			writeError(w, r, response.CodeInvalidEmail, "invalid email")
+			return
+		}
+
+		user, ok := loadUser(w, r, db, chi.URLParam(r, "guid"))
+		if !ok {
+			return
+		}
+
+		if req.Email == nil {
+			writeJSON(w, http.StatusOK, userResponse(user))
+			return
+		}
+
+		// Меняется только email: запись всей строки затёрла бы параллельный обмен refresh токена
+		err := db.UpdateUserEmail(r.Context(), user.UserGUID.String(), *req.Email)
+		if errors.Is(err, database.ErrUserNotFound) {
+			writeError(w, r, response.CodeUserNotFound, "user not found")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to update user")
+			writeError(w, r, response.CodeInternal, "failed to update user")
+			return
+		}
+		user.Email = *req.Email
+
+		requestLog(r).Info().Str("guid", user.UserGUID.String()).Msg("user updated")
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventUserUpdated,
+			Actor:    audit.ActorAdmin,
+			UserGUID: user.UserGUID.String(),
+			Outcome:  audit.OutcomeSuccess,
+			Details:  "email changed",
+		})
+		writeJSON(w, http.StatusOK, userResponse(user))
+	}
+}
+
+// SetUserStatusHandler - хендлер для блокировки, отключения и повторной активации пользователя.
+// Блокировка и отключение сразу завершают все сессии пользователя
+func SetUserStatusHandler(db database.DBInterface, status models.UserStatus, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req response.UserStatusChange
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			requestLog(r).Error().Err(err).Msg("failed to decode body")
+			writeError(w, r, response.CodeInvalidRequest, "invalid request body")
+			return
+		}
+
+		switch status {
+		case models.UserStatusActive:
+			req = response.UserStatusChange{}
+		case models.UserStatusDisabled:
+			req.Until = nil
+		case models.UserStatusLocked:
+			if req.Until != nil && !req.Until.After(time.Now()) {
+				writeError(w, r, response.CodeInvalidRequest, "lock end must be in the future")
+				return
+			}
+		}
+
+		guid := chi.URLParam(r, "guid")
+		if _, err := uuid.Parse(guid); err != nil {
+			requestLog(r).Error().Err(err).Msg("invalid guid")
+			writeError(w, r, response.CodeInvalidGUID, "invalid guid")
+			return
+		}
+
+		err := db.SetUserStatus(r.Context(), guid, status, req.Reason, req.Until)
+		if errors.Is(err, database.ErrUserNotFound) {
+			writeError(w, r, response.CodeUserNotFound, "user not found")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to set user status")
+			writeError(w, r, response.CodeInternal, "failed to set user status")
+			return
+		}
+
+		requestLog(r).Info().
+			Str("guid", guid).
+			Str("status", string(status)).
+			Str("reason", req.Reason).
+			Msg("user status changed")
+
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventUserStatusChanged,
+			Actor:    audit.ActorAdmin,
+			UserGUID: guid,
+			Outcome:  audit.OutcomeSuccess,
+			Details:  fmt.Sprintf("status %s: %s", status, req.Reason),
+		})
+		if status != models.UserStatusActive {
+			o.audit.Record(r, audit.Event{
+				Type:     audit.EventTokensRevoked,
+				Actor:    audit.ActorAdmin,
+				UserGUID: guid,
+				Outcome:  audit.OutcomeSuccess,
+				Details:  "all sessions revoked on " + string(status),
+			})
+		}
+
+		user, ok := loadUser(w, r, db, guid)
+		if !ok {
+			return
+		}
+
+		writeJSON(w, http.StatusOK, userResponse(user))
+	}
+}
+
+// DeleteUserHandler - хендлер для удаления пользователя
+func DeleteUserHandler(db database.DBInterface, opts ...Option) http.HandlerFunc {
+	o := newOptions(opts)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		guid := chi.URLParam(r, "guid")
+		if _, err := uuid.Parse(guid); err != nil {
+			requestLog(r).Error().Err(err).Msg("invalid guid")
+			writeError(w, r, response.CodeInvalidGUID, "invalid guid")
+			return
+		}
+
+		err := db.DeleteUser(r.Context(), guid)
+		if errors.Is(err, database.ErrUserNotFound) {
+			writeError(w, r, response.CodeUserNotFound, "user not found")
+			return
+		}
+		if err != nil {
+			requestLog(r).Error().Err(err).Msg("failed to delete user")
+			writeError(w, r, response.CodeInternal, "failed to delete user")
+			return
+		}
+
+		requestLog(r).Info().Str("guid", guid).Msg("user deleted")
+		o.audit.Record(r, audit.Event{
+			Type:     audit.EventUserDeleted,
+			Actor:    audit.ActorAdmin,
+			UserGUID: guid,
+			Outcome:  audit.OutcomeSuccess,
+		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func loadUser(w http.ResponseWriter, r *http.Request, db database.DBInterface, guid string) (*models.User, bool) {
+	if _, err := uuid.Parse(guid); err != nil {
+		requestLog(r).Error().Err(err).Msg("invalid guid")
+		writeError(w, r, response.CodeInvalidGUID, "invalid guid")
+		return nil, false
+	}
+
+	user, err := db.GetUserByGUID(r.Context(), guid)
+	if errors.Is(err, database.ErrUserNotFound) {
+		writeError(w, r, response.CodeUserNotFound, "user not found")
+		return nil, false
+	}
+	if err != nil {
+		requestLog(r).Error().Err(err).Msg("failed to get user")
+		writeError(w, r, response.CodeInternal, "failed to get user")
+		return nil, false
+	}
+
+	return user, true
+}
+
+func userResponse(user *models.User) response.User {
+	return response.User{
+		GUID:         user.UserGUID.String(),
+		Email:        user.Email,
+		IP:           user.IP,
+		Status:       string(user.Status),
+		StatusReason: user.StatusReason,
+		StatusUntil:  user.StatusUntil,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	}
+}
+
+// ValidEmail проверяет адрес пользователя так же, как административный API
+func ValidEmail(email string) bool {
+	if email == "" || len(email) > maxEmailLength {
+		return false
+	}
+
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+func queryInt(r *http.Request, key string, def int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Состояния готовности и отдельных проверок
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDraining = "draining"
+)
+
+// DefaultTimeout - время на одну проверку, если в NewChecker не задано другое
+const DefaultTimeout = 2 * time.Second
+
+// Check - проверка зависимости сервиса. Неудача необязательной проверки
+// попадает в отчёт, но не снимает готовность
+type Check struct {
+	Name     string
+	Optional bool
+	Run      func(ctx context.Context) error
+}
+
+// Result - итог одной проверки
+type Result struct {
+	Status   string `json:"status"`
+	Optional bool   `json:"optional,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report - итог проверки готовности
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Ready сообщает, готов ли сервис принимать запросы
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Failed сообщает, не прошла ли хотя бы одна проверка, в том числе необязательная
+func (r Report) Failed() bool {
+	for _, result := range r.Checks {
+		if result.Status != StatusOK {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted возвращает отчёт без текстов ошибок: в них бывают адреса и имена
+// внутренних сервисов, которые не стоит показывать снаружи
+func (r Report) Redacted() Report {
+	if len(r.Checks) == 0 {
+		return r
+	}
+
+	checks := make(map[string]Result, len(r.Checks))
+	for name, result := range r.Checks {
+		result.Error = ""
+		checks[name] = result
+	}
+	return Report{Status: r.Status, Checks: checks}
+}
+
+// Checker проверяет готовность сервиса. После Drain сервис считается не готовым
+// без проверок, чтобы балансировщик перестал присылать запросы до остановки
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Drain переводит сервис в состояние остановки: готовность больше не восстанавливается
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Draining сообщает, вызывался ли Drain
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check выполняет проверки параллельно, каждую не дольше таймаута
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.Draining() {
+		return Report{Status: StatusDraining}
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(c.checks))}
+	for i, check := range c.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != StatusOK && !check.Optional {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	result := Result{Status: StatusOK, Optional: check.Optional}
+	if err := check.Run(ctx); err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
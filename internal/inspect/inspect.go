@@ -0,0 +1,154 @@
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+)
+
+// ErrMalformed - строка не разбирается как JWT
+var ErrMalformed = errors.New("token is malformed")
+
+// Ключ, которым подписан токен
+const (
+	KeyCurrent  = "current"
+	KeyPrevious = "previous"
+)
+
+// Срок действия токена
+const (
+	ExpiryValid   = "valid"
+	ExpiryExpired = "expired"
+	ExpiryMissing = "missing"
+)
+
+// Report - разбор access токена для отладки. В JSON его отдаёт /debug/token
+type Report struct {
+	Header map[string]any `json:"header"`
+	Claims map[string]any `json:"claims"`
+	// Key - current или previous; пусто, если подпись не сходится ни с одним ключом
+	Key       string     `json:"key,omitempty"`
+	Expiry    string     `json:"expiry"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Revoked и Session заполняются только для токенов, подписанных ключом сервиса.
+	// У токена клиента (client_credentials) сессии нет
+	Revoked *bool    `json:"revoked,omitempty"`
+	Session *Session `json:"session,omitempty"`
+	// Valid сообщает, примет ли токен сервис; иначе Reason объясняет причину
+	Valid  bool   `json:"valid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Session - сессия, которой выдан токен
+type Session struct {
+	ID        string     `json:"id"`
+	UserGUID  string     `json:"user_guid"`
+	State     string     `json:"state"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Inspector разбирает токены, выданные jwt.NewTokens, и проверяет их ключами сервиса
+type Inspector struct {
+	db       database.DBInterface
+	sessions database.SessionStore
+	keys     []string
+}
+
+func NewInspector(db database.DBInterface, sessions database.SessionStore, ownKey string,
+	previousKeys ...string) *Inspector {
+	return &Inspector{db: db, sessions: sessions, keys: append([]string{ownKey}, previousKeys...)}
+}
+
+// Inspect разбирает токен. Недействительный токен - не ошибка: причина попадает
+// в Report.Reason. Ошибка возвращается для неразборчивой строки (ErrMalformed)
+// и при сбое базы данных
+func (in *Inspector) Inspect(ctx context.Context, token string) (*Report, error) {
+	const fn = "inspect.Inspector.Inspect"
+
+	decoded, err := jwt.Decode(token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", fn, ErrMalformed, err)
+	}
+
+	now := time.Now()
+	report := &Report{Header: decoded.Header, Claims: decoded.Claims, Expiry: ExpiryMissing}
+	if exp, ok := decoded.Claims["exp"].(json.Number); ok {
+		if sec, err := exp.Int64(); err == nil {
+			expiresAt := time.Unix(sec, 0).UTC()
+			report.ExpiresAt = &expiresAt
+			report.Expiry = ExpiryValid
+			if now.After(expiresAt) {
+				report.Expiry = ExpiryExpired
+			}
+		}
+	}
+
+	switch jwt.SigningKey(token, in.keys...) {
+	case -1:
+		report.Reason = "signature does not match the configured keys"
+		return report, nil
+	case 0:
+		report.Key = KeyCurrent
+	default:
+		report.Key = KeyPrevious
+	}
+
+	_, err = jwt.ParseAccessToken(token, in.keys[0], in.keys[1:]...)
+	if err != nil {
+		report.Reason = err.Error()
+	}
+	report.Valid = err == nil
+
+	// Поддельный токен мог бы узнать чужую сессию, поэтому база читается после проверки подписи
+	jti, _ := decoded.Claims["jti"].(string)
+	if _, parseErr := uuid.Parse(jti); parseErr != nil {
+		return report, nil
+	}
+
+	revoked, err := in.db.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+	report.Revoked = &revoked
+
+	session, err := in.sessions.GetSession(ctx, jti)
+	switch {
+	case errors.Is(err, database.ErrSessionNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	default:
+		report.Session = newSession(session, now)
+	}
+
+	if revoked && report.Valid {
+		report.Valid = false
+		report.Reason = "token has been revoked"
+	}
+
+	return report, nil
+}
+
+func newSession(s *models.Session, now time.Time) *Session {
+	return &Session{
+		ID:        s.ID.String(),
+		UserGUID:  s.UserGUID.String(),
+		State:     s.State(now),
+		IP:        s.IP,
+		UserAgent: s.UserAgent,
+		CreatedAt: s.CreatedAt,
+		ExpiresAt: s.ExpiresAt,
+		RevokedAt: s.RevokedAt,
+	}
+}
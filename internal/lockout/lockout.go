@@ -0,0 +1,187 @@
+package lockout
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// Policy задаёт, после скольких неудачных попыток подряд refresh блокируется и насколько.
+// Нулевой порог отключает счётчик своей области
+type Policy struct {
+	UserThreshold    int
+	SessionThreshold int
+	// BaseBackoff - длительность первой блокировки; каждая следующая вдвое дольше, но не больше MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// ResetAfter - через сколько без неудачных попыток счётчики и эскалация забываются
+	ResetAfter time.Duration
+}
+
+// Backoff возвращает длительность блокировки с номером n, начиная с 1
+func (p Policy) Backoff(n int) time.Duration {
+	d := p.BaseBackoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// State - блокировка refresh в одной из областей
+type State struct {
+	Locked bool
+	Scope  string
+	Until  time.Time
+	// Lockouts - номер блокировки подряд, определяет её длительность
+	Lockouts int
+}
+
+// SessionID возвращает идентификатор сессии клиента для области сессии - отпечаток
+// предъявленного refresh токена. IP клиента для этого не годится: его легко сменить
+func SessionID(token []byte) string {
+	sum := sha256.Sum256(token)
+	return hex.EncodeToString(sum[:16])
+}
+
+// SourceID возвращает идентификатор источника попыток с чужими токенами - IP клиента.
+// Отпечаток такого токена меняется с каждой догадкой, а адрес, определённый с учётом
+// доверенных прокси, клиент не выбирает
+func SourceID(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+// Guard считает неудачные попытки refresh по пользователю и по сессии клиента.
+// Нулевой *Guard ничего не блокирует
+type Guard struct {
+	store  database.LockoutStore
+	policy Policy
+	now    func() time.Time
+}
+
+func NewGuard(store database.LockoutStore, policy Policy) *Guard {
+	return &Guard{store: store, policy: policy, now: time.Now}
+}
+
+// Check возвращает действующую блокировку refresh для пользователя в сессии session
+// с источника source. Из нескольких блокировок возвращается самая долгая
+func (g *Guard) Check(ctx context.Context, guid, session, source string) (State, error) {
+	const fn = "lockout.Check"
+
+	if g == nil {
+		return State{}, nil
+	}
+
+	lockouts, err := g.store.ListRefreshLockouts(ctx, guid)
+	if err != nil {
+		return State{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	now := g.now()
+	var state State
+	for i := range lockouts {
+		l := &lockouts[i]
+		if l.Scope == models.LockoutScopeSession && l.Subject != session && l.Subject != source {
+			continue
+		}
+		if l.IsLocked(now) && l.LockedUntil.After(state.Until) {
+			state = State{Locked: true, Scope: l.Scope, Until: *l.LockedUntil, Lockouts: l.Lockouts}
+		}
+	}
+
+	return state, nil
+}
+
+// Failure учитывает неудачную попытку. genuine - предъявлен настоящий токен
+// пользователя (текущий или уже использованный); только такие попытки считаются
+// в области пользователя. Иначе любой, кто знает GUID, мог бы заблокировать refresh
+// самому пользователю. Попытки с чужим токеном считаются в области сессии по
+// источнику source: так перебор токенов блокируется, а счётчиков не больше, чем
+// адресов у перебирающего. Возвращённое состояние Locked, если именно эта попытка
+// привела к блокировке
+func (g *Guard) Failure(ctx context.Context, guid, session, source string, genuine bool) (State, error) {
+	const fn = "lockout.Failure"
+
+	if g == nil {
+		return State{}, nil
+	}
+
+	subject := session
+	if !genuine {
+		subject = source
+	}
+
+	scopes := []struct {
+		scope     string
+		subject   string
+		threshold int
+	}{
+		{models.LockoutScopeUser, "", g.policy.UserThreshold},
+		{models.LockoutScopeSession, subject, g.policy.SessionThreshold},
+	}
+
+	now := g.now()
+	var state State
+	for _, s := range scopes {
+		if s.threshold <= 0 || (s.scope == models.LockoutScopeUser && !genuine) {
+			continue
+		}
+
+		err := g.store.UpdateRefreshLockout(ctx, guid, s.scope, s.subject, func(l *models.RefreshLockout) {
+			if locked := g.count(l, s.threshold, now); locked.Locked && locked.Until.After(state.Until) {
+				locked.Scope = s.scope
+				state = locked
+			}
+		})
+		if err != nil {
+			return state, fmt.Errorf("%s: %w", fn, err)
+		}
+	}
+
+	return state, nil
+}
+
+// count увеличивает счётчик и при достижении порога блокирует область
+func (g *Guard) count(l *models.RefreshLockout, threshold int, now time.Time) State {
+	if now.Sub(l.LastFailureAt) > g.policy.ResetAfter && !l.IsLocked(now) {
+		l.Failures = 0
+		l.Lockouts = 0
+	}
+
+	l.Failures++
+	l.LastFailureAt = now
+	if l.Failures < threshold {
+		return State{}
+	}
+
+	l.Failures = 0
+	l.Lockouts++
+	until := now.Add(g.policy.Backoff(l.Lockouts))
+	l.LockedUntil = &until
+
+	return State{Locked: true, Until: until, Lockouts: l.Lockouts}
+}
+
+// Success сбрасывает счётчики пользователя и сессии после успешного refresh.
+// Блокировки других сессий и счётчики источников остаются: иначе перебор с того же
+// адреса сбрасывался бы успешными refresh пользователя
+func (g *Guard) Success(ctx context.Context, guid, session string) error {
+	const fn = "lockout.Success"
+
+	if g == nil {
+		return nil
+	}
+
+	if err := g.store.ResetRefreshLockout(ctx, guid, session); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "auth_medods"
+
+// Метрики задачи очистки устаревших данных
+var (
+	RetentionDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "deleted_total",
+		Help:      "Number of rows pruned by the retention worker.",
+	}, []string{"table"})
+
+	RetentionRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "runs_total",
+		Help:      "Retention runs by result: success, error or skipped when another replica holds the lock.",
+	}, []string{"result"})
+
+	RetentionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "run_duration_seconds",
+		Help:      "Duration of retention runs that acquired the lock.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+
+	RetentionLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "retention",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful retention run.",
+	})
+)
+
+// Метрики ограничения запросов
+var (
+	RateLimitRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ratelimit",
+		Name:      "requests_total",
+		Help:      "Rate limit decisions by route, key kind and result: allowed, limited or error.",
+	}, []string{"route", "key", "result"})
+)
+
+// Метрики защиты от подбора refresh токенов
+var (
+	RefreshLockouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "lockout",
+		Name:      "locks_total",
+		Help:      "Refresh lockouts triggered by repeated failed attempts, by scope: user or session.",
+	}, []string{"scope"})
+)
+
+// Метрики выдачи токенов
+var (
+	TokensIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tokens",
+		Name:      "issued_total",
+		Help:      "Token pairs issued without a refresh token, by grant: guid or client_credentials.",
+	}, []string{"grant"})
+
+	TokensRefreshed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tokens",
+		Name:      "refreshed_total",
+		Help:      "Token pairs issued in exchange for a refresh token.",
+	})
+
+	TokenFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tokens",
+		Name:      "failures_total",
+		Help:      "Rejected token requests by operation (issue, refresh, oauth) and reason: the error code of the response.",
+	}, []string{"operation", "reason"})
+
+	IPChanges = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tokens",
+		Name:      "ip_changes_total",
+		Help:      "Successful refreshes from an IP address different from the previous one.",
+	})
+
+	BcryptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "tokens",
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Duration of bcrypt operations by op: hash or compare.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 10),
+	}, []string{"op"})
+)
+
+// Метрики доставки предупреждений пользователям
+var (
+	Notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "notify",
+		Name:      "notifications_total",
+		Help:      "Security notifications by kind (ip_changed, refresh_locked) and result: sent or failed.",
+	}, []string{"kind", "result"})
+)
+
+// Метрики HTTP сервера
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route pattern, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
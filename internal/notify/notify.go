@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/mail.v2"
+)
+
+// Notifier доставляет пользователю предупреждения о событиях безопасности
+type Notifier interface {
+	Notify(to, subject, body string) error
+}
+
+// SMTPConfig - параметры почтового сервера для отправки предупреждений
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Email отправляет предупреждения письмом
+type Email struct {
+	from   string
+	dialer *mail.Dialer
+}
+
+func NewEmail(cfg SMTPConfig) *Email {
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+
+	return &Email{
+		from:   from,
+		dialer: mail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password),
+	}
+}
+
+func (e *Email) Notify(to, subject, body string) error {
+	const fn = "notify.Email.Notify"
+
+	m := mail.NewMessage()
+	m.SetHeader("From", e.from)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
+
+	if err := e.dialer.DialAndSend(m); err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// Log пишет предупреждения в лог вместо отправки; используется, когда SMTP не настроен
+type Log struct{}
+
+func (Log) Notify(to, subject, body string) error {
+	log.Info().Str("to", to).Str("subject", subject).Msg(body)
+	return nil
+}
+
+// Ping проверяет, что почтовый сервер принимает соединения
+func (e *Email) Ping(ctx context.Context) error {
+	const fn = "notify.Email.Ping"
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(e.dialer.Host, strconv.Itoa(e.dialer.Port)))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return conn.Close()
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// sweepInterval - как часто MemoryStore удаляет неиспользуемые ключи
+const sweepInterval = time.Minute
+
+// MemoryStore хранит состояние ограничителя в памяти процесса. Каждая реплика
+// считает запросы отдельно; для общего лимита используется хранилище в PostgreSQL
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*models.RateLimitBucket
+	maxIdle   time.Duration
+	lastSweep time.Time
+}
+
+// NewMemoryStore создаёт хранилище, которое забывает ключи, не использованные
+// дольше maxIdle. maxIdle не должен быть меньше наибольшего периода лимитов
+func NewMemoryStore(maxIdle time.Duration) *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]*models.RateLimitBucket{},
+		maxIdle: maxIdle,
+	}
+}
+
+func (s *MemoryStore) TakeRateLimit(_ context.Context, key string, take func(bucket *models.RateLimitBucket)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &models.RateLimitBucket{Key: key}
+		s.buckets[key] = bucket
+	}
+
+	take(bucket)
+	s.sweep(bucket.UpdatedAt)
+
+	return nil
+}
+
+// sweep удаляет ключи, запас которых уже восстановился полностью
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.UpdatedAt) > s.maxIdle {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// Len возвращает число отслеживаемых ключей
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.buckets)
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// Хранилища состояния ограничителя
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+)
+
+// Limit - не больше Requests запросов подряд, после чего запас восстанавливается
+// равномерно: Requests запросов за Period. Нулевой Limit ничего не ограничивает
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// UnmarshalText разбирает лимит в формате ParseLimit
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+	return nil
+}
+
+// ParseLimit разбирает лимит вида "20/1m". Пустая строка, "0" и "off" отключают ограничение
+func ParseLimit(s string) (Limit, error) {
+	const fn = "ratelimit.ParseLimit"
+
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" || s == "off" {
+		return Limit{}, nil
+	}
+
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%s: %q: expected <requests>/<period>", fn, s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%s: %q: requests must be a positive number", fn, s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%s: %q: period must be a positive duration", fn, s)
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+// RouteLimits - лимиты одного маршрута по IP клиента и по GUID пользователя
+type RouteLimits struct {
+	IP   Limit
+	GUID Limit
+}
+
+// Config - настройки ограничения запросов к эндпоинтам выдачи токенов
+type Config struct {
+	Store   string
+	Access  RouteLimits
+	Refresh RouteLimits
+}
+
+// MaxPeriod - наибольший период среди лимитов; через это время без запросов
+// запас любого ключа восстанавливается полностью
+func (c Config) MaxPeriod() time.Duration {
+	var max time.Duration
+	for _, l := range []Limit{c.Access.IP, c.Access.GUID, c.Refresh.IP, c.Refresh.GUID} {
+		if l.Enabled() && l.Period > max {
+			max = l.Period
+		}
+	}
+	return max
+}
+
+// Result - решение по одному запросу
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset - через сколько запас восстановится полностью
+	Reset time.Duration
+	// RetryAfter - через сколько будет разрешён следующий запрос; 0, если запрос разрешён
+	RetryAfter time.Duration
+}
+
+// Limiter расходует запас запросов по ключам из общего хранилища
+type Limiter struct {
+	store database.RateLimitStore
+	now   func() time.Time
+}
+
+func NewLimiter(store database.RateLimitStore) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Allow списывает один запрос с запаса ключа. Отключённый лимит разрешает любой запрос
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	const fn = "ratelimit.Allow"
+
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := l.now()
+	var res Result
+	err := l.store.TakeRateLimit(ctx, key, func(bucket *models.RateLimitBucket) {
+		res = take(bucket, limit, now)
+	})
+	if err != nil {
+		return Result{}, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	return res, nil
+}
+
+// take пополняет запас за прошедшее время и списывает из него один запрос
+func take(bucket *models.RateLimitBucket, limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	perSecond := capacity / limit.Period.Seconds()
+
+	tokens := capacity
+	if elapsed := now.Sub(bucket.UpdatedAt); elapsed < limit.Period {
+		tokens = math.Min(capacity, bucket.Tokens+elapsed.Seconds()*perSecond)
+	}
+
+	res := Result{Limit: limit.Requests}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - tokens) / perSecond)
+	}
+
+	bucket.Tokens = tokens
+	bucket.UpdatedAt = now
+
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = seconds((capacity - tokens) / perSecond)
+
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
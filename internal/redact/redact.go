@@ -0,0 +1,149 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Redacted заменяет в логах значение секрета
+const Redacted = "[REDACTED]"
+
+// minSecretLen - более короткие значения не ищутся в тексте: замена испортила бы
+// лог, а такой секрет всё равно не защитить
+const minSecretLen = 4
+
+// Secret - строка, которую нельзя показывать: ключи, пароли, токены. При выводе
+// через fmt, JSON или текстовом кодировании вместо значения пишется [REDACTED],
+// пустой секрет остаётся пустым, чтобы было видно, что он не задан
+type Secret string
+
+// Value возвращает само значение секрета
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Writer фильтрует JSON события zerolog перед выводом: заменяет значения полей,
+// имена которых указывают на секрет (password, secret, *_token, *_hash...), и
+// вхождения известных секретов в любых строках, включая сообщение и ошибку.
+// Фильтр стоит на выводе, а не в zerolog.Hook: хук не видит уже добавленных полей
+type Writer struct {
+	out io.Writer
+
+	mu      sync.RWMutex
+	secrets []string
+}
+
+func NewWriter(out io.Writer, secrets ...string) *Writer {
+	w := &Writer{out: out}
+	w.Add(secrets...)
+	return w
+}
+
+// Add добавляет значения, которые не должны попасть в лог
+func (w *Writer) Add(secrets ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range secrets {
+		if len(s) >= minSecretLen {
+			w.secrets = append(w.secrets, s)
+		}
+	}
+}
+
+// Write получает одно событие zerolog. Строки, которые не разбираются как JSON,
+// проходят только замену известных секретов
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var event map[string]any
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&event); err != nil {
+		if _, err := io.WriteString(w.out, w.replace(string(p))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(w.redact(event)); err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *Writer) redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if SensitiveKey(key) {
+				if s, ok := value.(string); !ok || s != "" {
+					v[key] = Redacted
+				}
+				continue
+			}
+			v[key] = w.redact(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = w.redact(value)
+		}
+		return v
+	case string:
+		return w.replace(v)
+	}
+	return v
+}
+
+func (w *Writer) replace(s string) string {
+	for _, secret := range w.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+// sensitiveParts - фрагменты имён полей, значения которых всегда скрываются
+var sensitiveParts = []string{"password", "passwd", "secret", "authorization", "cookie", "dsn", "connstr", "ownkey"}
+
+// SensitiveKey сообщает, указывает ли имя поля на секрет. Регистр, подчёркивания
+// и дефисы не учитываются: RefreshToken, refresh_token и refresh-token одинаковы.
+// Поля вида token_ttl не считаются секретными - проверяется окончание имени
+func SensitiveKey(key string) bool {
+	key = strings.ToLower(strings.NewReplacer("_", "", "-", "", " ", "").Replace(key))
+	for _, part := range sensitiveParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return strings.HasSuffix(key, "token") || strings.HasSuffix(key, "hash")
+}
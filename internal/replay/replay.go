@@ -0,0 +1,42 @@
+package replay
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache помнит одноразовые идентификаторы (jti) до истечения их срока действия.
+// Кеш локален для реплики: повтор на другую реплику он не обнаружит; для общей
+// проверки используется хранилище в PostgreSQL
+type Cache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func NewCache() *Cache {
+	return &Cache{seen: map[string]time.Time{}}
+}
+
+// Add запоминает ключ до expiresAt и возвращает false, если ключ уже встречался
+func (c *Cache) Add(key string, expiresAt, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, exp := range c.seen {
+		if exp.Before(now) {
+			delete(c.seen, k)
+		}
+	}
+
+	if _, ok := c.seen[key]; ok {
+		return false
+	}
+	c.seen[key] = expiresAt
+	return true
+}
+
+// AddReplayKey - Add в виде database.ReplayStore; кеш в памяти не возвращает ошибок
+func (c *Cache) AddReplayKey(_ context.Context, key string, expiresAt, now time.Time) (bool, error) {
+	return c.Add(key, expiresAt, now), nil
+}
@@ -0,0 +1,180 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/metrics"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+// Таблицы, которые обслуживает очистка; используются как метки метрик
+const (
+	TableRefreshTokens = "refresh_tokens"
+	TableSessions      = "sessions"
+	TableDenylist      = "token_denylist"
+	TableAuditEvents   = "audit_events"
+	TableRateLimits    = "rate_limits"
+	TableLockouts      = "refresh_lockouts"
+	TableReplayKeys    = "replay_keys"
+)
+
+// Config задаёт сроки хранения. Нулевой срок для refresh токенов, сессий,
+// журнала аудита, состояния ограничителя запросов и счётчиков неудачных
+// попыток refresh отключает их очистку;
+// записи denylist удаляются всегда, спустя DenylistGrace после истечения
+// срока действия токена, а использованные jti - сразу после истечения
+type Config struct {
+	Interval      time.Duration
+	BatchSize     int
+	RefreshTokens time.Duration
+	Sessions      time.Duration
+	DenylistGrace time.Duration
+	AuditEvents   time.Duration
+	RateLimits    time.Duration
+	Lockouts      time.Duration
+}
+
+// Stats - число удалённых записей по таблицам за один проход
+type Stats map[string]int64
+
+// Worker периодически удаляет устаревшие данные. Из нескольких реплик проход
+// выполняет только та, что взяла advisory lock
+type Worker struct {
+	store   database.RetentionStore
+	lockKey int64
+	cfg     Config
+	now     func() time.Time
+}
+
+func NewWorker(store database.RetentionStore, lockKey int64, cfg Config) *Worker {
+	return &Worker{
+		store:   store,
+		lockKey: lockKey,
+		cfg:     cfg,
+		now:     time.Now,
+	}
+}
+
+// Run выполняет проходы с интервалом cfg.Interval до отмены ctx и возвращается,
+// когда текущий проход остановится. Нулевой интервал отключает фоновую очистку
+func (w *Worker) Run(ctx context.Context) {
+	if w.cfg.Interval <= 0 {
+		log.Info().Msg("retention scheduler is disabled")
+		return
+	}
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, _, err := w.RunOnce(ctx); err != nil {
+			if ctx.Err() != nil {
+				log.Info().Msg("retention run stopped")
+				return
+			}
+			log.Error().Err(err).Msg("retention run failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce выполняет один проход очистки. ran == false означает, что проход
+// пропущен, так как его уже выполняет другая реплика
+func (w *Worker) RunOnce(ctx context.Context) (stats Stats, ran bool, err error) {
+	const fn = "retention.RunOnce"
+
+	ctx, span := tracing.Start(ctx, fn)
+	defer func() { tracing.End(span, err) }()
+
+	unlock, ok, err := w.store.TryLock(ctx, w.lockKey)
+	if err != nil {
+		metrics.RetentionRuns.WithLabelValues("error").Inc()
+		return nil, false, fmt.Errorf("%s: %w", fn, err)
+	}
+	if !ok {
+		metrics.RetentionRuns.WithLabelValues("skipped").Inc()
+		log.Debug().Msg("retention lock is held by another replica")
+		return nil, false, nil
+	}
+	defer unlock()
+
+	start := w.now()
+	stats = Stats{}
+
+	tasks := []struct {
+		table  string
+		maxAge time.Duration
+		prune  func(context.Context, time.Time, int) (int64, error)
+		always bool
+	}{
+		{TableRefreshTokens, w.cfg.RefreshTokens, w.store.PruneRefreshTokens, false},
+		{TableSessions, w.cfg.Sessions, w.store.PruneSessions, false},
+		{TableDenylist, w.cfg.DenylistGrace, w.store.PruneDenylist, true},
+		{TableAuditEvents, w.cfg.AuditEvents, w.store.PruneAuditEvents, false},
+		{TableRateLimits, w.cfg.RateLimits, w.store.PruneRateLimits, false},
+		{TableLockouts, w.cfg.Lockouts, w.store.PruneRefreshLockouts, false},
+		{TableReplayKeys, 0, w.store.PruneReplayKeys, true},
+	}
+
+	for _, task := range tasks {
+		if task.maxAge <= 0 && !task.always {
+			continue
+		}
+
+		n, err := w.pruneTable(ctx, start.Add(-task.maxAge), task.prune)
+		stats[task.table] = n
+		metrics.RetentionDeleted.WithLabelValues(task.table).Add(float64(n))
+		if err != nil {
+			metrics.RetentionRuns.WithLabelValues("error").Inc()
+			return stats, true, fmt.Errorf("%s: %s: %w", fn, task.table, err)
+		}
+	}
+
+	elapsed := w.now().Sub(start)
+	metrics.RetentionRuns.WithLabelValues("success").Inc()
+	metrics.RetentionDuration.Observe(elapsed.Seconds())
+	metrics.RetentionLastSuccess.SetToCurrentTime()
+
+	log.Info().
+		Int64(TableRefreshTokens, stats[TableRefreshTokens]).
+		Int64(TableSessions, stats[TableSessions]).
+		Int64(TableDenylist, stats[TableDenylist]).
+		Int64(TableAuditEvents, stats[TableAuditEvents]).
+		Int64(TableRateLimits, stats[TableRateLimits]).
+		Int64(TableLockouts, stats[TableLockouts]).
+		Dur("elapsed", elapsed).
+		Msg("retention run finished")
+
+	return stats, true, nil
+}
+
+// pruneTable удаляет записи пачками, пока очередная пачка не окажется неполной.
+// Отмена ctx останавливает очистку после пачки, которая уже удаляется
+func (w *Worker) pruneTable(ctx context.Context, cutoff time.Time,
+	prune func(context.Context, time.Time, int) (int64, error)) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := prune(context.WithoutCancel(ctx), cutoff, w.cfg.BatchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(w.cfg.BatchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package servertls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Режимы проверки клиентских сертификатов
+const (
+	// ClientAuthNone - сертификат клиента не запрашивается
+	ClientAuthNone = "none"
+	// ClientAuthRequest - сертификат запрашивается без проверки цепочки; клиента
+	// определяет отпечаток сертификата (метод mtls в CLIENT_AUTH)
+	ClientAuthRequest = "request"
+	// ClientAuthOptional - предъявленный сертификат проверяется по ClientCA
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire - без сертификата, подписанного ClientCA, соединение не устанавливается
+	ClientAuthRequire = "require"
+)
+
+var ClientAuthModes = []string{ClientAuthNone, ClientAuthRequest, ClientAuthOptional, ClientAuthRequire}
+
+// Config - файлы сертификатов сервера. Пустой CertFile отключает TLS
+type Config struct {
+	CertFile       string
+	KeyFile        string
+	ClientCA       string
+	ClientAuth     string
+	ReloadInterval time.Duration
+}
+
+// Enabled сообщает, задан ли сертификат сервера
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// New собирает настройки TLS сервера. Сертификат отдаётся через Reloader,
+// чтобы его можно было заменить без перезапуска
+func New(cfg Config) (*tls.Config, *Reloader, error) {
+	const fn = "servertls.New"
+
+	reloader, err := NewReloader(cfg.CertFile, cfg.KeyFile, cfg.ReloadInterval)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch cfg.ClientAuth {
+	case ClientAuthNone, "":
+		tlsCfg.ClientAuth = tls.NoClientCert
+	case ClientAuthRequest:
+		tlsCfg.ClientAuth = tls.RequestClientCert
+	case ClientAuthOptional, ClientAuthRequire:
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.ClientAuth == ClientAuthRequire {
+			tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		if tlsCfg.ClientCAs, err = loadCertPool(cfg.ClientCA); err != nil {
+			return nil, nil, fmt.Errorf("%s: client CA: %w", fn, err)
+		}
+	default:
+		return nil, nil, fmt.Errorf("%s: unknown client auth mode %q", fn, cfg.ClientAuth)
+	}
+
+	return tlsCfg, reloader, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, errors.New("CA bundle is required")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// Reloader отдаёт сертификат сервера и перечитывает его, когда меняются файлы.
+// Если новые файлы не разбираются, остаётся прежний сертификат
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate подходит для tls.Config.GetCertificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload перечитывает сертификат, если файлы изменились после прошлой загрузки.
+// reloaded сообщает, заменён ли сертификат
+func (r *Reloader) Reload() (reloaded bool, err error) {
+	const fn = "servertls.Reloader.Reload"
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return false, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	r.mu.Lock()
+	r.cert, r.modTime = &cert, modTime
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// Run проверяет файлы сертификата с интервалом до отмены ctx. Нулевой интервал
+// отключает перезагрузку
+func (r *Reloader) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to reload TLS certificate, keeping the previous one")
+			continue
+		}
+		if reloaded {
+			cert, _ := r.GetCertificate(nil)
+			log.Info().Time("not_after", cert.Leaf.NotAfter).Msg("TLS certificate reloaded")
+		}
+	}
+}
+
+// latestModTime - время последнего изменения файлов сертификата и ключа
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Экспортёры трасс
+const (
+	// ExporterNone отключает экспорт; контекст трассы всё равно принимается и передаётся
+	ExporterNone = "none"
+	// ExporterOTLP отправляет трассы коллектору по OTLP/HTTP
+	ExporterOTLP = "otlp"
+	// ExporterStdout печатает завершённые span в stdout для локальной отладки
+	ExporterStdout = "stdout"
+)
+
+// Exporters - допустимые значения Config.Exporter
+var Exporters = []string{ExporterNone, ExporterOTLP, ExporterStdout}
+
+const instrumentationName = "github.com/volchok96/auth-medods"
+
+// Config - настройки трассировки. Endpoint - адрес коллектора OTLP/HTTP; пустой
+// берётся из стандартных OTEL_EXPORTER_OTLP_* переменных окружения. Выборка
+// задаётся переменными OTEL_TRACES_SAMPLER и OTEL_TRACES_SAMPLER_ARG
+type Config struct {
+	Exporter    string
+	Endpoint    string
+	ServiceName string
+	// Output - куда пишет экспортёр stdout; по умолчанию os.Stdout
+	Output io.Writer
+}
+
+// Setup устанавливает глобальный провайдер трасс и W3C propagation (traceparent,
+// tracestate, baggage). Возвращённая функция отправляет накопленные span и
+// останавливает провайдер; её нужно вызвать при остановке сервиса
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	const fn = "tracing.Setup"
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		exporter = exp
+	case ExporterStdout:
+		opts := []stdouttrace.Option{stdouttrace.WithPrettyPrint()}
+		if cfg.Output != nil {
+			opts = append(opts, stdouttrace.WithWriter(cfg.Output))
+		}
+		exp, err := stdouttrace.New(opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fn, err)
+		}
+		exporter = exp
+	default:
+		return nil, fmt.Errorf("%s: unknown exporter %q", fn, cfg.Exporter)
+	}
+
+	// Переменные OTEL_SERVICE_NAME и OTEL_RESOURCE_ATTRIBUTES дополняют и переопределяют имя сервиса
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil && !errors.Is(err, resource.ErrPartialResource) {
+		return nil, fmt.Errorf("%s: %w", fn, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer возвращает трассировщик сервиса. Пока Setup не вызван, span не записываются
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start открывает дочерний span операции name
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// End отмечает ошибку операции, если она была, и закрывает span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
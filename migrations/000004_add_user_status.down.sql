@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS token_denylist;
+DROP TABLE IF EXISTS sessions;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_status_check,
+    ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET disabled = TRUE WHERE status <> 'active';
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS status_until,
+    DROP COLUMN IF EXISTS status_reason,
+    DROP COLUMN IF EXISTS status;
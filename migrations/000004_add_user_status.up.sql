@@ -0,0 +1,30 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS status_until TIMESTAMPTZ;
+
+UPDATE users SET status = 'disabled' WHERE disabled;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS disabled,
+    ADD CONSTRAINT users_status_check CHECK (status IN ('active', 'locked', 'disabled'));
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY,
+    user_guid UUID NOT NULL REFERENCES users (user_guid) ON DELETE CASCADE,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_guid_idx ON sessions (user_guid);
+
+CREATE TABLE IF NOT EXISTS token_denylist (
+    jti UUID PRIMARY KEY,
+    user_guid UUID NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,4 @@
+-- Записи, добавленные до включения цепочки, остаются с пустым хешем
+ALTER TABLE audit_events
+    ADD COLUMN IF NOT EXISTS prev_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS token_denylist_expires_at_idx;
+DROP INDEX IF EXISTS sessions_expires_at_idx;
+DROP INDEX IF EXISTS users_refresh_issued_at_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_issued_at;
@@ -0,0 +1,20 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_issued_at TIMESTAMPTZ;
+
+UPDATE users SET refresh_issued_at = updated_at
+WHERE hashed_refresh_token IS NOT NULL AND hashed_refresh_token <> '';
+
+CREATE INDEX IF NOT EXISTS users_refresh_issued_at_idx ON users (refresh_issued_at)
+    WHERE hashed_refresh_token IS NOT NULL;
+CREATE INDEX IF NOT EXISTS sessions_expires_at_idx ON sessions (expires_at);
+CREATE INDEX IF NOT EXISTS token_denylist_expires_at_idx ON token_denylist (expires_at);
+
+-- Удалять записи журнала аудита может только задача очистки, выставившая
+-- в своей транзакции SET LOCAL audit.retention = 'on'. Изменение по-прежнему запрещено
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' AND current_setting('audit.retention', true) = 'on' THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,3 @@
+-- Хеш предыдущего refresh токена позволяет отличить повторное использование
+-- уже обменянного токена от подбора
+ALTER TABLE users ADD COLUMN IF NOT EXISTS previous_refresh_hash TEXT;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS refresh_lockouts (
+    user_guid UUID NOT NULL REFERENCES users (user_guid) ON DELETE CASCADE,
+    scope TEXT NOT NULL CHECK (scope IN ('user', 'session')),
+    subject TEXT NOT NULL DEFAULT '',
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (user_guid, scope, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_lockouts_last_failure_at ON refresh_lockouts (last_failure_at);
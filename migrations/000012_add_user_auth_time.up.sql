@@ -0,0 +1,3 @@
+-- Время последней аутентификации пользователя по GUID; refresh его не меняет.
+-- Попадает в claim auth_time ID токенов OpenID Connect
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_time TIMESTAMPTZ;
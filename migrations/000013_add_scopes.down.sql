@@ -0,0 +1,4 @@
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_audience;
+ALTER TABLE users DROP COLUMN IF EXISTS refresh_scope;
+ALTER TABLE clients DROP COLUMN IF EXISTS allowed_audiences;
+ALTER TABLE clients DROP COLUMN IF EXISTS allowed_scopes;
@@ -0,0 +1,8 @@
+-- Области доступа и получатели (aud), которые клиент может запрашивать для токенов
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS allowed_scopes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE clients ADD COLUMN IF NOT EXISTS allowed_audiences TEXT[] NOT NULL DEFAULT '{}';
+
+-- Области доступа и получатели, выданные вместе с текущим refresh токеном;
+-- refresh может их сохранить или сузить, но не расширить
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_scope TEXT[];
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_audience TEXT[];
@@ -0,0 +1,2 @@
+-- Отпечаток ключа DPoP, к которому привязан текущий refresh токен (RFC 9449)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_jkt TEXT;
@@ -0,0 +1,2 @@
+-- Клиент, которому выдан текущий refresh токен; обменять токен может только он
+ALTER TABLE users ADD COLUMN IF NOT EXISTS refresh_client_id TEXT;
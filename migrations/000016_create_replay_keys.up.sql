@@ -0,0 +1,7 @@
+-- Использованные jti DPoP proof, общие для всех реплик
+CREATE TABLE IF NOT EXISTS replay_keys (
+    key TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_replay_keys_expires_at ON replay_keys (expires_at);
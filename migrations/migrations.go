@@ -0,0 +1,10 @@
+// Package migrations встраивает SQL миграции в бинарник, чтобы команда
+// auth-medods migrate не зависела от каталога на диске
+package migrations
+
+import "embed"
+
+// FS - файлы миграций в формате migrate: NNNNNN_name.up.sql и NNNNNN_name.down.sql
+//
+//go:embed *.sql
+var FS embed.FS
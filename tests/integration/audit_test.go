@@ -0,0 +1,165 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+type MockAuditStore struct {
+	mock.Mock
+}
+
+func (m *MockAuditStore) AppendAuditEvent(_ context.Context, event *models.AuditEvent, chain func(string, *models.AuditEvent)) error {
+	chain("", event)
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockAuditStore) ListAuditEvents(_ context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	args := m.Called(filter)
+	events, _ := args.Get(0).([]models.AuditEvent)
+	return events, args.Error(1)
+}
+
+func auditEvent(eventType, outcome string, guid uuid.UUID) any {
+	return mock.MatchedBy(func(e *models.AuditEvent) bool {
+		return e.Type == eventType && e.Outcome == outcome && e.UserGUID.UUID == guid
+	})
+}
+
+func TestAccessHandlerWritesAuditEvents(t *testing.T) {
+	t.Run("successful issuance", func(t *testing.T) {
+		mockDB := new(MockDB)
+		store := new(MockAuditStore)
+		guid := uuid.New()
+
+		mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{UserGUID: guid, Email: "a@example.com"}, nil)
+		mockDB.On("UpdateUser", mock.Anything).Return(nil)
+		mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+		mockDB.On("CreateSession", mock.Anything).Return(nil)
+		store.On("AppendAuditEvent", auditEvent(audit.EventTokenIssued, audit.OutcomeSuccess, guid)).Return(nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/access?guid="+guid.String(), nil)
+		req.Header.Set("User-Agent", "medods-test")
+		w := httptest.NewRecorder()
+		handlers.AccessHandler(mockDB, "test_key", 30*time.Minute, handlers.WithAudit(audit.NewRecorder(store))).
+			ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		store.AssertExpectations(t)
+		event := store.Calls[0].Arguments.Get(0).(*models.AuditEvent)
+		assert.Equal(t, "medods-test", event.UserAgent)
+		assert.NotEmpty(t, event.IP)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mockDB := new(MockDB)
+		store := new(MockAuditStore)
+		guid := uuid.New()
+
+		mockDB.On("GetUserByGUID", guid.String()).Return(nil, database.ErrUserNotFound)
+		store.On("AppendAuditEvent", auditEvent(audit.EventTokenIssued, audit.OutcomeFailure, guid)).Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		handlers.AccessHandler(mockDB, "test_key", 30*time.Minute, handlers.WithAudit(audit.NewRecorder(store))).
+			ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/access?guid="+guid.String(), nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		store.AssertExpectations(t)
+	})
+}
+
+func TestRefreshHandlerAuditsInvalidToken(t *testing.T) {
+	mockDB := new(MockDB)
+	store := new(MockAuditStore)
+	guid := uuid.New()
+
+	mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{
+		UserGUID:           guid,
+		HashedRefreshToken: "$2a$10$invalidinvalidinvalidinvalidinvalidinvalidinvalidinva",
+	}, nil)
+	store.On("AppendAuditEvent", auditEvent(audit.EventTokenRefreshed, audit.OutcomeFailure, guid)).Return(nil).Once()
+
+	body, _ := json.Marshal(response.RefreshToken{GUID: guid.String(), RefreshToken: "d3JvbmctdG9rZW4="})
+	w := httptest.NewRecorder()
+	handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute, handlers.WithAudit(audit.NewRecorder(store))).
+		ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewBuffer(body)))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	store.AssertExpectations(t)
+	mockDB.AssertNotCalled(t, "RotateRefreshToken", mock.Anything, mock.Anything)
+}
+
+func TestListAuditEventsHandler(t *testing.T) {
+	store := new(MockAuditStore)
+	guid := uuid.New()
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store.On("ListAuditEvents", mock.MatchedBy(func(f models.AuditFilter) bool {
+		return f.UserGUID == guid.String() &&
+			assert.ObjectsAreEqual([]string{audit.EventTokenIssued, audit.EventTokenRefreshed}, f.Types) &&
+			f.From != nil && f.From.Equal(from) && f.To == nil &&
+			f.Limit == 2 && f.Offset == 4
+	})).Return([]models.AuditEvent{
+		{ID: 7, Type: audit.EventTokenIssued, UserGUID: uuid.NullUUID{UUID: guid, Valid: true}, Outcome: audit.OutcomeSuccess},
+		{ID: 6, Type: audit.EventTokenRefreshed, Outcome: audit.OutcomeFailure},
+	}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/audit?guid="+guid.String()+
+		"&type=token.issued,token.refreshed&from=2026-01-01T00:00:00Z&limit=2&offset=4", nil)
+	w := httptest.NewRecorder()
+	handlers.ListAuditEventsHandler(store).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var page response.AuditEvents
+	assert.NoError(t, json.NewDecoder(w.Body).Decode(&page))
+	assert.Len(t, page.Events, 2)
+	assert.Equal(t, guid.String(), page.Events[0].GUID)
+	if assert.NotNil(t, page.NextOffset) {
+		assert.Equal(t, 6, *page.NextOffset)
+	}
+	store.AssertExpectations(t)
+}
+
+func TestListAuditEventsHandlerRejectsBadRange(t *testing.T) {
+	store := new(MockAuditStore)
+
+	w := httptest.NewRecorder()
+	handlers.ListAuditEventsHandler(store).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit?from=yesterday", nil))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	store.AssertNotCalled(t, "ListAuditEvents", mock.Anything)
+}
+
+func TestRecordCommandHasNoClientDetails(t *testing.T) {
+	store := new(MockAuditStore)
+	guid := uuid.New()
+
+	store.On("AppendAuditEvent", mock.MatchedBy(func(e *models.AuditEvent) bool {
+		return e.Type == audit.EventUserStatusChanged && e.Actor == audit.ActorCLI &&
+			e.UserGUID.UUID == guid && e.IP == "" && e.UserAgent == "" && e.Hash != ""
+	})).Return(nil).Once()
+
+	audit.NewRecorder(store).RecordCommand(context.Background(), audit.Event{
+		Type:     audit.EventUserStatusChanged,
+		Actor:    audit.ActorCLI,
+		UserGUID: guid.String(),
+		Outcome:  audit.OutcomeSuccess,
+	})
+	store.AssertExpectations(t)
+}
@@ -0,0 +1,194 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+// memoryClientStore - ClientStore в памяти
+type memoryClientStore struct {
+	mu      sync.Mutex
+	clients map[string]models.Client
+}
+
+func newMemoryClientStore() *memoryClientStore {
+	return &memoryClientStore{clients: map[string]models.Client{}}
+}
+
+func (s *memoryClientStore) CreateClient(_ context.Context, c *models.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[c.ClientID]; ok {
+		return database.ErrClientExists
+	}
+	c.CreatedAt, c.UpdatedAt = time.Now(), time.Now()
+	s.clients[c.ClientID] = *c
+	return nil
+}
+
+func (s *memoryClientStore) UpdateClient(_ context.Context, c *models.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[c.ClientID]; !ok {
+		return database.ErrClientNotFound
+	}
+	c.UpdatedAt = time.Now()
+	s.clients[c.ClientID] = *c
+	return nil
+}
+
+func (s *memoryClientStore) GetClient(_ context.Context, id string) (*models.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.clients[id]
+	if !ok {
+		return nil, database.ErrClientNotFound
+	}
+	return &c, nil
+}
+
+func (s *memoryClientStore) GetClientByThumbprint(_ context.Context, thumbprint string) (*models.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.clients {
+		if c.CertThumbprint == thumbprint {
+			return &c, nil
+		}
+	}
+	return nil, database.ErrClientNotFound
+}
+
+func (s *memoryClientStore) ListClients(_ context.Context) ([]models.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []models.Client
+	for _, c := range s.clients {
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+func (s *memoryClientStore) DeleteClient(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.clients[id]; !ok {
+		return database.ErrClientNotFound
+	}
+	delete(s.clients, id)
+	return nil
+}
+
+func clientsRouter(store database.ClientStore) http.Handler {
+	r := chi.NewRouter()
+	r.Use(handlers.AdminAuth(adminToken))
+	r.Post("/admin/clients", handlers.CreateClientHandler(store))
+	r.Get("/admin/clients", handlers.ListClientsHandler(store))
+	r.Get("/admin/clients/{client_id}", handlers.GetClientHandler(store))
+	r.Patch("/admin/clients/{client_id}", handlers.UpdateClientHandler(store))
+	r.Post("/admin/clients/{client_id}/secret", handlers.RotateClientSecretHandler(store))
+	r.Delete("/admin/clients/{client_id}", handlers.DeleteClientHandler(store))
+	return r
+}
+
+func TestAccessRequiresRegisteredClient(t *testing.T) {
+	store := newMemoryClientStore()
+	admin := clientsRouter(store)
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/clients", response.CreateClient{Name: "web"}))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created response.Client
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&created))
+	require.NotEmpty(t, created.ClientID)
+	require.NotEmpty(t, created.ClientSecret)
+	assert.True(t, created.HasSecret)
+
+	guid := uuid.New()
+	mockDB := new(MockDB)
+	mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{UserGUID: guid}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	auth, err := clientauth.New(store, clientauth.Config{Methods: []string{clientauth.MethodSecret}})
+	require.NoError(t, err)
+	access := handlers.AccessHandler(mockDB, "test_key", 30*time.Minute, handlers.WithClientAuth(auth))
+
+	request := func(clientID, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/access?guid="+guid.String(), nil)
+		if clientID != "" {
+			req.SetBasicAuth(clientID, secret)
+		}
+		w := httptest.NewRecorder()
+		access.ServeHTTP(w, req)
+		return w
+	}
+
+	w = request("", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, response.CodeInvalidClient, decodeProblem(t, w).Code)
+	mockDB.AssertNotCalled(t, "GetUserByGUID", mock.Anything)
+
+	assert.Equal(t, http.StatusUnauthorized, request(created.ClientID, "wrong").Code)
+	assert.Equal(t, http.StatusOK, request(created.ClientID, created.ClientSecret).Code)
+
+	// После смены секрета старый перестаёт действовать
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/clients/"+created.ClientID+"/secret", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var rotated response.Client
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rotated))
+	assert.Equal(t, http.StatusUnauthorized, request(created.ClientID, created.ClientSecret).Code)
+	assert.Equal(t, http.StatusOK, request(created.ClientID, rotated.ClientSecret).Code)
+
+	w = httptest.NewRecorder()
+	admin.ServeHTTP(w, adminRequest(http.MethodDelete, "/admin/clients/"+created.ClientID, nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, request(created.ClientID, rotated.ClientSecret).Code)
+}
+
+func TestAdminClientsValidation(t *testing.T) {
+	admin := clientsRouter(newMemoryClientStore())
+
+	for _, req := range []response.CreateClient{
+		{Name: ""},
+		{Name: "web", CertThumbprint: "not-a-digest"},
+		{Name: "web", PublicKey: "-----BEGIN PUBLIC KEY-----"},
+		{Name: "web", NoSecret: true},
+	} {
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/clients", req))
+		assert.Equal(t, http.StatusBadRequest, w.Code, req)
+	}
+
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/clients/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, response.CodeClientNotFound, decodeProblem(t, w).Code)
+}
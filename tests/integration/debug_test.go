@@ -0,0 +1,163 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/inspect"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+type MockSessionStore struct {
+	mock.Mock
+}
+
+func (m *MockSessionStore) GetSession(_ context.Context, id string) (*models.Session, error) {
+	args := m.Called(id)
+	session, _ := args.Get(0).(*models.Session)
+	return session, args.Error(1)
+}
+
+func (m *MockSessionStore) ListSessions(_ context.Context, guid string, activeOnly bool) ([]models.Session, error) {
+	args := m.Called(guid, activeOnly)
+	sessions, _ := args.Get(0).([]models.Session)
+	return sessions, args.Error(1)
+}
+
+func (m *MockSessionStore) RevokeSession(_ context.Context, id, reason string) (string, error) {
+	args := m.Called(id, reason)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockSessionStore) RevokeUserSessions(_ context.Context, guid, reason string) error {
+	args := m.Called(guid, reason)
+	return args.Error(0)
+}
+
+func debugToken(t *testing.T, db *MockDB, sessions *MockSessionStore, token string, opts ...handlers.Option) (int, inspect.Report) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/debug/token", strings.NewReader(url.Values{"token": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handlers.DebugTokenHandler(db, sessions, "new_key", opts...).ServeHTTP(w, req)
+
+	var report inspect.Report
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	}
+	return w.Code, report
+}
+
+func TestDebugTokenHandler(t *testing.T) {
+	guid := uuid.New()
+	issue := func(key string, ttl time.Duration) *jwt.Tokens {
+		tokens, err := jwt.NewTokens(context.Background(), key,
+			jwt.Params{GUID: guid.String(), IP: "10.0.0.7", Scope: []string{"openid"}, TTL: ttl})
+		require.NoError(t, err)
+		return tokens
+	}
+	session := func(tokens *jwt.Tokens) *models.Session {
+		return &models.Session{ID: uuid.MustParse(tokens.ID), UserGUID: guid, IP: "10.0.0.7",
+			UserAgent: "curl/8.0", CreatedAt: time.Now(), ExpiresAt: tokens.ExpiresAt}
+	}
+
+	t.Run("valid token with its session", func(t *testing.T) {
+		tokens := issue("new_key", time.Hour)
+		db, sessions := new(MockDB), new(MockSessionStore)
+		db.On("IsTokenRevoked", tokens.ID).Return(false, nil)
+		sessions.On("GetSession", tokens.ID).Return(session(tokens), nil)
+
+		code, report := debugToken(t, db, sessions, tokens.Access)
+		assert.Equal(t, http.StatusOK, code)
+		assert.True(t, report.Valid)
+		assert.Equal(t, inspect.KeyCurrent, report.Key)
+		assert.Equal(t, inspect.ExpiryValid, report.Expiry)
+		assert.Equal(t, "HS512", report.Header["alg"])
+		assert.Equal(t, guid.String(), report.Claims["guid"])
+		assert.Equal(t, "openid", report.Claims["scope"])
+		require.NotNil(t, report.Session)
+		assert.Equal(t, models.SessionActive, report.Session.State)
+		assert.Equal(t, "curl/8.0", report.Session.UserAgent)
+	})
+
+	t.Run("previous key", func(t *testing.T) {
+		tokens := issue("old_key", time.Hour)
+		db, sessions := new(MockDB), new(MockSessionStore)
+		db.On("IsTokenRevoked", tokens.ID).Return(false, nil)
+		sessions.On("GetSession", tokens.ID).Return(nil, database.ErrSessionNotFound)
+
+		_, report := debugToken(t, db, sessions, tokens.Access, handlers.WithPreviousKeys("old_key"))
+		assert.True(t, report.Valid)
+		assert.Equal(t, inspect.KeyPrevious, report.Key)
+		assert.Nil(t, report.Session)
+	})
+
+	t.Run("expired and revoked", func(t *testing.T) {
+		tokens := issue("new_key", -time.Minute)
+		revokedAt := time.Now()
+		revoked := session(tokens)
+		revoked.RevokedAt = &revokedAt
+		db, sessions := new(MockDB), new(MockSessionStore)
+		db.On("IsTokenRevoked", tokens.ID).Return(true, nil)
+		sessions.On("GetSession", tokens.ID).Return(revoked, nil)
+
+		_, report := debugToken(t, db, sessions, tokens.Access)
+		assert.False(t, report.Valid)
+		assert.Equal(t, inspect.ExpiryExpired, report.Expiry)
+		assert.Contains(t, report.Reason, "expired")
+		require.NotNil(t, report.Revoked)
+		assert.True(t, *report.Revoked)
+		assert.Equal(t, models.SessionRevoked, report.Session.State)
+	})
+
+	t.Run("foreign signature is not looked up", func(t *testing.T) {
+		tokens := issue("someone_else", time.Hour)
+		db, sessions := new(MockDB), new(MockSessionStore)
+
+		_, report := debugToken(t, db, sessions, tokens.Access)
+		assert.False(t, report.Valid)
+		assert.Empty(t, report.Key)
+		assert.Equal(t, tokens.ID, report.Claims["jti"], "claims are shown even when the signature does not match")
+		assert.Nil(t, report.Revoked)
+		db.AssertNotCalled(t, "IsTokenRevoked", mock.Anything)
+		sessions.AssertNotCalled(t, "GetSession", mock.Anything)
+	})
+
+	t.Run("malformed token", func(t *testing.T) {
+		code, _ := debugToken(t, new(MockDB), new(MockSessionStore), "not-a-jwt")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestDebugEndpointsAreOptIn(t *testing.T) {
+	status := func(adminToken, bearer string, opts ...handlers.Option) int {
+		req := httptest.NewRequest(http.MethodPost, "/debug/token", nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		w := httptest.NewRecorder()
+		handlers.SetupRoutes(nil, "test_key", time.Minute, adminToken, ratelimit.Config{}, opts...).ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, status("admin", "admin"))
+	assert.Equal(t, http.StatusNotFound, status("", "", handlers.WithDebugEndpoints()), "endpoint requires ADMIN_TOKEN")
+	assert.Equal(t, http.StatusUnauthorized, status("admin", "", handlers.WithDebugEndpoints()))
+	assert.Equal(t, http.StatusUnauthorized, status("admin", "wrong", handlers.WithDebugEndpoints()))
+	assert.Equal(t, http.StatusBadRequest, status("admin", "admin", handlers.WithDebugEndpoints()), "enabled endpoint requires a token")
+}
@@ -1,6 +1,7 @@
 package integration_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -19,7 +20,7 @@ type MockDB struct {
 	mock.Mock
 }
 
-func (m *MockDB) GetUserByGUID(guid string) (*models.User, error) {
+func (m *MockDB) GetUserByGUID(_ context.Context, guid string) (*models.User, error) {
 	args := m.Called(guid)
 	if user, ok := args.Get(0).(*models.User); ok {
 		return user, args.Error(1)
@@ -27,11 +28,52 @@ func (m *MockDB) GetUserByGUID(guid string) (*models.User, error) {
 	return nil, args.Error(1)
 }
 
-func (m *MockDB) UpdateUser(user *models.User) error {
+func (m *MockDB) CreateUser(_ context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
+func (m *MockDB) ListUsers(_ context.Context, limit, offset int) ([]models.User, error) {
+	args := m.Called(limit, offset)
+	users, _ := args.Get(0).([]models.User)
+	return users, args.Error(1)
+}
+
+func (m *MockDB) DeleteUser(_ context.Context, guid string) error {
+	args := m.Called(guid)
+	return args.Error(0)
+}
+
+func (m *MockDB) SetUserStatus(_ context.Context, guid string, status models.UserStatus, reason string, until *time.Time) error {
+	args := m.Called(guid, status, reason, until)
+	return args.Error(0)
+}
+
+func (m *MockDB) CreateSession(_ context.Context, session *models.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockDB) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDB) UpdateUser(_ context.Context, user *models.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *MockDB) UpdateUserEmail(_ context.Context, guid, email string) error {
+	args := m.Called(guid, email)
+	return args.Error(0)
+}
+
+func (m *MockDB) RotateRefreshToken(_ context.Context, user *models.User, previousHash string) error {
+	args := m.Called(user, previousHash)
+	return args.Error(0)
+}
+
 func (m *MockDB) Close() error {
 	return nil
 }
@@ -56,6 +98,9 @@ func TestAccessHandlerIntegration(t *testing.T) {
 
 	mockDB.On("UpdateUser", mock.Anything).Return(nil).Once() // Ожидаем один вызов
 
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil).Once()
+	mockDB.On("CreateSession", mock.Anything).Return(nil).Once()
+
 	handler := handlers.AccessHandler(mockDB, ownKey, tokenTTL)
 
 	t.Run("integration test for access handler with mocked DB", func(t *testing.T) {
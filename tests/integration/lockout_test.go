@@ -0,0 +1,188 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/lockout"
+)
+
+// memoryLockoutStore - LockoutStore в памяти
+type memoryLockoutStore struct {
+	mu       sync.Mutex
+	lockouts map[string]*models.RefreshLockout
+}
+
+func newMemoryLockoutStore() *memoryLockoutStore {
+	return &memoryLockoutStore{lockouts: map[string]*models.RefreshLockout{}}
+}
+
+func (s *memoryLockoutStore) UpdateRefreshLockout(_ context.Context, guid, scope, subject string, update func(*models.RefreshLockout)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := guid + "/" + scope + "/" + subject
+	l, ok := s.lockouts[key]
+	if !ok {
+		l = &models.RefreshLockout{UserGUID: uuid.MustParse(guid), Scope: scope, Subject: subject}
+		s.lockouts[key] = l
+	}
+	update(l)
+	return nil
+}
+
+func (s *memoryLockoutStore) ListRefreshLockouts(_ context.Context, guid string) ([]models.RefreshLockout, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []models.RefreshLockout
+	for _, l := range s.lockouts {
+		if l.UserGUID.String() == guid {
+			list = append(list, *l)
+		}
+	}
+	return list, nil
+}
+
+func (s *memoryLockoutStore) ResetRefreshLockout(_ context.Context, guid, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.lockouts, guid+"/"+models.LockoutScopeUser+"/")
+	delete(s.lockouts, guid+"/"+models.LockoutScopeSession+"/"+subject)
+	return nil
+}
+
+func (s *memoryLockoutStore) ClearRefreshLockouts(_ context.Context, guid string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for key, l := range s.lockouts {
+		if l.UserGUID.String() == guid {
+			delete(s.lockouts, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// recordingNotifier запоминает отправленные предупреждения
+type recordingNotifier struct {
+	mu       sync.Mutex
+	to       []string
+	subjects []string
+}
+
+func (n *recordingNotifier) Notify(to, subject, body string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.to = append(n.to, to)
+	n.subjects = append(n.subjects, subject)
+	return nil
+}
+
+func TestRefreshLockoutAfterFailedAttempts(t *testing.T) {
+	token := []byte(uuid.New().String())
+	hash, _ := bcrypt.GenerateFromPassword(token, bcrypt.MinCost)
+	oldToken := []byte(uuid.New().String())
+	oldHash, _ := bcrypt.GenerateFromPassword(oldToken, bcrypt.MinCost)
+	guid := uuid.New()
+	user := func() *models.User {
+		return &models.User{
+			UserGUID:            guid,
+			IP:                  "192.0.2.1",
+			Email:               "user@example.com",
+			HashedRefreshToken:  string(hash),
+			PreviousRefreshHash: string(oldHash),
+		}
+	}
+
+	mockDB := new(MockDB)
+	mockDB.On("GetUserByGUID", guid.String()).Return(user(), nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	store := newMemoryLockoutStore()
+	notifier := &recordingNotifier{}
+	guard := lockout.NewGuard(store, lockout.Policy{
+		UserThreshold:    2,
+		SessionThreshold: 3,
+		BaseBackoff:      time.Minute,
+		MaxBackoff:       time.Hour,
+		ResetAfter:       time.Hour,
+	})
+	refresh := handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute,
+		handlers.WithLockout(guard), handlers.WithNotifier(notifier))
+
+	sendFrom := func(addr string, token []byte) *httptest.ResponseRecorder {
+		req := refreshRequest(guid.String(), token)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		refresh.ServeHTTP(w, req)
+		return w
+	}
+	send := func(token []byte) *httptest.ResponseRecorder {
+		return sendFrom("192.0.2.1:4000", token)
+	}
+
+	// Подбор разными токенами блокирует источник перебора и не беспокоит пользователя
+	const attacker = "198.51.100.7:5000"
+	for i := range 3 {
+		assert.Equal(t, http.StatusUnauthorized, sendFrom(attacker, []byte(fmt.Sprintf("guess-%d", i))).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, sendFrom(attacker, []byte("guess-next")).Code)
+	assert.Empty(t, notifier.subjects)
+
+	// Повтор уже использованного токена - признак его кражи, он блокирует refresh пользователю
+	assert.Equal(t, http.StatusUnauthorized, send(oldToken).Code)
+	assert.Equal(t, http.StatusUnauthorized, send(oldToken).Code)
+	require.Len(t, notifier.to, 1)
+	assert.Equal(t, "user@example.com", notifier.to[0])
+
+	// Пока действует блокировка пользователя, отклоняется и правильный токен
+	w := send(token)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Equal(t, response.CodeRefreshLocked, decodeProblem(t, w).Code)
+	mockDB.AssertNotCalled(t, "RotateRefreshToken", mock.Anything, mock.Anything)
+
+	router := chi.NewRouter()
+	router.Use(handlers.AdminAuth(adminToken))
+	router.Get("/admin/users/{guid}/lockout", handlers.GetLockoutHandler(mockDB, store))
+	router.Delete("/admin/users/{guid}/lockout", handlers.ClearLockoutHandler(mockDB, store))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/users/"+guid.String()+"/lockout", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var state response.RefreshLockouts
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&state))
+	assert.True(t, state.Locked)
+	require.Len(t, state.Lockouts, 3)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, adminRequest(http.MethodDelete, "/admin/users/"+guid.String()+"/lockout", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	assert.Equal(t, http.StatusOK, send(token).Code)
+	assert.Empty(t, store.lockouts)
+}
@@ -0,0 +1,79 @@
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+// openTestStorage подключается к базе из DB_CONN_STR и применяет миграции.
+// Без DB_CONN_STR тест пропускается
+func openTestStorage(t *testing.T) *pgsql.DB {
+	t.Helper()
+	connStr := os.Getenv("DB_CONN_STR")
+	if connStr == "" {
+		t.Skip("DB_CONN_STR is not set")
+	}
+
+	storage, err := pgsql.NewDB(connStr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	migrator, err := storage.Migrator(context.Background())
+	require.NoError(t, err)
+	defer migrator.Close()
+	require.NoError(t, migrator.Up())
+
+	return storage
+}
+
+func TestIntrospectAfterUserDeletion(t *testing.T) {
+	storage := openTestStorage(t)
+	ctx := context.Background()
+
+	user := &models.User{UserGUID: uuid.New(), Email: "deleted@example.com"}
+	require.NoError(t, storage.CreateUser(ctx, user))
+
+	tokens, err := jwt.NewTokens(ctx, "test_key",
+		jwt.Params{GUID: user.UserGUID.String(), IP: "127.0.0.1", TTL: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, storage.CreateSession(ctx, &models.Session{
+		ID:        uuid.MustParse(tokens.ID),
+		UserGUID:  user.UserGUID,
+		IP:        "127.0.0.1",
+		ExpiresAt: tokens.ExpiresAt,
+	}))
+
+	introspect := func() bool {
+		req := httptest.NewRequest(http.MethodPost, "/admin/introspect",
+			strings.NewReader(url.Values{"token": {tokens.Access}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handlers.IntrospectHandler(storage, "test_key").ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body response.Introspection
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		return body.Active
+	}
+
+	assert.True(t, introspect())
+	require.NoError(t, storage.DeleteUser(ctx, user.UserGUID.String()))
+	assert.False(t, introspect(), "access tokens of a deleted user are revoked")
+}
@@ -0,0 +1,183 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) response.Problem {
+	t.Helper()
+
+	assert.Equal(t, response.ProblemContentType, w.Header().Get("Content-Type"))
+
+	var problem response.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, w.Code, problem.Status)
+	assert.Equal(t, "urn:auth-medods:problem:"+string(problem.Code), problem.Type)
+	assert.NotEmpty(t, problem.Title)
+
+	return problem
+}
+
+func refreshRequest(guid string, token []byte) *http.Request {
+	body, _ := json.Marshal(response.RefreshToken{
+		GUID:         guid,
+		RefreshToken: base64.StdEncoding.EncodeToString(token),
+	})
+	return httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewBuffer(body))
+}
+
+func TestAccessHandlerProblemDetails(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		setup  func(db *MockDB, guid string)
+		status int
+		code   response.ErrorCode
+	}{
+		{
+			name:   "missing guid",
+			query:  "",
+			status: http.StatusBadRequest,
+			code:   response.CodeInvalidGUID,
+		},
+		{
+			name:   "malformed guid",
+			query:  "?guid=not-a-guid",
+			status: http.StatusBadRequest,
+			code:   response.CodeInvalidGUID,
+		},
+		{
+			name: "unknown user",
+			setup: func(db *MockDB, guid string) {
+				db.On("GetUserByGUID", guid).Return(nil, database.ErrUserNotFound)
+			},
+			status: http.StatusNotFound,
+			code:   response.CodeUserNotFound,
+		},
+		{
+			name: "storage failure is a server fault",
+			setup: func(db *MockDB, guid string) {
+				db.On("GetUserByGUID", guid).Return(&models.User{UserGUID: uuid.MustParse(guid)}, nil)
+				db.On("UpdateUser", mock.Anything).Return(errors.New("connection refused"))
+			},
+			status: http.StatusInternalServerError,
+			code:   response.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(MockDB)
+			guid := uuid.New().String()
+			query := tt.query
+			if tt.setup != nil {
+				tt.setup(mockDB, guid)
+				query = "?guid=" + guid
+			}
+
+			w := httptest.NewRecorder()
+			handlers.AccessHandler(mockDB, "test_key", 30*time.Minute).
+				ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/access"+query, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			problem := decodeProblem(t, w)
+			assert.Equal(t, tt.code, problem.Code)
+			assert.Equal(t, "/access", problem.Instance)
+		})
+	}
+}
+
+func TestRefreshHandlerProblemDetails(t *testing.T) {
+	current := []byte(uuid.New().String())
+	previous := []byte(uuid.New().String())
+	currentHash, _ := bcrypt.GenerateFromPassword(current, bcrypt.MinCost)
+	previousHash, _ := bcrypt.GenerateFromPassword(previous, bcrypt.MinCost)
+	guid := uuid.New()
+
+	user := func() *models.User {
+		return &models.User{
+			UserGUID:            guid,
+			HashedRefreshToken:  string(currentHash),
+			PreviousRefreshHash: string(previousHash),
+		}
+	}
+
+	t.Run("reused refresh token", func(t *testing.T) {
+		mockDB := new(MockDB)
+		mockDB.On("GetUserByGUID", guid.String()).Return(user(), nil)
+
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute).ServeHTTP(w, refreshRequest(guid.String(), previous))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, response.CodeTokenReused, decodeProblem(t, w).Code)
+		mockDB.AssertNotCalled(t, "RotateRefreshToken", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		mockDB := new(MockDB)
+		mockDB.On("GetUserByGUID", guid.String()).Return(user(), nil)
+
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute).ServeHTTP(w, refreshRequest(guid.String(), []byte("guess")))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, response.CodeInvalidRefreshToken, decodeProblem(t, w).Code)
+	})
+
+	t.Run("token rotated by a concurrent request", func(t *testing.T) {
+		mockDB := new(MockDB)
+		mockDB.On("GetUserByGUID", guid.String()).Return(user(), nil)
+		mockDB.On("RotateRefreshToken", mock.Anything, string(currentHash)).Return(database.ErrRefreshConflict)
+
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute).ServeHTTP(w, refreshRequest(guid.String(), current))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, response.CodeTokenReused, decodeProblem(t, w).Code)
+	})
+
+	t.Run("unknown user looks like an invalid token", func(t *testing.T) {
+		mockDB := new(MockDB)
+		mockDB.On("GetUserByGUID", guid.String()).Return(nil, database.ErrUserNotFound)
+
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute).ServeHTTP(w, refreshRequest(guid.String(), current))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, response.CodeInvalidRefreshToken, decodeProblem(t, w).Code)
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(new(MockDB), "test_key", 30*time.Minute).
+			ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewBufferString("{")))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, response.CodeInvalidRequest, decodeProblem(t, w).Code)
+	})
+}
+
+func TestErrorCodesHaveStableStatuses(t *testing.T) {
+	assert.Equal(t, http.StatusUnauthorized, response.CodeTokenReused.Status())
+	assert.Equal(t, http.StatusForbidden, response.CodeUserInactive.Status())
+	assert.Equal(t, http.StatusInternalServerError, response.ErrorCode("unknown").Status())
+}
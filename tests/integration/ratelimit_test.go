@@ -0,0 +1,193 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+func TestAccessRateLimitByGUID(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limits := ratelimit.RouteLimits{
+		IP:   ratelimit.Limit{Requests: 10, Period: time.Minute},
+		GUID: ratelimit.Limit{Requests: 2, Period: time.Minute},
+	}
+
+	calls := 0
+	h := handlers.AccessRateLimit(limiter, limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+
+	request := func(guid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/access?guid="+guid, nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	const guid = "4b9f2c0e-7d1a-4e3b-9c5d-2f6a8b1c0d3e"
+	w := request(guid)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+
+	// Та же запись GUID в другом регистре и в фигурных скобках расходует тот же лимит
+	request(strings.ToUpper(guid))
+	w = request("%7B" + guid + "%7D")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, response.CodeRateLimited, decodeProblem(t, w).Code)
+
+	// Лимит по GUID не мешает другим пользователям с того же адреса
+	assert.Equal(t, http.StatusOK, request("0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f").Code)
+	// Строка, не являющаяся GUID, правило по GUID пропускает
+	assert.Equal(t, http.StatusOK, request("not-a-guid").Code)
+	assert.Equal(t, 4, calls)
+}
+
+func TestAccessRateLimitByIP(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limits := ratelimit.RouteLimits{IP: ratelimit.Limit{Requests: 1, Period: time.Minute}}
+
+	h := handlers.AccessRateLimit(limiter, limits)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/access?guid=g"+string(rune('0'+i)), nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code)
+		if want == http.StatusTooManyRequests {
+			assert.Equal(t, response.CodeIPBlocked, decodeProblem(t, w).Code)
+		}
+	}
+}
+
+func TestRefreshRateLimitKeepsBody(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limits := ratelimit.RouteLimits{GUID: ratelimit.Limit{Requests: 1, Period: time.Minute}}
+	body := `{"guid":"4b9f2c0e-7d1a-4e3b-9c5d-2f6a8b1c0d3e","refresh_token":"dG9rZW4="}`
+
+	h := handlers.RefreshRateLimit(limiter, limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(got))
+	}))
+
+	codes := make([]int, 0, 2)
+	for range 2 {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewBufferString(body)))
+		codes = append(codes, w.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestRefreshRateLimitByCookieGUID(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limits := ratelimit.RouteLimits{GUID: ratelimit.Limit{Requests: 1, Period: time.Minute}}
+	h := handlers.RefreshRateLimit(limiter, limits)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	request := func(guid string) int {
+		refresh := base64.StdEncoding.EncodeToString([]byte(guid + ":secret"))
+		req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: handlers.RefreshCookie, Value: refresh})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Тело пустое, GUID берётся из refresh токена в cookie
+	const guid = "4b9f2c0e-7d1a-4e3b-9c5d-2f6a8b1c0d3e"
+	assert.Equal(t, http.StatusOK, request(guid))
+	assert.Equal(t, http.StatusTooManyRequests, request(guid))
+	assert.Equal(t, http.StatusOK, request("0c1d2e3f-4a5b-4c6d-8e7f-9a0b1c2d3e4f"))
+}
+
+func TestTokenRateLimitUsesOAuthErrors(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	cfg := ratelimit.Config{Refresh: ratelimit.RouteLimits{IP: ratelimit.Limit{Requests: 1, Period: time.Minute}}}
+	h := handlers.TokenRateLimit(limiter, cfg)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	request := func() *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {handlers.GrantRefreshToken}, "refresh_token": {"dG9rZW4="}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, request().Code)
+
+	w := request()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	var body response.OAuthError
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	assert.Equal(t, "temporarily_unavailable", body.Error)
+	assert.NotEmpty(t, body.ErrorDescription)
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limits := ratelimit.RouteLimits{IP: ratelimit.Limit{Requests: 1, Period: time.Minute}}
+	trusted, err := ip.ParsePrefixes([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	h := handlers.ClientIP(ip.NewResolver(trusted))(
+		handlers.AccessRateLimit(limiter, limits)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})))
+
+	request := func(i int, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/access?guid=g%d", i), nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Клиент без прокси меняет порт и X-Forwarded-For, но ключ лимита - адрес соединения
+	for i := range 3 {
+		want := http.StatusTooManyRequests
+		if i == 0 {
+			want = http.StatusOK
+		}
+		assert.Equal(t, want, request(i, fmt.Sprintf("203.0.113.5:%d", 4000+i), fmt.Sprintf("192.0.2.%d", i)))
+	}
+
+	// За доверенным прокси клиент дописывает адреса слева, прокси - его настоящий адрес справа
+	for i := range 3 {
+		want := http.StatusTooManyRequests
+		if i == 0 {
+			want = http.StatusOK
+		}
+		forwardedFor := fmt.Sprintf("192.0.2.%d, 198.51.100.7:%d", i, 5000+i)
+		assert.Equal(t, want, request(i, fmt.Sprintf("10.0.0.1:%d", 6000+i), forwardedFor))
+	}
+
+	// Другой клиент за тем же прокси считается отдельно
+	assert.Equal(t, http.StatusOK, request(9, "10.0.0.1:7000", "198.51.100.8"))
+}
@@ -0,0 +1,313 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+const adminToken = "admin_secret"
+
+func adminRouter(db database.DBInterface) http.Handler {
+	r := chi.NewRouter()
+	r.Use(handlers.AdminAuth(adminToken))
+	r.Post("/admin/users", handlers.CreateUserHandler(db))
+	r.Patch("/admin/users/{guid}", handlers.UpdateUserHandler(db))
+	r.Post("/admin/users/{guid}/lock", handlers.SetUserStatusHandler(db, models.UserStatusLocked))
+	r.Post("/admin/users/{guid}/disable", handlers.SetUserStatusHandler(db, models.UserStatusDisabled))
+	r.Delete("/admin/users/{guid}", handlers.DeleteUserHandler(db))
+	r.Post("/admin/introspect", handlers.IntrospectHandler(db, "test_key"))
+	return r
+}
+
+func adminRequest(method, target string, body any) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	return req
+}
+
+func TestAccessHandlerRefusesUnknownAndDisabledUsers(t *testing.T) {
+	handler := func(db *MockDB) http.HandlerFunc {
+		return handlers.AccessHandler(db, "test_key", 30*time.Minute)
+	}
+
+	t.Run("unknown guid is not created", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New().String()
+		mockDB.On("GetUserByGUID", guid).Return(nil, database.ErrUserNotFound)
+
+		w := httptest.NewRecorder()
+		handler(mockDB).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/access?guid="+guid, nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockDB.AssertNotCalled(t, "UpdateUser", mock.Anything)
+	})
+
+	t.Run("disabled user gets no tokens", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New()
+		mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{
+			UserGUID: guid,
+			Email:    "testuser@example.com",
+			Status:   models.UserStatusDisabled,
+		}, nil)
+
+		w := httptest.NewRecorder()
+		handler(mockDB).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/access?guid="+guid.String(), nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockDB.AssertNotCalled(t, "UpdateUser", mock.Anything)
+	})
+
+	t.Run("email is preserved on issuance", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New()
+		mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{
+			UserGUID: guid,
+			Email:    "testuser@example.com",
+		}, nil)
+		mockDB.On("UpdateUser", mock.MatchedBy(func(u *models.User) bool {
+			return u.Email == "testuser@example.com" && u.HashedRefreshToken != ""
+		})).Return(nil).Once()
+		mockDB.On("CreateSession", mock.MatchedBy(func(s *models.Session) bool {
+			return s.UserGUID == guid && s.ExpiresAt.After(time.Now())
+		})).Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		handler(mockDB).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/access?guid="+guid.String(), nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockDB.AssertExpectations(t)
+	})
+}
+
+func TestAdminUsersAPI(t *testing.T) {
+	t.Run("requests without admin token are rejected", func(t *testing.T) {
+		mockDB := new(MockDB)
+		req := httptest.NewRequest(http.MethodPost, "/admin/users", bytes.NewBufferString(`{"email":"a@example.com"}`))
+		req.Header.Set("Authorization", "Bearer wrong")
+		w := httptest.NewRecorder()
+
+		adminRouter(mockDB).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockDB.AssertNotCalled(t, "CreateUser", mock.Anything)
+	})
+
+	t.Run("create user", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New()
+		mockDB.On("CreateUser", mock.MatchedBy(func(u *models.User) bool {
+			return u.UserGUID == guid && u.Email == "new@example.com"
+		})).Return(nil).Once()
+
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodPost, "/admin/users", response.CreateUser{
+			GUID:  guid.String(),
+			Email: "new@example.com",
+		}))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var body response.User
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.Equal(t, guid.String(), body.GUID)
+		mockDB.AssertExpectations(t)
+	})
+
+	t.Run("create duplicate user", func(t *testing.T) {
+		mockDB := new(MockDB)
+		mockDB.On("CreateUser", mock.Anything).Return(database.ErrUserExists).Once()
+
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodPost, "/admin/users", response.CreateUser{
+			Email: "dup@example.com",
+		}))
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("create user with invalid email", func(t *testing.T) {
+		mockDB := new(MockDB)
+
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodPost, "/admin/users", response.CreateUser{
+			Email: "not an email",
+		}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockDB.AssertNotCalled(t, "CreateUser", mock.Anything)
+	})
+
+	t.Run("update email touches only the email", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New()
+		mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{
+			UserGUID:           guid,
+			Email:              "old@example.com",
+			HashedRefreshToken: "stale-hash",
+		}, nil)
+		mockDB.On("UpdateUserEmail", guid.String(), "new@example.com").Return(nil).Once()
+
+		email := "new@example.com"
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodPatch, "/admin/users/"+guid.String(),
+			response.UpdateUser{Email: &email}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body response.User
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.Equal(t, email, body.Email)
+		// Строка целиком не перезаписывается, refresh токен из параллельного обмена сохраняется
+		mockDB.AssertNotCalled(t, "UpdateUser", mock.Anything)
+		mockDB.AssertExpectations(t)
+	})
+
+	t.Run("disable user", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New()
+		mockDB.On("SetUserStatus", guid.String(), models.UserStatusDisabled, "left the clinic", (*time.Time)(nil)).
+			Return(nil).Once()
+		mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{
+			UserGUID:     guid,
+			Email:        "testuser@example.com",
+			Status:       models.UserStatusDisabled,
+			StatusReason: "left the clinic",
+		}, nil)
+
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodPost, "/admin/users/"+guid.String()+"/disable",
+			response.UserStatusChange{Reason: "left the clinic"}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body response.User
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		assert.Equal(t, "disabled", body.Status)
+		mockDB.AssertExpectations(t)
+	})
+
+	t.Run("lock with end in the past is rejected", func(t *testing.T) {
+		mockDB := new(MockDB)
+		past := time.Now().Add(-time.Hour)
+
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodPost, "/admin/users/"+uuid.New().String()+"/lock",
+			response.UserStatusChange{Reason: "suspicious activity", Until: &past}))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockDB.AssertNotCalled(t, "SetUserStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("delete unknown user", func(t *testing.T) {
+		mockDB := new(MockDB)
+		guid := uuid.New().String()
+		mockDB.On("DeleteUser", guid).Return(database.ErrUserNotFound).Once()
+
+		w := httptest.NewRecorder()
+		adminRouter(mockDB).ServeHTTP(w, adminRequest(http.MethodDelete, "/admin/users/"+guid, nil))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestLockedUserCannotRefresh(t *testing.T) {
+	mockDB := new(MockDB)
+	guid := uuid.New()
+	until := time.Now().Add(time.Hour)
+	mockDB.On("GetUserByGUID", guid.String()).Return(&models.User{
+		UserGUID:           guid,
+		HashedRefreshToken: "someHashedToken",
+		Status:             models.UserStatusLocked,
+		StatusUntil:        &until,
+	}, nil)
+
+	body, _ := json.Marshal(response.RefreshToken{GUID: guid.String(), RefreshToken: "c29tZVRva2Vu"})
+	w := httptest.NewRecorder()
+	handlers.RefreshHandler(mockDB, "test_key", 30*time.Minute).
+		ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewBuffer(body)))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	mockDB.AssertNotCalled(t, "UpdateUser", mock.Anything)
+}
+
+func TestExpiredLockIsLifted(t *testing.T) {
+	until := time.Now().Add(-time.Minute)
+	user := &models.User{Status: models.UserStatusLocked, StatusUntil: &until}
+	assert.True(t, user.IsActive(time.Now()))
+
+	user.StatusUntil = nil
+	assert.False(t, user.IsActive(time.Now()))
+}
+
+func TestIntrospectRevokedToken(t *testing.T) {
+	tokens, err := jwt.NewTokens(context.Background(), "test_key",
+		jwt.Params{GUID: uuid.New().String(), IP: "127.0.0.1", TTL: time.Hour})
+	assert.NoError(t, err)
+
+	introspect := func(db *MockDB) response.Introspection {
+		req := adminRequest(http.MethodPost, "/admin/introspect", nil)
+		req.Body = io.NopCloser(strings.NewReader(url.Values{"token": {tokens.Access}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		adminRouter(db).ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body response.Introspection
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		return body
+	}
+
+	active := new(MockDB)
+	active.On("IsTokenRevoked", tokens.ID).Return(false, nil)
+	assert.True(t, introspect(active).Active)
+
+	revoked := new(MockDB)
+	revoked.On("IsTokenRevoked", tokens.ID).Return(true, nil)
+	assert.False(t, introspect(revoked).Active)
+}
+
+func TestIntrospectAfterKeyRotation(t *testing.T) {
+	tokens, err := jwt.NewTokens(context.Background(), "old_key",
+		jwt.Params{GUID: uuid.New().String(), IP: "127.0.0.1", TTL: time.Hour})
+	assert.NoError(t, err)
+
+	introspect := func(opts ...handlers.Option) bool {
+		db := new(MockDB)
+		db.On("IsTokenRevoked", tokens.ID).Return(false, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/admin/introspect",
+			strings.NewReader(url.Values{"token": {tokens.Access}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handlers.IntrospectHandler(db, "new_key", opts...).ServeHTTP(w, req)
+
+		var body response.Introspection
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		return body.Active
+	}
+
+	assert.False(t, introspect(), "the new key alone rejects tokens signed with the old one")
+	assert.True(t, introspect(handlers.WithPreviousKeys("old_key")))
+	assert.False(t, introspect(handlers.WithPreviousKeys("", "other_key")))
+}
@@ -1,6 +1,7 @@
 package unit_tests
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -27,11 +28,52 @@ type AMockDB struct {
 	mock.Mock
 }
 
-func (m *AMockDB) UpdateUser(user *models.User) error {
+func (m *AMockDB) CreateUser(_ context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
+func (m *AMockDB) ListUsers(_ context.Context, limit, offset int) ([]models.User, error) {
+	args := m.Called(limit, offset)
+	users, _ := args.Get(0).([]models.User)
+	return users, args.Error(1)
+}
+
+func (m *AMockDB) DeleteUser(_ context.Context, guid string) error {
+	args := m.Called(guid)
+	return args.Error(0)
+}
+
+func (m *AMockDB) SetUserStatus(_ context.Context, guid string, status models.UserStatus, reason string, until *time.Time) error {
+	args := m.Called(guid, status, reason, until)
+	return args.Error(0)
+}
+
+func (m *AMockDB) CreateSession(_ context.Context, session *models.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *AMockDB) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *AMockDB) UpdateUser(_ context.Context, user *models.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *AMockDB) UpdateUserEmail(_ context.Context, guid, email string) error {
+	args := m.Called(guid, email)
+	return args.Error(0)
+}
+
+func (m *AMockDB) RotateRefreshToken(_ context.Context, user *models.User, previousHash string) error {
+	args := m.Called(user, previousHash)
+	return args.Error(0)
+}
+
 func setupTestData(db *sql.DB, guid string) error {
 	hashedRefreshToken, err := bcrypt.GenerateFromPassword([]byte("someRefreshToken"), bcrypt.DefaultCost)
 	if err != nil {
@@ -57,7 +99,7 @@ func teardownTestData(db *sql.DB, guid string) error {
 	return nil
 }
 
-func (m *AMockDB) GetUserByGUID(guid string) (*models.User, error) {
+func (m *AMockDB) GetUserByGUID(_ context.Context, guid string) (*models.User, error) {
 	args := m.Called(guid)
 	if user, ok := args.Get(0).(*models.User); ok {
 		return user, args.Error(1)
@@ -131,6 +173,7 @@ func TestAccessHandler(t *testing.T) {
 		}, nil)
 
 		mockDB.On("UpdateUser", mock.Anything).Return(nil)
+		mockDB.On("CreateSession", mock.Anything).Return(nil)
 
 		req := httptest.NewRequest(http.MethodGet, "/access?guid="+guid, nil)
 		w := httptest.NewRecorder()
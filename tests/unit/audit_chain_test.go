@@ -0,0 +1,120 @@
+package unit_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/audit"
+	"github.com/volchok96/auth-medods/internal/database/models"
+)
+
+// memoryAuditLog - журнал аудита в памяти, повторяющий порядок выдачи записей PostgreSQL
+type memoryAuditLog struct {
+	events []models.AuditEvent
+}
+
+func (m *memoryAuditLog) append(e models.AuditEvent) {
+	prevHash := ""
+	if len(m.events) > 0 {
+		prevHash = m.events[len(m.events)-1].Hash
+	}
+
+	e.ID = int64(len(m.events) + 1)
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	e.PrevHash = prevHash
+	e.Hash = audit.Hash(prevHash, &e)
+	m.events = append(m.events, e)
+}
+
+func (m *memoryAuditLog) AuditEventsAfter(_ context.Context, afterID int64, limit int) ([]models.AuditEvent, error) {
+	var page []models.AuditEvent
+	for _, e := range m.events {
+		if e.ID > afterID && len(page) < limit {
+			page = append(page, e)
+		}
+	}
+	return page, nil
+}
+
+func newAuditLog(t *testing.T, n int) *memoryAuditLog {
+	log := &memoryAuditLog{}
+	guid := uuid.New()
+	for i := 0; i < n; i++ {
+		log.append(models.AuditEvent{
+			Type:     audit.EventTokenIssued,
+			Actor:    audit.ActorClient,
+			UserGUID: uuid.NullUUID{UUID: guid, Valid: true},
+			IP:       "127.0.0.1",
+			Outcome:  audit.OutcomeSuccess,
+		})
+	}
+	return log
+}
+
+func TestAuditChainVerify(t *testing.T) {
+	t.Run("intact chain across batches", func(t *testing.T) {
+		res, err := audit.Verify(context.Background(), newAuditLog(t, 7), 3)
+		require.NoError(t, err)
+
+		assert.True(t, res.OK())
+		assert.Equal(t, 7, res.Checked)
+		assert.Equal(t, int64(1), res.FirstID)
+		assert.Equal(t, int64(7), res.LastID)
+		assert.Empty(t, res.AnchorHash)
+	})
+
+	t.Run("modified record", func(t *testing.T) {
+		log := newAuditLog(t, 5)
+		log.events[2].Outcome = audit.OutcomeFailure
+
+		res, err := audit.Verify(context.Background(), log, 2)
+		require.NoError(t, err)
+
+		assert.False(t, res.OK())
+		assert.Equal(t, int64(3), res.BrokenID)
+		assert.Equal(t, 2, res.Checked)
+	})
+
+	t.Run("deleted record", func(t *testing.T) {
+		log := newAuditLog(t, 5)
+		log.events = append(log.events[:3], log.events[4:]...)
+
+		res, err := audit.Verify(context.Background(), log, 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(5), res.BrokenID)
+	})
+
+	t.Run("rehashed record breaks the next link", func(t *testing.T) {
+		log := newAuditLog(t, 4)
+		log.events[1].Details = "forged"
+		log.events[1].Hash = audit.Hash(log.events[1].PrevHash, &log.events[1])
+
+		res, err := audit.Verify(context.Background(), log, 10)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(3), res.BrokenID)
+	})
+
+	t.Run("pruned head and legacy records", func(t *testing.T) {
+		log := newAuditLog(t, 5)
+		log.events = log.events[2:]
+		res, err := audit.Verify(context.Background(), log, 10)
+		require.NoError(t, err)
+		assert.True(t, res.OK())
+		assert.Equal(t, log.events[0].PrevHash, res.AnchorHash)
+
+		legacy := &memoryAuditLog{events: []models.AuditEvent{{ID: 1, Type: audit.EventTokenIssued}}}
+		legacy.append(models.AuditEvent{Type: audit.EventTokenIssued})
+		res, err = audit.Verify(context.Background(), legacy, 10)
+		require.NoError(t, err)
+		assert.True(t, res.OK())
+		assert.Equal(t, 1, res.Unchained)
+		assert.Equal(t, 1, res.Checked)
+	})
+}
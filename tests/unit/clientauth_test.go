@@ -0,0 +1,287 @@
+package unit_tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/database"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+	"github.com/volchok96/auth-medods/internal/replay"
+)
+
+// fakeClientStore хранит клиентов в памяти
+type fakeClientStore struct {
+	clients map[string]*models.Client
+}
+
+func newFakeClientStore(clients ...*models.Client) *fakeClientStore {
+	s := &fakeClientStore{clients: map[string]*models.Client{}}
+	for _, c := range clients {
+		s.clients[c.ClientID] = c
+	}
+	return s
+}
+
+func (s *fakeClientStore) CreateClient(_ context.Context, c *models.Client) error {
+	s.clients[c.ClientID] = c
+	return nil
+}
+
+func (s *fakeClientStore) UpdateClient(_ context.Context, c *models.Client) error {
+	s.clients[c.ClientID] = c
+	return nil
+}
+
+func (s *fakeClientStore) GetClient(_ context.Context, id string) (*models.Client, error) {
+	if c, ok := s.clients[id]; ok {
+		return c, nil
+	}
+	return nil, database.ErrClientNotFound
+}
+
+func (s *fakeClientStore) GetClientByThumbprint(_ context.Context, thumbprint string) (*models.Client, error) {
+	for _, c := range s.clients {
+		if c.CertThumbprint == thumbprint {
+			return c, nil
+		}
+	}
+	return nil, database.ErrClientNotFound
+}
+
+func (s *fakeClientStore) ListClients(_ context.Context) ([]models.Client, error) {
+	var list []models.Client
+	for _, c := range s.clients {
+		list = append(list, *c)
+	}
+	return list, nil
+}
+
+func (s *fakeClientStore) DeleteClient(_ context.Context, id string) error {
+	delete(s.clients, id)
+	return nil
+}
+
+func TestSecretMethod(t *testing.T) {
+	secret, hash, err := clientauth.GenerateSecret()
+	require.NoError(t, err)
+	method := clientauth.NewSecretMethod(newFakeClientStore(&models.Client{ClientID: "spa", SecretHash: hash}))
+
+	req := httptest.NewRequest(http.MethodGet, "/access", nil)
+	_, err = method.Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrNoCredentials)
+
+	req.SetBasicAuth("spa", secret)
+	client, err := method.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "spa", client.ClientID)
+
+	req.SetBasicAuth("spa", "wrong")
+	_, err = method.Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+
+	req.SetBasicAuth("unknown", secret)
+	_, err = method.Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+
+	// client_secret_post
+	form := url.Values{"client_id": {"spa"}, "client_secret": {secret}}
+	req = httptest.NewRequest(http.MethodPost, "/access", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client, err = method.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "spa", client.ClientID)
+}
+
+func selfSignedCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "frontend"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func TestMTLSMethod(t *testing.T) {
+	cert := selfSignedCert(t)
+	store := newFakeClientStore(&models.Client{ClientID: "gateway", CertThumbprint: clientauth.Thumbprint(cert)})
+	trusted, err := ip.ParsePrefixes([]string{"10.0.0.1"})
+	require.NoError(t, err)
+	proxies := ip.NewResolver(trusted)
+
+	req := httptest.NewRequest(http.MethodGet, "/access", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	client, err := clientauth.NewMTLSMethod(store, "", nil).Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "gateway", client.ClientID)
+
+	// Сертификат от прокси принимается только из настроенного заголовка
+	pemCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	req = httptest.NewRequest(http.MethodGet, "/access", nil)
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.Header.Set("X-Client-Cert", url.QueryEscape(string(pemCert)))
+
+	_, err = clientauth.NewMTLSMethod(store, "", proxies).Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrNoCredentials)
+
+	client, err = clientauth.NewMTLSMethod(store, "X-Client-Cert", proxies).Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "gateway", client.ClientID)
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{selfSignedCert(t)}}
+	_, err = clientauth.NewMTLSMethod(store, "", nil).Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+}
+
+func TestMTLSMethodIgnoresHeaderFromUntrustedSource(t *testing.T) {
+	cert := selfSignedCert(t)
+	store := newFakeClientStore(&models.Client{ClientID: "gateway", CertThumbprint: clientauth.Thumbprint(cert)})
+	trusted, err := ip.ParsePrefixes([]string{"10.0.0.1"})
+	require.NoError(t, err)
+	method := clientauth.NewMTLSMethod(store, "X-Client-Cert", ip.NewResolver(trusted))
+
+	pemCert := url.QueryEscape(string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+
+	// Клиент напрямую, минуя прокси
+	req := httptest.NewRequest(http.MethodGet, "/access", nil)
+	req.RemoteAddr = "203.0.113.5:4000"
+	req.Header.Set("X-Client-Cert", pemCert)
+	_, err = method.Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrNoCredentials)
+
+	// Доверенный адрес, но TLS завершён самим сервисом: заголовок не от прокси
+	req.RemoteAddr = "10.0.0.1:4000"
+	req.TLS = &tls.ConnectionState{}
+	_, err = method.Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrNoCredentials)
+
+	// Без списка прокси заголовок не принимается ни от кого
+	req.TLS = nil
+	_, err = clientauth.NewMTLSMethod(store, "X-Client-Cert", nil).Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrNoCredentials)
+}
+
+func TestAssertionMethod(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.True(t, clientauth.ValidPublicKey(publicKey))
+
+	store := newFakeClientStore(&models.Client{ClientID: "svc", PublicKey: publicKey})
+	replayStore := replay.NewCache()
+	method := clientauth.NewAssertionMethod(store, "https://auth.example.com", replayStore)
+
+	sign := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+	request := func(assertion string) *http.Request {
+		q := url.Values{"client_assertion_type": {clientauth.AssertionType}, "client_assertion": {assertion}}
+		return httptest.NewRequest(http.MethodGet, "/access?"+q.Encode(), nil)
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "svc",
+			"sub": "svc",
+			"aud": "https://auth.example.com",
+			"jti": uuid.NewString(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+	}
+
+	valid := sign(claims())
+	client, err := method.Authenticate(request(valid))
+	require.NoError(t, err)
+	assert.Equal(t, "svc", client.ClientID)
+
+	_, err = method.Authenticate(request(valid))
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials, "replayed jti")
+
+	// Реплики с общим хранилищем видят jti друг друга
+	otherReplica := clientauth.NewAssertionMethod(store, "https://auth.example.com", replayStore)
+	_, err = otherReplica.Authenticate(request(valid))
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials, "jti replayed on another replica")
+
+	// Недоступное хранилище - ошибка сервиса, а не неверные данные клиента
+	unavailable := clientauth.NewAssertionMethod(store, "https://auth.example.com", failingReplayStore{})
+	_, err = unavailable.Authenticate(request(sign(claims())))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, clientauth.ErrInvalidCredentials)
+
+	wrongAudience := claims()
+	wrongAudience["aud"] = "https://other.example.com"
+	_, err = method.Authenticate(request(sign(wrongAudience)))
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+
+	longLived := claims()
+	longLived["exp"] = time.Now().Add(time.Hour).Unix()
+	_, err = method.Authenticate(request(sign(longLived)))
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodES256, claims()).SignedString(otherKey)
+	_, err = method.Authenticate(request(forged))
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	_, err = method.Authenticate(request(unsigned))
+	assert.ErrorIs(t, err, clientauth.ErrInvalidCredentials)
+}
+
+func TestClientAuthChain(t *testing.T) {
+	_, err := clientauth.New(newFakeClientStore(), clientauth.Config{Methods: []string{"password"}})
+	assert.Error(t, err)
+
+	_, err = clientauth.New(newFakeClientStore(), clientauth.Config{Methods: []string{clientauth.MethodAssertion}})
+	assert.Error(t, err, "assertion requires an audience")
+
+	auth, err := clientauth.New(newFakeClientStore(), clientauth.Config{Methods: []string{clientauth.MethodNone}})
+	require.NoError(t, err)
+	assert.Nil(t, auth)
+
+	secret, hash, err := clientauth.GenerateSecret()
+	require.NoError(t, err)
+	auth, err = clientauth.New(newFakeClientStore(&models.Client{ClientID: "spa", SecretHash: hash}),
+		clientauth.Config{Methods: []string{clientauth.MethodMTLS, clientauth.MethodSecret}})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/access", nil)
+	_, err = auth.Authenticate(req)
+	assert.ErrorIs(t, err, clientauth.ErrNoCredentials)
+
+	req.SetBasicAuth("spa", secret)
+	client, err := auth.Authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "spa", client.ClientID)
+}
@@ -0,0 +1,230 @@
+package unit_tests
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+// baseEnv - минимальный набор обязательных настроек
+func baseEnv() map[string]string {
+	return map[string]string{
+		"OWN_KEY": "secret",
+		"DB_USER": "postgres",
+		"DB_NAME": "auth",
+	}
+}
+
+func lookup(env map[string]string) config.LookupEnv {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg, args, err := config.Load(nil, lookup(baseEnv()))
+	require.NoError(t, err)
+	assert.Empty(t, args)
+
+	assert.Equal(t, ":8080", cfg.Server.Addr)
+	assert.Equal(t, 5*time.Second, cfg.Server.DrainDelay)
+	assert.Equal(t, 20*time.Second, cfg.Server.ShutdownTimeout)
+	assert.Equal(t, 30*time.Minute, cfg.Token.TTL)
+	assert.Equal(t, "localhost", cfg.Database.Host)
+	assert.Equal(t, 5432, cfg.Database.Port)
+	assert.Equal(t, "require", cfg.Database.SSLMode)
+	assert.Equal(t, []string{"secret"}, cfg.ClientAuth.Methods)
+	assert.Equal(t, ratelimit.Limit{Requests: 30, Period: time.Minute}, cfg.RateLimit.AccessIP)
+	assert.Equal(t, 7*24*time.Hour, cfg.Retention.RefreshLockouts)
+	assert.False(t, cfg.Cookies.Enabled)
+	assert.Equal(t, "none", cfg.Tracing.Exporter)
+	assert.Equal(t, "auth-medods", cfg.Tracing.ServiceName)
+}
+
+func TestConfigSourcePrecedence(t *testing.T) {
+	file := writeFile(t, "auth.yaml", `
+token:
+  ttl: 10m
+db:
+  host: file-host
+  port: 6432
+client_auth:
+  methods: [secret, mtls]
+`)
+
+	env := baseEnv()
+	env["DB_HOST"] = "env-host"
+	env["CONFIG_FILE"] = file
+
+	cfg, args, err := config.Load([]string{"-db-host", "flag-host", "cleanup"}, lookup(env))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"cleanup"}, args)
+	assert.Equal(t, "flag-host", cfg.Database.Host, "flags override the environment")
+	assert.Equal(t, 6432, cfg.Database.Port, "file overrides defaults")
+	assert.Equal(t, 10*time.Minute, cfg.Token.TTL)
+	assert.Equal(t, []string{"secret", "mtls"}, cfg.ClientAuth.Methods)
+
+	delete(env, "CONFIG_FILE")
+	cfg, _, err = config.Load([]string{"-config", file}, lookup(env))
+	require.NoError(t, err)
+	assert.Equal(t, "env-host", cfg.Database.Host, "environment overrides the file")
+}
+
+func TestConfigTOMLFile(t *testing.T) {
+	file := writeFile(t, "auth.toml", `
+# Настройки сервиса
+[token]
+own_key = "from # toml"
+ttl = "15m"
+
+[db]
+user = 'postgres'
+name = "auth"
+port = 5433
+
+[client_auth]
+methods = ["secret", "assertion"]
+assertion_audience = "https://auth.example.com"
+
+[cookies]
+enabled = true # браузерные клиенты
+`)
+
+	cfg, _, err := config.Load([]string{"-config", file}, lookup(map[string]string{}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "from # toml", cfg.Token.OwnKey.Value())
+	assert.Equal(t, 15*time.Minute, cfg.Token.TTL)
+	assert.Equal(t, 5433, cfg.Database.Port)
+	assert.Equal(t, []string{"secret", "assertion"}, cfg.ClientAuth.Methods)
+	assert.True(t, cfg.Cookies.Enabled)
+
+	// Синтаксис TOML проверяет библиотека, а списки таблиц некуда разложить по ключам
+	for _, data := range []string{"[token\nown_key = 1", "[[db]]\nhost = \"a\""} {
+		_, _, err = config.Load([]string{"-config", writeFile(t, "bad.toml", data)}, lookup(map[string]string{}))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestConfigSecretFiles(t *testing.T) {
+	env := baseEnv()
+	delete(env, "OWN_KEY")
+	env["OWN_KEY_FILE"] = writeFile(t, "own_key", "from-file\n")
+
+	cfg, _, err := config.Load(nil, lookup(env))
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", cfg.Token.OwnKey.Value())
+
+	env["OWN_KEY"] = "direct"
+	_, _, err = config.Load(nil, lookup(env))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "both OWN_KEY and OWN_KEY_FILE are set")
+}
+
+func TestConfigDotenvFile(t *testing.T) {
+	env := baseEnv()
+	env["ENV_FILE"] = writeFile(t, ".env", "DB_HOST=dotenv-host\nDB_NAME=dotenv\n")
+
+	cfg, _, err := config.Load(nil, lookup(env))
+	require.NoError(t, err)
+	assert.Equal(t, "dotenv-host", cfg.Database.Host)
+	assert.Equal(t, "auth", cfg.Database.Name, "the environment overrides .env")
+
+	env["ENV_FILE"] = filepath.Join(t.TempDir(), "missing.env")
+	_, _, err = config.Load(nil, lookup(env))
+	assert.Error(t, err, "an explicit ENV_FILE must exist")
+}
+
+func TestConfigReportsEveryInvalidField(t *testing.T) {
+	file := writeFile(t, "auth.yaml", "db:\n  hostname: typo\n")
+	env := map[string]string{
+		"TOKEN_TTL":            "soon",
+		"DB_PORT":              "70000",
+		"RATE_LIMIT_ACCESS_IP": "many",
+		"CLIENT_AUTH":          "secret,kerberos",
+		"TRACING_EXPORTER":     "jaeger",
+		"SHUTDOWN_TIMEOUT":     "0s",
+		"TLS_CLIENT_AUTH":      "require",
+		"CONFIG_FILE":          file,
+	}
+
+	_, _, err := config.Load(nil, lookup(env))
+	require.Error(t, err)
+
+	var errs *config.Errors
+	require.True(t, errors.As(err, &errs))
+
+	var keys []string
+	for _, fe := range *errs {
+		keys = append(keys, fe.Key)
+	}
+	assert.ElementsMatch(t, []string{
+		"token.own_key", "token.ttl", "db.user", "db.name", "db.port", "db.hostname",
+		"rate_limit.access_ip", "client_auth.methods", "server.shutdown_timeout", "tracing.exporter",
+		"tls.client_auth", "tls.client_ca",
+	}, keys)
+	assert.Contains(t, err.Error(), `token.ttl (TOKEN_TTL): invalid duration "soon"`)
+	assert.Contains(t, err.Error(), "db.hostname from "+file+": unknown key")
+}
+
+func TestConfigRetentionIntervalZeroDisablesCleanup(t *testing.T) {
+	env := baseEnv()
+	env["RETENTION_INTERVAL"] = "0"
+	cfg, _, err := config.Load(nil, lookup(env))
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Retention.Interval)
+
+	env["RETENTION_INTERVAL"] = "-1m"
+	_, _, err = config.Load(nil, lookup(env))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "retention.interval (RETENTION_INTERVAL): must not be negative")
+}
+
+func TestConfigDebugEndpointsRequireAdminToken(t *testing.T) {
+	env := baseEnv()
+	env["DEBUG_ENDPOINTS"] = "true"
+	_, _, err := config.Load(nil, lookup(env))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server.debug_endpoints (DEBUG_ENDPOINTS): requires admin.token")
+
+	env["ADMIN_TOKEN"] = "admin"
+	_, _, err = config.Load(nil, lookup(env))
+	require.NoError(t, err)
+}
+
+func TestDatabaseDSN(t *testing.T) {
+	db := config.Database{
+		Host:     "db.internal",
+		Port:     5432,
+		User:     "auth",
+		Password: "p@ss:w/rd",
+		Name:     "auth",
+		SSLMode:  "verify-full",
+	}
+
+	u, err := url.Parse(db.DSN())
+	require.NoError(t, err)
+	password, _ := u.User.Password()
+	assert.Equal(t, "p@ss:w/rd", password)
+	assert.Equal(t, "db.internal:5432", u.Host)
+	assert.Equal(t, "/auth", u.Path)
+	assert.Equal(t, "verify-full", u.Query().Get("sslmode"))
+}
@@ -0,0 +1,167 @@
+package unit_tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+func findCookie(resp *http.Response, name string) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestRefreshHandlerCookies(t *testing.T) {
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		IP:                 "192.0.2.1",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.RefreshHandler(mockDB, "test_key", time.Minute,
+		handlers.WithCookies(&handlers.Cookies{MaxAge: time.Hour}))
+
+	// Браузер отправляет refresh cookie без тела запроса
+	post := func(csrfCookie, csrfHeader string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.AddCookie(&http.Cookie{
+			Name:  handlers.RefreshCookie,
+			Value: base64.StdEncoding.EncodeToString([]byte(refreshToken)),
+		})
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: handlers.CSRFCookie, Value: csrfCookie})
+		}
+		if csrfHeader != "" {
+			req.Header.Set(handlers.CSRFHeader, csrfHeader)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("missing CSRF header", func(t *testing.T) {
+		resp := post("csrf", "")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		var problem response.Problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, response.CodeCSRFMismatch, problem.Code)
+	})
+
+	t.Run("mismatched CSRF header", func(t *testing.T) {
+		resp := post("csrf", "other")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("matching CSRF header", func(t *testing.T) {
+		resp := post("csrf", "csrf")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body response.UserResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.NotEmpty(t, body.AccessToken)
+		assert.Empty(t, body.GetRefreshToken, "refresh token must stay out of the body")
+
+		// Cookie ставится и на устаревший /refresh без версии
+		var paths []string
+		for _, refresh := range resp.Cookies() {
+			if refresh.Name != handlers.RefreshCookie {
+				continue
+			}
+			paths = append(paths, refresh.Path)
+			assert.True(t, refresh.HttpOnly)
+			assert.True(t, refresh.Secure)
+			assert.Equal(t, http.SameSiteStrictMode, refresh.SameSite)
+			assert.Equal(t, 3600, refresh.MaxAge)
+		}
+		assert.ElementsMatch(t, []string{"/api/v1/refresh", "/refresh"}, paths)
+
+		csrf := findCookie(resp, handlers.CSRFCookie)
+		require.NotNil(t, csrf)
+		assert.False(t, csrf.HttpOnly)
+		assert.NotEqual(t, "csrf", csrf.Value)
+	})
+}
+
+func TestRefreshHandlerCookieDeliveryIsOptIn(t *testing.T) {
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	// Клиент передаёт refresh токен в теле, как до включения cookie
+	post := func(delivery string) *http.Response {
+		mockDB := new(RMockDB)
+		mockDB.On("GetUserByGUID", guid).Return(&models.User{
+			UserGUID:           uuid.MustParse(guid),
+			HashedRefreshToken: string(hashedToken),
+			IP:                 "192.0.2.1",
+		}, nil)
+		mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+		mockDB.On("CreateSession", mock.Anything).Return(nil)
+		handler := handlers.RefreshHandler(mockDB, "test_key", time.Minute,
+			handlers.WithCookies(&handlers.Cookies{}))
+
+		body, err := json.Marshal(response.RefreshToken{
+			GUID:         guid,
+			RefreshToken: base64.StdEncoding.EncodeToString([]byte(refreshToken)),
+		})
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body))
+		req.RemoteAddr = "192.0.2.1:1234"
+		if delivery != "" {
+			req.Header.Set(handlers.TokenDeliveryHeader, delivery)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("body token without opt-in", func(t *testing.T) {
+		resp := post("")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body response.UserResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.NotEmpty(t, body.GetRefreshToken)
+		assert.Nil(t, findCookie(resp, handlers.RefreshCookie))
+	})
+
+	t.Run("body token with opt-in header", func(t *testing.T) {
+		resp := post(handlers.TokenDeliveryCookie)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body response.UserResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Empty(t, body.GetRefreshToken)
+		assert.NotNil(t, findCookie(resp, handlers.RefreshCookie))
+	})
+}
@@ -0,0 +1,251 @@
+package unit_tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/dpop"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/replay"
+)
+
+const dpopBaseURL = "https://auth.example.com"
+
+// dpopKey - ключ клиента, которым подписываются proof
+type dpopKey struct {
+	key *ecdsa.PrivateKey
+	jwk map[string]any
+}
+
+func newDPoPKey(t *testing.T) *dpopKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	coord := func(b []byte) string {
+		padded := make([]byte, 32)
+		copy(padded[32-len(b):], b)
+		return base64.RawURLEncoding.EncodeToString(padded)
+	}
+	return &dpopKey{key: key, jwk: map[string]any{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   coord(key.X.Bytes()),
+		"y":   coord(key.Y.Bytes()),
+	}}
+}
+
+// thumbprint - отпечаток ключа по RFC 7638
+func (k *dpopKey) thumbprint(t *testing.T) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{"crv": k.jwk["crv"], "kty": k.jwk["kty"], "x": k.jwk["x"], "y": k.jwk["y"]})
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (k *dpopKey) proof(t *testing.T, method, path string, iat time.Time, accessToken string) string {
+	t.Helper()
+	claims := gojwt.MapClaims{
+		"htm": method,
+		"htu": dpopBaseURL + path,
+		"iat": iat.Unix(),
+		"jti": uuid.New().String(),
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	token := gojwt.NewWithClaims(gojwt.SigningMethodES256, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = k.jwk
+	signed, err := token.SignedString(k.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestDPoPVerifier(t *testing.T) {
+	verifier := dpop.NewVerifier(dpop.Config{BaseURL: dpopBaseURL})
+	key := newDPoPKey(t)
+
+	request := func(method, path, proof string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		if proof != "" {
+			req.Header.Set(dpop.Header, proof)
+		}
+		return req
+	}
+
+	t.Run("valid proof", func(t *testing.T) {
+		jkt, err := verifier.Verify(request(http.MethodPost, "/oauth/token",
+			key.proof(t, http.MethodPost, "/oauth/token", time.Now(), "")), "")
+		require.NoError(t, err)
+		assert.Equal(t, key.thumbprint(t), jkt)
+	})
+
+	t.Run("missing proof", func(t *testing.T) {
+		_, err := verifier.Verify(request(http.MethodPost, "/oauth/token", ""), "")
+		assert.ErrorIs(t, err, dpop.ErrNoProof)
+	})
+
+	t.Run("replayed proof", func(t *testing.T) {
+		proof := key.proof(t, http.MethodPost, "/oauth/token", time.Now(), "")
+		_, err := verifier.Verify(request(http.MethodPost, "/oauth/token", proof), "")
+		require.NoError(t, err)
+		_, err = verifier.Verify(request(http.MethodPost, "/oauth/token", proof), "")
+		assert.ErrorIs(t, err, dpop.ErrInvalidProof)
+	})
+
+	t.Run("other method or url", func(t *testing.T) {
+		_, err := verifier.Verify(request(http.MethodGet, "/oauth/token",
+			key.proof(t, http.MethodPost, "/oauth/token", time.Now(), "")), "")
+		assert.ErrorIs(t, err, dpop.ErrInvalidProof)
+
+		_, err = verifier.Verify(request(http.MethodPost, "/refresh",
+			key.proof(t, http.MethodPost, "/oauth/token", time.Now(), "")), "")
+		assert.ErrorIs(t, err, dpop.ErrInvalidProof)
+	})
+
+	t.Run("stale proof", func(t *testing.T) {
+		_, err := verifier.Verify(request(http.MethodPost, "/oauth/token",
+			key.proof(t, http.MethodPost, "/oauth/token", time.Now().Add(-5*time.Minute), "")), "")
+		assert.ErrorIs(t, err, dpop.ErrInvalidProof)
+	})
+
+	t.Run("access token hash", func(t *testing.T) {
+		_, err := verifier.Verify(request(http.MethodGet, "/userinfo",
+			key.proof(t, http.MethodGet, "/userinfo", time.Now(), "token")), "token")
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(request(http.MethodGet, "/userinfo",
+			key.proof(t, http.MethodGet, "/userinfo", time.Now(), "other")), "token")
+		assert.ErrorIs(t, err, dpop.ErrInvalidProof)
+	})
+}
+
+// failingReplayStore - недоступное хранилище использованных jti
+type failingReplayStore struct{}
+
+func (failingReplayStore) AddReplayKey(context.Context, string, time.Time, time.Time) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestDPoPReplayStoreIsSharedBetweenReplicas(t *testing.T) {
+	store := replay.NewCache()
+	first := dpop.NewVerifier(dpop.Config{BaseURL: dpopBaseURL, Replay: store})
+	second := dpop.NewVerifier(dpop.Config{BaseURL: dpopBaseURL, Replay: store})
+	key := newDPoPKey(t)
+
+	request := func(proof string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", nil)
+		req.Header.Set(dpop.Header, proof)
+		return req
+	}
+
+	proof := key.proof(t, http.MethodPost, "/oauth/token", time.Now(), "")
+	_, err := first.Verify(request(proof), "")
+	require.NoError(t, err)
+	_, err = second.Verify(request(proof), "")
+	assert.ErrorIs(t, err, dpop.ErrInvalidProof, "a proof replayed on another replica is rejected")
+
+	broken := dpop.NewVerifier(dpop.Config{BaseURL: dpopBaseURL, Replay: failingReplayStore{}})
+	_, err = broken.Verify(request(key.proof(t, http.MethodPost, "/oauth/token", time.Now(), "")), "")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, dpop.ErrInvalidProof, "storage failure is not the client's fault")
+}
+
+func TestTokenHandlerBindsTokensToDPoPKey(t *testing.T) {
+	ownKey := "test_key"
+	guid := uuid.New().String()
+	key := newDPoPKey(t)
+
+	// Мок возвращает один и тот же указатель, поэтому привязка refresh токена
+	// сохраняется между запросами, как в базе
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{UserGUID: uuid.MustParse(guid)}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.TokenHandler(mockDB, ownKey, time.Minute,
+		handlers.WithDPoP(dpop.NewVerifier(dpop.Config{BaseURL: dpopBaseURL})))
+
+	post := func(form url.Values, proof string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if proof != "" {
+			req.Header.Set(dpop.Header, proof)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := post(url.Values{"grant_type": {handlers.GrantGUID}, "guid": {guid}},
+		key.proof(t, http.MethodPost, "/oauth/token", time.Now(), ""))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body response.TokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "DPoP", body.TokenType)
+
+	claims, err := jwt.ParseAccessToken(body.AccessToken, ownKey)
+	require.NoError(t, err)
+	assert.Equal(t, key.thumbprint(t), claims.JKT)
+
+	refresh := url.Values{"grant_type": {handlers.GrantRefreshToken}, "refresh_token": {body.RefreshToken}}
+
+	t.Run("refresh without a proof", func(t *testing.T) {
+		resp := post(refresh, "")
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_dpop_proof", decodeOAuthError(t, resp).Error)
+	})
+
+	t.Run("refresh with another key", func(t *testing.T) {
+		resp := post(refresh, newDPoPKey(t).proof(t, http.MethodPost, "/oauth/token", time.Now(), ""))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_dpop_proof", decodeOAuthError(t, resp).Error)
+	})
+
+	t.Run("refresh with the bound key", func(t *testing.T) {
+		resp := post(refresh, key.proof(t, http.MethodPost, "/oauth/token", time.Now(), ""))
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body response.TokenResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, "DPoP", body.TokenType)
+
+		claims, err := jwt.ParseAccessToken(body.AccessToken, ownKey)
+		require.NoError(t, err)
+		assert.Equal(t, key.thumbprint(t), claims.JKT)
+	})
+
+	t.Run("invalid proof", func(t *testing.T) {
+		resp := post(url.Values{"grant_type": {handlers.GrantGUID}, "guid": {guid}},
+			key.proof(t, http.MethodGet, "/oauth/token", time.Now(), ""))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_dpop_proof", decodeOAuthError(t, resp).Error)
+	})
+}
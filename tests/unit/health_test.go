@@ -0,0 +1,132 @@
+package unit_tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/health"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+func passingCheck(context.Context) error { return nil }
+
+func probe(t *testing.T, router http.Handler, path string) (int, health.Report) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&report))
+	return w.Code, report
+}
+
+func TestCheckerReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []health.Check
+		status string
+	}{
+		{
+			name:   "all checks pass",
+			checks: []health.Check{{Name: "database", Run: passingCheck}},
+			status: health.StatusOK,
+		},
+		{
+			name: "required check fails",
+			checks: []health.Check{
+				{Name: "database", Run: func(context.Context) error { return errors.New("connection refused") }},
+				{Name: "signing_keys", Run: passingCheck},
+			},
+			status: health.StatusFail,
+		},
+		{
+			name: "optional check fails",
+			checks: []health.Check{
+				{Name: "database", Run: passingCheck},
+				{Name: "notifier", Optional: true, Run: func(context.Context) error { return errors.New("smtp is down") }},
+			},
+			status: health.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := health.NewChecker(time.Second, tt.checks...).Check(context.Background())
+			assert.Equal(t, tt.status, report.Status)
+			assert.Len(t, report.Checks, len(tt.checks))
+		})
+	}
+}
+
+func TestCheckerDetails(t *testing.T) {
+	checker := health.NewChecker(50*time.Millisecond,
+		health.Check{Name: "database", Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		health.Check{Name: "notifier", Optional: true, Run: func(context.Context) error { return errors.New("smtp is down") }},
+	)
+
+	start := time.Now()
+	report := checker.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second, "a hanging check is cut off by the timeout")
+
+	assert.Equal(t, health.Result{Status: health.StatusFail, Error: context.DeadlineExceeded.Error()}, report.Checks["database"])
+	assert.Equal(t, health.Result{Status: health.StatusFail, Optional: true, Error: "smtp is down"}, report.Checks["notifier"])
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	var dbErr error
+	checker := health.NewChecker(time.Second, health.Check{Name: "database", Run: func(context.Context) error { return dbErr }})
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{}, handlers.WithReadiness(checker))
+
+	code, report := probe(t, router, "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Status)
+
+	code, report = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Checks["database"].Status)
+
+	dbErr = errors.New("connection refused")
+	code, report = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusFail, report.Status)
+	assert.Equal(t, health.StatusFail, report.Checks["database"].Status)
+	assert.Empty(t, report.Checks["database"].Error, "public readiness does not expose error details")
+
+	// Служебный listener показывает причину
+	internal := handlers.SetupInternalRoutes(nil, "test_key", "", handlers.WithReadiness(checker))
+	code, report = probe(t, internal, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", report.Checks["database"].Error)
+
+	code, _ = probe(t, router, "/healthz")
+	assert.Equal(t, http.StatusOK, code, "liveness does not depend on the database")
+
+	dbErr = nil
+	checker.Drain()
+	code, report = probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusDraining, report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+func TestReadyWithoutChecks(t *testing.T) {
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+
+	code, report := probe(t, router, "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusOK, report.Status)
+}
@@ -0,0 +1,63 @@
+package unit_tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/domain/ip"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ip.ParsePrefixes([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	require.NoError(t, err)
+	resolver := ip.NewResolver(trusted)
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{"without proxy", "203.0.113.5:4000", nil, "203.0.113.5"},
+		{"untrusted forwarded for", "203.0.113.5:4000", []string{"198.51.100.7"}, "203.0.113.5"},
+		{"trusted proxy", "10.0.0.1:4000", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"spoofed left hops", "10.0.0.1:4000", []string{"1.1.1.1, 198.51.100.7"}, "198.51.100.7"},
+		{"proxy chain", "10.0.0.1:4000", []string{"1.1.1.1, 198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		{"several headers", "10.0.0.1:4000", []string{"1.1.1.1", "198.51.100.7, 192.168.1.1"}, "198.51.100.7"},
+		{"port in hop", "10.0.0.1:4000", []string{"198.51.100.7:5555"}, "198.51.100.7"},
+		{"ipv6 hop with port", "[fd00::1]:4000", []string{"[2001:db8::1]:5555"}, "2001:db8::1"},
+		{"malformed hop", "10.0.0.1:4000", []string{"198.51.100.7, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"only trusted hops", "10.0.0.1:4000", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"trusted proxy without header", "10.0.0.1:4000", nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, tt.want, resolver.ClientIP(req))
+		})
+	}
+}
+
+func TestFromRequestWithoutResolverIgnoresForwardedFor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.5:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+	assert.Equal(t, "203.0.113.5", ip.FromRequest(req))
+}
+
+func TestParsePrefixesRejectsGarbage(t *testing.T) {
+	_, err := ip.ParsePrefixes([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = ip.ParsePrefixes([]string{"proxy.local"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package unit_tests
+
+import (
+	"context"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database/pgsql"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/migrations"
+)
+
+func TestParseAccessTokenPreviousKeys(t *testing.T) {
+	tokens, err := jwt.NewTokens(context.Background(), "old_key", jwt.Params{GUID: "7d3c1a52-5a4e-4f6b-9a0e-2f1b7c9d8e01", TTL: time.Hour})
+	require.NoError(t, err)
+
+	_, err = jwt.ParseAccessToken(tokens.Access, "new_key")
+	assert.Error(t, err)
+
+	claims, err := jwt.ParseAccessToken(tokens.Access, "new_key", "old_key")
+	require.NoError(t, err)
+	assert.Equal(t, tokens.ID, claims.ID)
+
+	_, err = jwt.ParseAccessToken(tokens.Access, "new_key", "", "other_key")
+	assert.Error(t, err)
+
+	// Пустой предыдущий ключ не принимает токен, подписанный пустым ключом
+	unsigned, err := jwt.NewTokens(context.Background(), "", jwt.Params{GUID: "7d3c1a52-5a4e-4f6b-9a0e-2f1b7c9d8e01", TTL: time.Hour})
+	require.NoError(t, err)
+	_, err = jwt.ParseAccessToken(unsigned.Access, "new_key", "")
+	assert.Error(t, err)
+}
+
+func TestEmbeddedMigrationsMatchSchemaVersion(t *testing.T) {
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	require.NoError(t, err)
+
+	pattern := regexp.MustCompile(`^(\d+)_\w+\.(up|down)\.sql$`)
+	files := map[string]bool{}
+	latest := 0
+	for _, name := range names {
+		m := pattern.FindStringSubmatch(name)
+		require.NotNil(t, m, "%s does not follow NNNNNN_name.up|down.sql", name)
+		files[name] = true
+		version, _ := strconv.Atoi(m[1])
+		latest = max(latest, version)
+	}
+
+	for name := range files {
+		if up, ok := strings.CutSuffix(name, ".up.sql"); ok {
+			assert.True(t, files[up+".down.sql"], "%s has no down migration", name)
+		}
+	}
+	assert.Equal(t, pgsql.SchemaVersion, latest, "pgsql.SchemaVersion must match the latest migration")
+}
@@ -0,0 +1,201 @@
+package unit_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/lockout"
+)
+
+// fakeLockoutStore хранит счётчики в памяти по ключу область/subject
+type fakeLockoutStore struct {
+	lockouts map[string]*models.RefreshLockout
+}
+
+func newFakeLockoutStore() *fakeLockoutStore {
+	return &fakeLockoutStore{lockouts: map[string]*models.RefreshLockout{}}
+}
+
+func (s *fakeLockoutStore) UpdateRefreshLockout(_ context.Context, guid, scope, subject string, update func(*models.RefreshLockout)) error {
+	key := guid + "/" + scope + "/" + subject
+	l, ok := s.lockouts[key]
+	if !ok {
+		l = &models.RefreshLockout{UserGUID: uuid.MustParse(guid), Scope: scope, Subject: subject}
+		s.lockouts[key] = l
+	}
+	update(l)
+	return nil
+}
+
+func (s *fakeLockoutStore) ListRefreshLockouts(_ context.Context, guid string) ([]models.RefreshLockout, error) {
+	var list []models.RefreshLockout
+	for _, l := range s.lockouts {
+		if l.UserGUID.String() == guid {
+			list = append(list, *l)
+		}
+	}
+	return list, nil
+}
+
+func (s *fakeLockoutStore) ResetRefreshLockout(_ context.Context, guid, subject string) error {
+	delete(s.lockouts, guid+"/"+models.LockoutScopeUser+"/")
+	delete(s.lockouts, guid+"/"+models.LockoutScopeSession+"/"+subject)
+	return nil
+}
+
+func (s *fakeLockoutStore) ClearRefreshLockouts(_ context.Context, guid string) (int64, error) {
+	var n int64
+	for key, l := range s.lockouts {
+		if l.UserGUID.String() == guid {
+			delete(s.lockouts, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+var testLockoutPolicy = lockout.Policy{
+	UserThreshold:    5,
+	SessionThreshold: 3,
+	BaseBackoff:      time.Minute,
+	MaxBackoff:       10 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+var testSource = lockout.SourceID("192.0.2.1")
+
+func TestLockoutBackoffEscalates(t *testing.T) {
+	assert.Equal(t, time.Minute, testLockoutPolicy.Backoff(1))
+	assert.Equal(t, 2*time.Minute, testLockoutPolicy.Backoff(2))
+	assert.Equal(t, 8*time.Minute, testLockoutPolicy.Backoff(4))
+	assert.Equal(t, 10*time.Minute, testLockoutPolicy.Backoff(5))
+	assert.Equal(t, 10*time.Minute, testLockoutPolicy.Backoff(100))
+}
+
+func TestLockoutLocksSessionAfterThreshold(t *testing.T) {
+	policy := testLockoutPolicy
+	policy.UserThreshold = 0
+	guard := lockout.NewGuard(newFakeLockoutStore(), policy)
+	guid := uuid.New().String()
+	session := lockout.SessionID([]byte("token-1"))
+
+	for i := 0; i < 2; i++ {
+		state, err := guard.Failure(context.Background(), guid, session, testSource, true)
+		require.NoError(t, err)
+		assert.False(t, state.Locked)
+	}
+
+	state, err := guard.Failure(context.Background(), guid, session, testSource, true)
+	require.NoError(t, err)
+	require.True(t, state.Locked)
+	assert.Equal(t, models.LockoutScopeSession, state.Scope)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), state.Until, time.Second)
+
+	state, err = guard.Check(context.Background(), guid, session, testSource)
+	require.NoError(t, err)
+	assert.True(t, state.Locked)
+
+	// Блокировка сессии не распространяется на другие токены пользователя
+	state, err = guard.Check(context.Background(), guid, lockout.SessionID([]byte("token-2")), testSource)
+	require.NoError(t, err)
+	assert.False(t, state.Locked)
+
+	// Следующая серия неудач блокирует сессию вдвое дольше
+	for i := 0; i < 3; i++ {
+		state, err = guard.Failure(context.Background(), guid, session, testSource, true)
+		require.NoError(t, err)
+	}
+	assert.True(t, state.Locked)
+	assert.Equal(t, 2, state.Lockouts)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), state.Until, time.Second)
+}
+
+func TestLockoutLocksUserAcrossSessions(t *testing.T) {
+	guard := lockout.NewGuard(newFakeLockoutStore(), testLockoutPolicy)
+	guid := uuid.New().String()
+
+	var state lockout.State
+	for i := 0; i < 5; i++ {
+		var err error
+		state, err = guard.Failure(context.Background(), guid, lockout.SessionID([]byte{byte(i)}), testSource, true)
+		require.NoError(t, err)
+	}
+	require.True(t, state.Locked)
+	assert.Equal(t, models.LockoutScopeUser, state.Scope)
+
+	state, err := guard.Check(context.Background(), guid, lockout.SessionID([]byte("other")), testSource)
+	require.NoError(t, err)
+	assert.True(t, state.Locked)
+}
+
+func TestLockoutIgnoresUnknownTokensForUser(t *testing.T) {
+	store := newFakeLockoutStore()
+	guard := lockout.NewGuard(store, testLockoutPolicy)
+	guid := uuid.New().String()
+
+	// Чужие запросы с GUID пользователя и случайными токенами не блокируют его refresh
+	for i := 0; i < 20; i++ {
+		_, err := guard.Failure(context.Background(), guid, lockout.SessionID([]byte{byte(i)}), lockout.SourceID("198.51.100.7"), false)
+		require.NoError(t, err)
+	}
+
+	state, err := guard.Check(context.Background(), guid, lockout.SessionID([]byte("real token")), testSource)
+	require.NoError(t, err)
+	assert.False(t, state.Locked)
+	assert.NotContains(t, store.lockouts, guid+"/"+models.LockoutScopeUser+"/")
+}
+
+func TestLockoutLocksSourceGuessingTokens(t *testing.T) {
+	store := newFakeLockoutStore()
+	guard := lockout.NewGuard(store, testLockoutPolicy)
+	guid := uuid.New().String()
+	source := lockout.SourceID("198.51.100.7")
+
+	// Каждая догадка - новый токен, но счётчик у источника один
+	var state lockout.State
+	for i := 0; i < testLockoutPolicy.SessionThreshold; i++ {
+		var err error
+		state, err = guard.Failure(context.Background(), guid, lockout.SessionID([]byte{byte(i)}), source, false)
+		require.NoError(t, err)
+	}
+	require.True(t, state.Locked)
+	assert.Equal(t, models.LockoutScopeSession, state.Scope)
+	assert.Len(t, store.lockouts, 1)
+
+	state, err := guard.Check(context.Background(), guid, lockout.SessionID([]byte("next guess")), source)
+	require.NoError(t, err)
+	assert.True(t, state.Locked)
+}
+
+func TestLockoutSuccessResetsCounters(t *testing.T) {
+	store := newFakeLockoutStore()
+	guard := lockout.NewGuard(store, testLockoutPolicy)
+	guid := uuid.New().String()
+	session := lockout.SessionID([]byte("token-1"))
+
+	for i := 0; i < 2; i++ {
+		_, err := guard.Failure(context.Background(), guid, session, testSource, true)
+		require.NoError(t, err)
+	}
+	require.NoError(t, guard.Success(context.Background(), guid, session))
+
+	state, err := guard.Failure(context.Background(), guid, session, testSource, true)
+	require.NoError(t, err)
+	assert.False(t, state.Locked)
+	assert.Len(t, store.lockouts, 2)
+}
+
+func TestNilLockoutGuard(t *testing.T) {
+	var guard *lockout.Guard
+
+	state, err := guard.Failure(context.Background(), uuid.New().String(), "session", testSource, true)
+	require.NoError(t, err)
+	assert.False(t, state.Locked)
+	assert.NoError(t, guard.Success(context.Background(), uuid.New().String(), "session"))
+}
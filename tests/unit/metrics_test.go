@@ -0,0 +1,161 @@
+package unit_tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/metrics"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+// failingNotifier не может доставить предупреждение
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(string, string, string) error {
+	return errors.New("smtp is down")
+}
+
+func scrapeMetrics(t *testing.T, router http.Handler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/no/such/"+uuid.NewString(), nil))
+
+	body := scrapeMetrics(t, router)
+	assert.Contains(t, body, `auth_medods_http_request_duration_seconds_count{method="GET",route="/openapi.json",status="200"}`)
+	assert.Contains(t, body, `auth_medods_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"}`)
+	assert.NotContains(t, body, "/no/such/", "unmatched paths must not become label values")
+}
+
+func TestTokenFailureMetrics(t *testing.T) {
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+	post := func(path, contentType, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	refreshInvalid := metrics.TokenFailures.WithLabelValues("refresh", string(response.CodeInvalidRequest))
+	before := testutil.ToFloat64(refreshInvalid)
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/refresh", "application/json", "{"))
+	assert.Equal(t, before+1, testutil.ToFloat64(refreshInvalid))
+
+	tooLarge := metrics.TokenFailures.WithLabelValues("refresh", string(response.CodeRequestTooLarge))
+	before = testutil.ToFloat64(tooLarge)
+	post("/refresh", "application/json", `{"guid":"`+strings.Repeat("a", 8<<10)+`"}`)
+	assert.Equal(t, before+1, testutil.ToFloat64(tooLarge), "legacy routes share the counters")
+
+	oauthUnsupported := metrics.TokenFailures.WithLabelValues("oauth", "unsupported_grant_type")
+	before = testutil.ToFloat64(oauthUnsupported)
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/oauth/token",
+		"application/x-www-form-urlencoded", "grant_type=password"))
+	assert.Equal(t, before+1, testutil.ToFloat64(oauthUnsupported))
+}
+
+func TestRefreshMetrics(t *testing.T) {
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		IP:                 "192.0.2.1",
+		Email:              "user@example.com",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	refresh := handlers.TokenMetrics("refresh")(handlers.RefreshHandler(mockDB, "test_key", time.Minute,
+		handlers.WithNotifier(failingNotifier{})))
+	post := func(token string) int {
+		body, _ := json.Marshal(response.RefreshToken{
+			GUID:         guid,
+			RefreshToken: base64.StdEncoding.EncodeToString([]byte(token)),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body))
+		req.RemoteAddr = "198.51.100.7:1234"
+		w := httptest.NewRecorder()
+		refresh.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	refreshed := testutil.ToFloat64(metrics.TokensRefreshed)
+	ipChanges := testutil.ToFloat64(metrics.IPChanges)
+	notifyFailed := testutil.ToFloat64(metrics.Notifications.WithLabelValues("ip_changed", "failed"))
+	mismatch := metrics.TokenFailures.WithLabelValues("refresh", string(response.CodeInvalidRefreshToken))
+	rejected := testutil.ToFloat64(mismatch)
+
+	require.Equal(t, http.StatusOK, post(refreshToken))
+	assert.Equal(t, refreshed+1, testutil.ToFloat64(metrics.TokensRefreshed))
+	assert.Equal(t, ipChanges+1, testutil.ToFloat64(metrics.IPChanges))
+	assert.Equal(t, notifyFailed+1, testutil.ToFloat64(metrics.Notifications.WithLabelValues("ip_changed", "failed")))
+
+	// Хранилище-заглушка не помнит прежний хеш, поэтому старый токен просто не подходит
+	assert.Equal(t, http.StatusUnauthorized, post(refreshToken))
+	assert.Equal(t, rejected+1, testutil.ToFloat64(mismatch))
+
+	assert.Positive(t, testutil.CollectAndCount(metrics.BcryptDuration))
+	body := scrapeMetrics(t, handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{}))
+	assert.Contains(t, body, `auth_medods_tokens_bcrypt_duration_seconds_count{op="compare"}`)
+	assert.Contains(t, body, `auth_medods_tokens_bcrypt_duration_seconds_count{op="hash"}`)
+}
+
+func TestOAuthFailureKeepsSpecificReason(t *testing.T) {
+	guid := uuid.New().String()
+	mockDB := new(RMockDB)
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(guid+":0000"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+	}, nil)
+
+	handler := handlers.TokenMetrics("oauth")(handlers.TokenHandler(mockDB, "test_key", time.Minute))
+	counter := metrics.TokenFailures.WithLabelValues("oauth", string(response.CodeInvalidRefreshToken))
+	generic := metrics.TokenFailures.WithLabelValues("oauth", "invalid_grant")
+	genericBefore := testutil.ToFloat64(generic)
+	before := testutil.ToFloat64(counter)
+
+	resp := postTokenForm(handler, url.Values{
+		"grant_type":    {handlers.GrantRefreshToken},
+		"refresh_token": {guid + ":ffff"},
+	})
+	assert.Equal(t, "invalid_grant", decodeOAuthError(t, resp).Error)
+	assert.Equal(t, before+1, testutil.ToFloat64(counter))
+	assert.Equal(t, genericBefore, testutil.ToFloat64(generic))
+}
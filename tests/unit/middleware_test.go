@@ -0,0 +1,160 @@
+package unit_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+// logLines разбирает JSON строки лога
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var event map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		lines = append(lines, event)
+	}
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := handlers.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = handlers.RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated when missing", "", false},
+		{"propagated from the client", "req-42.edge:7", true},
+		{"replaced when it contains spaces", "bad id", false},
+		{"replaced when too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set(handlers.RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			got := w.Header().Get(handlers.RequestIDHeader)
+			assert.Equal(t, seen, got)
+			if tt.keep {
+				assert.Equal(t, tt.incoming, got)
+				return
+			}
+			_, err := uuid.Parse(got)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	logs := captureLog(t)
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json?access_token=secret-in-query", nil)
+	req.Header.Set(handlers.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "req-1", w.Header().Get(handlers.RequestIDHeader))
+
+	lines := logLines(t, logs)
+	require.NotEmpty(t, lines)
+	access := lines[len(lines)-1]
+	assert.Equal(t, "request completed", access["message"])
+	assert.Equal(t, "req-1", access["request_id"])
+	assert.Equal(t, "GET", access["method"])
+	assert.Equal(t, "/openapi.json", access["path"])
+	assert.Equal(t, "/openapi.json", access["route"])
+	assert.EqualValues(t, http.StatusOK, access["status"])
+	assert.EqualValues(t, w.Body.Len(), access["bytes"])
+	assert.Contains(t, access, "latency")
+	assert.NotContains(t, logs.String(), "secret-in-query", "query strings may carry tokens")
+}
+
+func TestRecoverer(t *testing.T) {
+	serve := func(h http.HandlerFunc) (*httptest.ResponseRecorder, []map[string]any) {
+		logs := captureLog(t)
+		handler := handlers.RequestID(handlers.RequestLogger(handlers.Recoverer(h)))
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/refresh", nil)
+		req.Header.Set(handlers.RequestIDHeader, "req-panic")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w, logLines(t, logs)
+	}
+
+	t.Run("responds with problem+json", func(t *testing.T) {
+		w, lines := serve(func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, response.ProblemContentType, w.Header().Get("Content-Type"))
+		var problem response.Problem
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+		assert.Equal(t, response.CodeInternal, problem.Code)
+
+		require.Len(t, lines, 2)
+		assert.Equal(t, "handler panicked", lines[0]["message"])
+		assert.Equal(t, "boom", lines[0]["panic"])
+		assert.Equal(t, "req-panic", lines[0]["request_id"])
+		assert.Contains(t, lines[0]["stack"], "runtime/debug.Stack")
+		assert.EqualValues(t, http.StatusInternalServerError, lines[1]["status"])
+		assert.Equal(t, "error", lines[1]["level"])
+	})
+
+	t.Run("keeps a response that has started", func(t *testing.T) {
+		w, _ := serve(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("late")
+		})
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("re-panics on ErrAbortHandler", func(t *testing.T) {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) })
+		})
+	})
+}
+
+func TestRefreshBodyLimit(t *testing.T) {
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+	huge := `{"guid":"` + strings.Repeat("a", 8<<10) + `"}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/refresh", strings.NewReader(huge)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var problem response.Problem
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+	assert.Equal(t, response.CodeRequestTooLarge, problem.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/oauth/token",
+		strings.NewReader("grant_type=refresh_token&refresh_token="+strings.Repeat("a", 8<<10)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
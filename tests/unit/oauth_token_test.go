@@ -0,0 +1,226 @@
+package unit_tests
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+func postTokenForm(handler http.Handler, form url.Values) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func decodeOAuthError(t *testing.T, resp *http.Response) response.OAuthError {
+	t.Helper()
+	var body response.OAuthError
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func TestTokenHandlerRefreshGrant(t *testing.T) {
+	ownKey := "test_key"
+	tokenTTL := 30 * time.Minute
+
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		IP:                 "192.0.2.1",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.TokenHandler(mockDB, ownKey, tokenTTL)
+
+	resp := postTokenForm(handler, url.Values{
+		"grant_type":    {handlers.GrantRefreshToken},
+		"refresh_token": {refreshToken},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+
+	var body response.TokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "Bearer", body.TokenType)
+	assert.Equal(t, int64(tokenTTL.Seconds()), body.ExpiresIn)
+	assert.NotEmpty(t, body.AccessToken)
+
+	// Новый refresh токен снова содержит GUID пользователя
+	got, ok := jwt.RefreshTokenGUID(body.RefreshToken)
+	assert.True(t, ok)
+	assert.Equal(t, guid, got)
+
+	claims, err := jwt.ParseAccessToken(body.AccessToken, ownKey)
+	require.NoError(t, err)
+	assert.Equal(t, guid, claims.Subject)
+}
+
+func TestTokenHandlerErrors(t *testing.T) {
+	handler := handlers.TokenHandler(new(RMockDB), "test_key", time.Minute)
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		status int
+		code   string
+	}{
+		{
+			name:   "missing grant_type",
+			form:   url.Values{},
+			status: http.StatusBadRequest,
+			code:   "invalid_request",
+		},
+		{
+			name:   "unsupported grant_type",
+			form:   url.Values{"grant_type": {"password"}},
+			status: http.StatusBadRequest,
+			code:   "unsupported_grant_type",
+		},
+		{
+			name:   "refresh token without guid prefix",
+			form:   url.Values{"grant_type": {handlers.GrantRefreshToken}, "refresh_token": {uuid.New().String()}},
+			status: http.StatusBadRequest,
+			code:   "invalid_grant",
+		},
+		{
+			name:   "client_credentials without client authentication",
+			form:   url.Values{"grant_type": {handlers.GrantClientCredentials}},
+			status: http.StatusBadRequest,
+			code:   "unauthorized_client",
+		},
+		{
+			name:   "scope requested",
+			form:   url.Values{"grant_type": {handlers.GrantGUID}, "guid": {uuid.New().String()}, "scope": {"admin"}},
+			status: http.StatusBadRequest,
+			code:   "invalid_scope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := postTokenForm(handler, tt.form)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, tt.code, decodeOAuthError(t, resp).Error)
+		})
+	}
+}
+
+func TestRefreshTokenWorksAcrossEndpoints(t *testing.T) {
+	ownKey := "test_key"
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	// Мок возвращает один и тот же объект, поэтому новый хеш виден следующему запросу
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		IP:                 "192.0.2.1",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	tokenHandler := handlers.TokenHandler(mockDB, ownKey, time.Minute)
+	refreshHandler := handlers.RefreshHandler(mockDB, ownKey, time.Minute)
+
+	// Токен в base64, как его выдают /access и /refresh, принимает /oauth/token
+	resp := postTokenForm(tokenHandler, url.Values{
+		"grant_type":    {handlers.GrantRefreshToken},
+		"refresh_token": {base64.StdEncoding.EncodeToString([]byte(refreshToken))},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tokenBody response.TokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tokenBody))
+
+	// Токен от /oauth/token как есть принимает /refresh
+	body, err := json.Marshal(response.RefreshToken{GUID: guid, RefreshToken: tokenBody.RefreshToken})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	refreshHandler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var refreshBody response.UserResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&refreshBody))
+
+	// И обратно: base64 от /refresh снова принимает /oauth/token
+	resp = postTokenForm(tokenHandler, url.Values{
+		"grant_type":    {handlers.GrantRefreshToken},
+		"refresh_token": {refreshBody.GetRefreshToken},
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRefreshGrantRequiresIssuingClient(t *testing.T) {
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	frontend, gateway := selfSignedCert(t), selfSignedCert(t)
+	store := newFakeClientStore(
+		&models.Client{ClientID: "frontend", CertThumbprint: clientauth.Thumbprint(frontend)},
+		&models.Client{ClientID: "gateway", CertThumbprint: clientauth.Thumbprint(gateway)},
+	)
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		RefreshClientID:    "frontend",
+		IP:                 "192.0.2.1",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.TokenHandler(mockDB, "test_key", time.Minute,
+		handlers.WithClientAuth(clientauth.NewMTLSMethod(store, "", nil)))
+	exchange := func(cert *x509.Certificate) *http.Response {
+		form := url.Values{"grant_type": {handlers.GrantRefreshToken}, "refresh_token": {refreshToken}}
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	resp := exchange(gateway)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_grant", decodeOAuthError(t, resp).Error)
+	mockDB.AssertNotCalled(t, "RotateRefreshToken", mock.Anything, mock.Anything)
+
+	assert.Equal(t, http.StatusOK, exchange(frontend).StatusCode)
+}
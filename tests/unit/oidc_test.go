@@ -0,0 +1,180 @@
+package unit_tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+const testIssuer = "https://auth.example.com"
+
+func newTestOIDC(t *testing.T) *handlers.OIDC {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jwt.NewIDTokenSigner(key)
+	require.NoError(t, err)
+	return &handlers.OIDC{Issuer: testIssuer, Signer: signer}
+}
+
+func TestTokenHandlerIssuesIDToken(t *testing.T) {
+	cfg := newTestOIDC(t)
+	guid := uuid.New().String()
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID: uuid.MustParse(guid),
+		Email:    "user@example.com",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.TokenHandler(mockDB, "test_key", time.Minute, handlers.WithOIDC(cfg))
+
+	before := time.Now().Add(-time.Second)
+	resp := postTokenForm(handler, url.Values{
+		"grant_type": {handlers.GrantGUID},
+		"guid":       {guid},
+		"scope":      {"openid"},
+		"nonce":      {"n-0S6_WzA2Mj"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body response.TokenResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "openid", body.Scope)
+	require.NotEmpty(t, body.IDToken)
+
+	claims := gojwt.MapClaims{}
+	token, err := gojwt.ParseWithClaims(body.IDToken, claims, func(token *gojwt.Token) (any, error) {
+		assert.Equal(t, cfg.Signer.KeyID(), token.Header["kid"])
+		return cfg.Signer.PublicKey(), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", token.Method.Alg())
+
+	assert.Equal(t, testIssuer, claims["iss"])
+	assert.Equal(t, guid, claims["sub"])
+	assert.Equal(t, testIssuer, claims["aud"])
+	assert.Equal(t, "user@example.com", claims["email"])
+	assert.Equal(t, "n-0S6_WzA2Mj", claims["nonce"])
+	assert.GreaterOrEqual(t, int64(claims["auth_time"].(float64)), before.Unix())
+}
+
+func TestTokenHandlerRejectsOpenIDWithoutOIDC(t *testing.T) {
+	handler := handlers.TokenHandler(new(RMockDB), "test_key", time.Minute)
+
+	resp := postTokenForm(handler, url.Values{
+		"grant_type": {handlers.GrantGUID},
+		"guid":       {uuid.New().String()},
+		"scope":      {"openid"},
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "invalid_scope", decodeOAuthError(t, resp).Error)
+}
+
+func TestUserInfoHandler(t *testing.T) {
+	ownKey := "test_key"
+	guid := uuid.New().String()
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID: uuid.MustParse(guid),
+		Email:    "user@example.com",
+	}, nil)
+	mockDB.On("IsTokenRevoked", mock.Anything).Return(false, nil)
+
+	handler := handlers.UserInfoHandler(mockDB, ownKey)
+
+	get := func(token string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("user token", func(t *testing.T) {
+		tokens, err := jwt.NewTokens(context.Background(), ownKey,
+			jwt.Params{GUID: guid, IP: "192.0.2.1", Scope: []string{handlers.ScopeOpenID}, TTL: time.Minute})
+		require.NoError(t, err)
+
+		resp := get(tokens.Access)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var info response.UserInfo
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+		assert.Equal(t, guid, info.Subject)
+		assert.Equal(t, "user@example.com", info.Email)
+	})
+
+	t.Run("token without openid scope", func(t *testing.T) {
+		tokens, err := jwt.NewTokens(context.Background(), ownKey, jwt.Params{GUID: guid, IP: "192.0.2.1", TTL: time.Minute})
+		require.NoError(t, err)
+
+		resp := get(tokens.Access)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="insufficient_scope"`)
+		var problem response.Problem
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		assert.Equal(t, response.CodeInsufficientScope, problem.Code)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		resp := get("")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, `Bearer realm="auth-medods"`, resp.Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("client token", func(t *testing.T) {
+		tokens, err := jwt.NewClientToken(context.Background(), ownKey, jwt.Params{ClientID: "service", TTL: time.Minute})
+		require.NoError(t, err)
+
+		resp := get(tokens.Access)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+
+	t.Run("foreign signature", func(t *testing.T) {
+		tokens, err := jwt.NewTokens(context.Background(), "other_key", jwt.Params{GUID: guid, TTL: time.Minute})
+		require.NoError(t, err)
+
+		resp := get(tokens.Access)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestDiscoveryHandler(t *testing.T) {
+	cfg := newTestOIDC(t)
+
+	w := httptest.NewRecorder()
+	handlers.DiscoveryHandler(cfg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc response.OpenIDConfiguration
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	assert.Equal(t, testIssuer, doc.Issuer)
+	assert.Equal(t, testIssuer+"/api/v1/oauth/token", doc.TokenEndpoint)
+	assert.Equal(t, testIssuer+"/api/v1/userinfo", doc.UserInfoEndpoint)
+	assert.Equal(t, testIssuer+"/.well-known/jwks.json", doc.JWKSURI)
+	assert.Contains(t, doc.IDTokenSigningAlgValuesSupported, "RS256")
+}
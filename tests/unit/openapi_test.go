@@ -0,0 +1,277 @@
+package unit_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/openapi"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+// apiTypes - типы пакета response, которые описаны в документе
+var apiTypes = []any{
+	response.UserResponse{}, response.RefreshToken{}, response.TokenResponse{}, response.OAuthError{},
+	response.UserInfo{}, response.Problem{}, response.CreateUser{}, response.UpdateUser{},
+	response.UserStatusChange{}, response.User{}, response.UserList{}, response.Introspection{},
+	response.Confirmation{}, response.AuditEvent{}, response.AuditEvents{}, response.RefreshLockout{},
+	response.RefreshLockouts{}, response.CreateClient{}, response.UpdateClient{}, response.Client{},
+	response.ClientList{},
+}
+
+// loadAPIDocument получает документ так же, как клиенты: через /openapi.json
+func loadAPIDocument(t *testing.T) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handlers.OpenAPIHandler(handlers.APIDocument()).
+		ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]any
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	return doc
+}
+
+func schemas(doc map[string]any) map[string]any {
+	return doc["components"].(map[string]any)["schemas"].(map[string]any)
+}
+
+func resolve(doc, schema map[string]any) map[string]any {
+	if ref, ok := schema["$ref"].(string); ok {
+		return schemas(doc)[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]any)
+	}
+	return schema
+}
+
+// validate проверяет значение, декодированное из JSON ответа, по схеме документа
+func validate(t *testing.T, doc, schema map[string]any, value any, path string) {
+	t.Helper()
+	schema = resolve(doc, schema)
+
+	if value == nil {
+		assert.Equal(t, true, schema["nullable"], "%s: null is not allowed", path)
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		require.True(t, ok, "%s: expected an object", path)
+		props, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			assert.Contains(t, obj, name, "%s: required property is missing", path)
+		}
+		for name, v := range obj {
+			prop, ok := props[name].(map[string]any)
+			if assert.True(t, ok, "%s.%s is not documented", path, name) {
+				validate(t, doc, prop, v, path+"."+name)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		require.True(t, ok, "%s: expected an array", path)
+		for _, item := range items {
+			validate(t, doc, schema["items"].(map[string]any), item, path+"[]")
+		}
+	case "string":
+		assert.IsType(t, "", value, path)
+	case "integer", "number":
+		assert.IsType(t, float64(0), value, path)
+	case "boolean":
+		assert.IsType(t, true, value, path)
+	}
+}
+
+func TestAPIDocumentSchemasMatchTypes(t *testing.T) {
+	doc := loadAPIDocument(t)
+	assert.Equal(t, openapi.Version, doc["openapi"])
+
+	documented := schemas(doc)
+	for _, v := range apiTypes {
+		typ := reflect.TypeOf(v)
+		t.Run(typ.Name(), func(t *testing.T) {
+			schema, ok := documented[typ.Name()].(map[string]any)
+			require.True(t, ok, "schema is not documented")
+
+			var fields, required []string
+			for i := 0; i < typ.NumField(); i++ {
+				f := typ.Field(i)
+				name, omitempty, ok := openapi.JSONField(f)
+				if !ok {
+					continue
+				}
+				fields = append(fields, name)
+				if !omitempty && f.Type.Kind() != reflect.Pointer {
+					required = append(required, name)
+				}
+			}
+
+			var props []string
+			for name := range schema["properties"].(map[string]any) {
+				props = append(props, name)
+			}
+			var docRequired []string
+			for _, name := range asSlice(schema["required"]) {
+				docRequired = append(docRequired, name.(string))
+			}
+
+			assert.ElementsMatch(t, fields, props)
+			assert.ElementsMatch(t, required, docRequired)
+		})
+	}
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+func TestAPIDocumentReferencesResolve(t *testing.T) {
+	doc := loadAPIDocument(t)
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				name := strings.TrimPrefix(ref, "#/components/schemas/")
+				assert.Contains(t, schemas(doc), name, "unresolved %s", ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+// serviceRoutes - служебные маршруты без версии, которые не входят в API
+var serviceRoutes = map[string]bool{"/openapi.json": true, "/metrics": true, "/healthz": true, "/readyz": true}
+
+// Все маршруты /api/v1 описаны в документе, а у каждого есть устаревший псевдоним без версии
+func TestAPIDocumentCoversRoutes(t *testing.T) {
+	doc := loadAPIDocument(t)
+	paths := doc["paths"].(map[string]any)
+
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "admin_token", ratelimit.Config{},
+		handlers.WithOIDC(newTestOIDC(t)))
+
+	var versioned, legacy []string
+	err := chi.Walk(router.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = strings.TrimSuffix(route, "/")
+		switch {
+		case strings.HasPrefix(route, handlers.APIPrefix+"/"):
+			versioned = append(versioned, method+" "+strings.TrimPrefix(route, handlers.APIPrefix))
+		case !strings.HasPrefix(route, "/.well-known") && !serviceRoutes[route]:
+			legacy = append(legacy, method+" "+route)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, versioned)
+
+	sort.Strings(versioned)
+	sort.Strings(legacy)
+	assert.Equal(t, versioned, legacy)
+
+	for _, route := range versioned {
+		method, path, _ := strings.Cut(route, " ")
+		item, ok := paths[path].(map[string]any)
+		if assert.True(t, ok, "%s is not documented", path) {
+			assert.Contains(t, item, strings.ToLower(method), "%s is not documented", route)
+		}
+	}
+}
+
+func TestLegacyRoutesAreDeprecated(t *testing.T) {
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/oauth/token", nil))
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, `</api/v1/oauth/token>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/oauth/token", nil))
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+// Ответы хендлеров соответствуют схемам из документа
+func TestAPIResponsesMatchDocument(t *testing.T) {
+	doc := loadAPIDocument(t)
+	operation := func(method, path string) map[string]any {
+		return doc["paths"].(map[string]any)[path].(map[string]any)[method].(map[string]any)
+	}
+	check := func(t *testing.T, op map[string]any, resp *http.Response) {
+		t.Helper()
+		documented, ok := op["responses"].(map[string]any)[resp.Status[:3]].(map[string]any)
+		require.True(t, ok, "status %s is not documented", resp.Status)
+
+		content := documented["content"].(map[string]any)
+		mediaType := strings.Split(resp.Header.Get("Content-Type"), ";")[0]
+		media, ok := content[mediaType].(map[string]any)
+		require.True(t, ok, "content type %s is not documented", mediaType)
+
+		var body any
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		validate(t, doc, media["schema"].(map[string]any), body, "body")
+	}
+
+	guid := uuid.New().String()
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{UserGUID: uuid.MustParse(guid), Email: "user@example.com"}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+	mockDB.On("IsTokenRevoked", mock.Anything).Return(false, nil)
+
+	token := handlers.TokenHandler(mockDB, "test_key", time.Minute, handlers.WithOIDC(newTestOIDC(t)))
+
+	t.Run("token response", func(t *testing.T) {
+		resp := postTokenForm(token, url.Values{
+			"grant_type": {handlers.GrantGUID},
+			"guid":       {guid},
+			"scope":      {"openid"},
+		})
+		check(t, operation("post", "/oauth/token"), resp)
+	})
+
+	t.Run("oauth error", func(t *testing.T) {
+		resp := postTokenForm(token, url.Values{"grant_type": {"password"}})
+		check(t, operation("post", "/oauth/token"), resp)
+	})
+
+	t.Run("problem", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(mockDB, "test_key", time.Minute).
+			ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/refresh", strings.NewReader("{")))
+		check(t, operation("post", "/refresh"), w.Result())
+	})
+
+	t.Run("access", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.AccessHandler(mockDB, "test_key", time.Minute).
+			ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/access?guid="+guid, nil))
+		check(t, operation("get", "/access"), w.Result())
+	})
+}
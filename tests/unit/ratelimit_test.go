@@ -0,0 +1,82 @@
+package unit_tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("20/1m")
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 20, Period: time.Minute}, limit)
+
+	for _, off := range []string{"", "0", "off"} {
+		limit, err := ratelimit.ParseLimit(off)
+		require.NoError(t, err)
+		assert.False(t, limit.Enabled(), off)
+	}
+
+	for _, bad := range []string{"20", "x/1m", "-1/1m", "20/soon", "20/0s"} {
+		_, err := ratelimit.ParseLimit(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestLimiterExhaustsBurst(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limit := ratelimit.Limit{Requests: 3, Period: time.Hour}
+
+	for i := 2; i >= 0; i-- {
+		res, err := limiter.Allow(context.Background(), "ip:10.0.0.1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+		assert.Equal(t, 3, res.Limit)
+	}
+
+	res, err := limiter.Allow(context.Background(), "ip:10.0.0.1", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	// Один запрос восстанавливается за треть часа
+	assert.InDelta(t, (20 * time.Minute).Seconds(), res.RetryAfter.Seconds(), 1)
+	assert.InDelta(t, time.Hour.Seconds(), res.Reset.Seconds(), 1)
+
+	// Запас других ключей не расходуется
+	res, err = limiter.Allow(context.Background(), "ip:10.0.0.2", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLimiterRefills(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(time.Hour))
+	limit := ratelimit.Limit{Requests: 1, Period: 100 * time.Millisecond}
+
+	res, err := limiter.Allow(context.Background(), "guid", limit)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	res, err = limiter.Allow(context.Background(), "guid", limit)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+
+	time.Sleep(150 * time.Millisecond)
+
+	res, err = limiter.Allow(context.Background(), "guid", limit)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestLimiterDisabledLimit(t *testing.T) {
+	store := ratelimit.NewMemoryStore(time.Hour)
+
+	res, err := ratelimit.NewLimiter(store).Allow(context.Background(), "ip:10.0.0.1", ratelimit.Limit{})
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Zero(t, store.Len())
+}
@@ -0,0 +1,217 @@
+package unit_tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/config"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/redact"
+)
+
+// captureLog перенаправляет глобальный логгер в буфер до конца теста
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() { log.Logger = prev })
+	return &buf
+}
+
+func assertNotLogged(t *testing.T, logs string, secrets map[string]string) {
+	t.Helper()
+	for name, secret := range secrets {
+		assert.NotContains(t, logs, secret, "%s reached the log", name)
+	}
+}
+
+func TestSecretFormatting(t *testing.T) {
+	s := redact.Secret("hunter22")
+
+	assert.Equal(t, "hunter22", s.Value())
+	for _, format := range []string{"%v", "%s", "%q", "%+v", "%#v", "%x"} {
+		assert.NotContains(t, fmt.Sprintf(format, s), "hunter22", format)
+	}
+
+	data, err := json.Marshal(struct {
+		Password redact.Secret `json:"password"`
+		Empty    redact.Secret `json:"empty"`
+	}{Password: s})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"password":"[REDACTED]","empty":""}`, string(data))
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Info().Stringer("key", s).Interface("cfg", map[string]any{"k": s}).Msg("")
+	assert.NotContains(t, buf.String(), "hunter22")
+}
+
+func TestWriterRedactsSensitiveFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(redact.NewWriter(&buf))
+
+	logger.Info().
+		Str("refresh_token", "abc.def").
+		Str("RefreshToken", "abc.def").
+		Str("hashed_refresh_token", "$2a$10$hash").
+		Str("client_secret", "s3cr3t").
+		Str("Authorization", "Bearer abc.def").
+		Dict("db", zerolog.Dict().Str("password", "pg-pass").Str("host", "db")).
+		Dur("token_ttl", time.Minute).
+		Str("guid", "user-guid").
+		Str("empty_token", "").
+		Msg("request")
+
+	var event map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &event))
+	for _, key := range []string{"refresh_token", "RefreshToken", "hashed_refresh_token", "client_secret", "Authorization"} {
+		assert.Equal(t, redact.Redacted, event[key], key)
+	}
+	assert.Equal(t, map[string]any{"password": redact.Redacted, "host": "db"}, event["db"])
+	assert.Equal(t, "user-guid", event["guid"])
+	assert.Equal(t, "", event["empty_token"], "empty values show that nothing was set")
+	assert.EqualValues(t, 60000, event["token_ttl"])
+	assert.Equal(t, "request", event["message"])
+}
+
+func TestWriterRedactsKnownSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	w := redact.NewWriter(&buf, "own-signing-key", "abc")
+	w.Add("pg-password")
+	logger := zerolog.New(w)
+
+	logger.Error().
+		Err(fmt.Errorf("dial postgres://auth:pg-password@db/auth: refused")).
+		Str("note", "uses own-signing-key").
+		Msg("failed with own-signing-key")
+	_, err := w.Write([]byte("plain pg-password line\n"))
+	require.NoError(t, err)
+
+	logs := buf.String()
+	assertNotLogged(t, logs, map[string]string{"own key": "own-signing-key", "db password": "pg-password"})
+	assert.Contains(t, logs, "postgres://auth:[REDACTED]@db/auth")
+	assert.Contains(t, logs, "plain [REDACTED] line")
+
+	// Слишком короткие значения не вырезаются из текста
+	assert.NotContains(t, logs, "[REDACTED]c")
+}
+
+func TestWriterWithConsoleOutput(t *testing.T) {
+	var buf bytes.Buffer
+	logger := zerolog.New(redact.NewWriter(zerolog.ConsoleWriter{Out: &buf, NoColor: true}, "own-signing-key"))
+
+	logger.Info().Str("refresh_token", "abc.def").Msg("key own-signing-key")
+
+	assert.Contains(t, buf.String(), "refresh_token=[REDACTED]")
+	assert.Contains(t, buf.String(), "key [REDACTED]")
+	assert.NotContains(t, buf.String(), "abc.def")
+}
+
+func TestConfigSecretsStayOutOfLogs(t *testing.T) {
+	env := baseEnv()
+	env["OWN_KEY"] = "own-signing-key"
+	env["DB_PASSWORD"] = "pg-password"
+	env["ADMIN_TOKEN"] = "admin-token"
+	env["SMTP_PASSWORD"] = "smtp-password"
+
+	cfg, _, err := config.Load(nil, lookup(env))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"own-signing-key", "pg-password", "admin-token", "smtp-password"}, cfg.Secrets())
+
+	var buf bytes.Buffer
+	logger := zerolog.New(&buf)
+	logger.Info().Interface("config", cfg).Msgf("loaded %+v", *cfg)
+	assertNotLogged(t, buf.String(), map[string]string{
+		"own key": "own-signing-key", "db password": "pg-password",
+		"admin token": "admin-token", "smtp password": "smtp-password",
+	})
+	assert.Contains(t, cfg.Database.DSN(), "pg-password", "the DSN still carries the real password")
+}
+
+func TestHandlersDoNotLogTokens(t *testing.T) {
+	const ownKey = "own-signing-key"
+	logs := captureLog(t)
+
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.DefaultCost)
+	require.NoError(t, err)
+
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		IP:                 "192.0.2.1",
+		Email:              "user@example.com",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	refresh := func(token string) *http.Response {
+		body, _ := json.Marshal(response.RefreshToken{
+			GUID:         guid,
+			RefreshToken: base64.StdEncoding.EncodeToString([]byte(token)),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body))
+		req.RemoteAddr = "198.51.100.7:1234"
+		w := httptest.NewRecorder()
+		handlers.RefreshHandler(mockDB, ownKey, time.Minute).ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	wrongToken := guid + ":ffffffffffffffffffffffffffffffff"
+	assert.Equal(t, http.StatusUnauthorized, refresh(wrongToken).StatusCode)
+
+	resp := refresh(refreshToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var issued response.UserResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&issued))
+
+	newRefresh, err := base64.StdEncoding.DecodeString(issued.GetRefreshToken)
+	require.NoError(t, err)
+
+	// Повторное использование старого токена и обмен нового через OAuth
+	assert.Equal(t, http.StatusUnauthorized, refresh(refreshToken).StatusCode)
+	tokenResp := postTokenForm(handlers.TokenHandler(mockDB, ownKey, time.Minute), url.Values{
+		"grant_type":    {handlers.GrantRefreshToken},
+		"refresh_token": {string(newRefresh)},
+	})
+	require.Equal(t, http.StatusOK, tokenResp.StatusCode)
+	var oauth response.TokenResponse
+	require.NoError(t, json.NewDecoder(tokenResp.Body).Decode(&oauth))
+
+	require.NotEmpty(t, logs.String(), "handlers are expected to log the requests")
+	secrets := map[string]string{
+		"own key":                 ownKey,
+		"submitted refresh token": strings.TrimPrefix(refreshToken, guid),
+		"wrong refresh token":     strings.TrimPrefix(wrongToken, guid),
+		"encoded refresh token":   base64.StdEncoding.EncodeToString([]byte(refreshToken)),
+		"refresh token hash":      string(hashedToken),
+		"issued access token":     issued.AccessToken,
+		"issued refresh token":    strings.TrimPrefix(string(newRefresh), guid),
+		"encoded issued refresh":  issued.GetRefreshToken,
+		"oauth access token":      oauth.AccessToken,
+		"oauth refresh token":     strings.TrimPrefix(oauth.RefreshToken, guid),
+	}
+	assertNotLogged(t, logs.String(), secrets)
+}
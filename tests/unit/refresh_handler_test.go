@@ -2,6 +2,7 @@ package unit_tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
@@ -27,12 +28,53 @@ func (m *RMockDB) Close() error {
 	panic("unimplemented")
 }
 
-func (m *RMockDB) UpdateUser(user *models.User) error {
+func (m *RMockDB) CreateUser(_ context.Context, user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
 }
 
-func (m *RMockDB) GetUserByGUID(guid string) (*models.User, error) {
+func (m *RMockDB) ListUsers(_ context.Context, limit, offset int) ([]models.User, error) {
+	args := m.Called(limit, offset)
+	users, _ := args.Get(0).([]models.User)
+	return users, args.Error(1)
+}
+
+func (m *RMockDB) DeleteUser(_ context.Context, guid string) error {
+	args := m.Called(guid)
+	return args.Error(0)
+}
+
+func (m *RMockDB) SetUserStatus(_ context.Context, guid string, status models.UserStatus, reason string, until *time.Time) error {
+	args := m.Called(guid, status, reason, until)
+	return args.Error(0)
+}
+
+func (m *RMockDB) CreateSession(_ context.Context, session *models.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *RMockDB) IsTokenRevoked(_ context.Context, jti string) (bool, error) {
+	args := m.Called(jti)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *RMockDB) UpdateUser(_ context.Context, user *models.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *RMockDB) UpdateUserEmail(_ context.Context, guid, email string) error {
+	args := m.Called(guid, email)
+	return args.Error(0)
+}
+
+func (m *RMockDB) RotateRefreshToken(_ context.Context, user *models.User, previousHash string) error {
+	args := m.Called(user, previousHash)
+	return args.Error(0)
+}
+
+func (m *RMockDB) GetUserByGUID(_ context.Context, guid string) (*models.User, error) {
 	args := m.Called(guid)
 	user, ok := args.Get(0).(*models.User)
 	if !ok {
@@ -57,6 +99,8 @@ func TestRefreshHandler(t *testing.T) {
 			HashedRefreshToken: string(hashedToken),
 		}, nil)
 		mockDB.On("UpdateUser", mock.Anything).Return(nil)
+		mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+		mockDB.On("CreateSession", mock.Anything).Return(nil)
 
 		body, _ := json.Marshal(response.RefreshToken{
 			GUID:         guid,
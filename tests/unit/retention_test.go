@@ -0,0 +1,194 @@
+package unit_tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/retention"
+)
+
+// fakeRetentionStore хранит для каждой таблицы число устаревших записей
+type fakeRetentionStore struct {
+	stale    map[string]int64
+	cutoffs  map[string]time.Time
+	batches  map[string]int
+	locked   bool
+	unlocked bool
+	failOn   string
+}
+
+func newFakeRetentionStore(stale map[string]int64) *fakeRetentionStore {
+	return &fakeRetentionStore{
+		stale:   stale,
+		cutoffs: map[string]time.Time{},
+		batches: map[string]int{},
+	}
+}
+
+func (s *fakeRetentionStore) prune(table string, cutoff time.Time, limit int) (int64, error) {
+	s.cutoffs[table] = cutoff
+	s.batches[table]++
+	if table == s.failOn {
+		return 0, errors.New("connection reset")
+	}
+
+	n := s.stale[table]
+	if n > int64(limit) {
+		n = int64(limit)
+	}
+	s.stale[table] -= n
+	return n, nil
+}
+
+func (s *fakeRetentionStore) PruneRefreshTokens(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableRefreshTokens, before, limit)
+}
+
+func (s *fakeRetentionStore) PruneSessions(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableSessions, before, limit)
+}
+
+func (s *fakeRetentionStore) PruneDenylist(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableDenylist, before, limit)
+}
+
+func (s *fakeRetentionStore) PruneAuditEvents(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableAuditEvents, before, limit)
+}
+
+func (s *fakeRetentionStore) PruneRateLimits(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableRateLimits, before, limit)
+}
+
+func (s *fakeRetentionStore) PruneRefreshLockouts(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableLockouts, before, limit)
+}
+
+func (s *fakeRetentionStore) PruneReplayKeys(_ context.Context, before time.Time, limit int) (int64, error) {
+	return s.prune(retention.TableReplayKeys, before, limit)
+}
+
+func (s *fakeRetentionStore) TryLock(_ context.Context, key int64) (func(), bool, error) {
+	if s.locked {
+		return nil, false, nil
+	}
+	s.locked = true
+	return func() { s.locked, s.unlocked = false, true }, true, nil
+}
+
+func TestRetentionWorkerPrunesInBatches(t *testing.T) {
+	store := newFakeRetentionStore(map[string]int64{
+		retention.TableRefreshTokens: 5,
+		retention.TableSessions:      10,
+		retention.TableDenylist:      1,
+		retention.TableAuditEvents:   100,
+	})
+	cfg := retention.Config{
+		BatchSize:     4,
+		RefreshTokens: 24 * time.Hour,
+		Sessions:      time.Hour,
+		DenylistGrace: 0,
+		AuditEvents:   0,
+	}
+
+	before := time.Now()
+	stats, ran, err := retention.NewWorker(store, 1, cfg).RunOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, ran)
+	assert.True(t, store.unlocked)
+	assert.Equal(t, int64(5), stats[retention.TableRefreshTokens])
+	assert.Equal(t, int64(10), stats[retention.TableSessions])
+	assert.Equal(t, int64(1), stats[retention.TableDenylist])
+	assert.Equal(t, 2, store.batches[retention.TableRefreshTokens])
+	assert.Equal(t, 3, store.batches[retention.TableSessions])
+
+	// Нулевой срок хранения журнала аудита отключает его очистку
+	assert.Zero(t, store.batches[retention.TableAuditEvents])
+	assert.Equal(t, int64(100), store.stale[retention.TableAuditEvents])
+
+	assert.WithinDuration(t, before.Add(-24*time.Hour), store.cutoffs[retention.TableRefreshTokens], time.Second)
+	assert.WithinDuration(t, before, store.cutoffs[retention.TableDenylist], time.Second)
+}
+
+func TestRetentionWorkerSkipsWithoutLock(t *testing.T) {
+	store := newFakeRetentionStore(map[string]int64{retention.TableSessions: 3})
+	store.locked = true
+
+	_, ran, err := retention.NewWorker(store, 1, retention.Config{BatchSize: 10, Sessions: time.Hour}).
+		RunOnce(context.Background())
+	require.NoError(t, err)
+
+	assert.False(t, ran)
+	assert.Zero(t, store.batches[retention.TableSessions])
+}
+
+func TestRetentionWorkerStopsOnError(t *testing.T) {
+	store := newFakeRetentionStore(map[string]int64{retention.TableSessions: 3, retention.TableAuditEvents: 3})
+	store.failOn = retention.TableSessions
+
+	_, ran, err := retention.NewWorker(store, 1, retention.Config{
+		BatchSize:   10,
+		Sessions:    time.Hour,
+		AuditEvents: time.Hour,
+	}).RunOnce(context.Background())
+
+	assert.Error(t, err)
+	assert.True(t, ran)
+	assert.True(t, store.unlocked)
+	assert.Zero(t, store.batches[retention.TableAuditEvents])
+}
+
+// stoppingRetentionStore отменяет контекст воркера во время удаления первой пачки,
+// как это делает остановка сервера
+type stoppingRetentionStore struct {
+	*fakeRetentionStore
+	stop     context.CancelFunc
+	batchErr error
+}
+
+func (s *stoppingRetentionStore) PruneRefreshTokens(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.stop()
+	s.batchErr = ctx.Err()
+	return s.fakeRetentionStore.PruneRefreshTokens(ctx, before, limit)
+}
+
+func TestRetentionWorkerStopsAfterCurrentBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &stoppingRetentionStore{
+		fakeRetentionStore: newFakeRetentionStore(map[string]int64{
+			retention.TableRefreshTokens: 10,
+			retention.TableSessions:      3,
+		}),
+		stop: cancel,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		retention.NewWorker(store, 1, retention.Config{
+			Interval:      time.Hour,
+			BatchSize:     4,
+			RefreshTokens: time.Hour,
+			Sessions:      time.Hour,
+		}).Run(ctx)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after cancellation")
+	}
+
+	assert.NoError(t, store.batchErr, "the batch in progress is not interrupted")
+	assert.Equal(t, 1, store.batches[retention.TableRefreshTokens])
+	assert.Equal(t, int64(6), store.stale[retention.TableRefreshTokens])
+	assert.Zero(t, store.batches[retention.TableSessions])
+	assert.True(t, store.unlocked)
+}
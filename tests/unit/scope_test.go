@@ -0,0 +1,148 @@
+package unit_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/domain/jwt"
+	"github.com/volchok96/auth-medods/internal/handlers"
+)
+
+func TestTokenHandlerScopes(t *testing.T) {
+	ownKey := "test_key"
+	guid := uuid.New().String()
+
+	secret, hash, err := clientauth.GenerateSecret()
+	require.NoError(t, err)
+	client := &models.Client{
+		ClientID:         "spa",
+		SecretHash:       hash,
+		AllowedScopes:    []string{"orders.read", "orders.write"},
+		AllowedAudiences: []string{"orders-api", "billing-api"},
+	}
+	store := newFakeClientStore(client)
+
+	// Мок возвращает один и тот же указатель, поэтому выданные области доступа
+	// сохраняются между запросами, как в базе
+	mockDB := new(RMockDB)
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{UserGUID: uuid.MustParse(guid)}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.TokenHandler(mockDB, ownKey, time.Minute,
+		handlers.WithClientAuth(clientauth.NewSecretMethod(store)))
+
+	post := func(form url.Values) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("spa", secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	issued := func(t *testing.T, resp *http.Response) (response.TokenResponse, *jwt.Claims) {
+		t.Helper()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var body response.TokenResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		claims, err := jwt.ParseAccessToken(body.AccessToken, ownKey)
+		require.NoError(t, err)
+		return body, claims
+	}
+
+	body, claims := issued(t, post(url.Values{
+		"grant_type": {handlers.GrantGUID},
+		"guid":       {guid},
+		"scope":      {"orders.read orders.write"},
+		"audience":   {"orders-api"},
+	}))
+	assert.Equal(t, "orders.read orders.write", body.Scope)
+	assert.Equal(t, []string{"orders.read", "orders.write"}, claims.Scope)
+	assert.Equal(t, []string{"orders-api"}, claims.Audience)
+	assert.Equal(t, "spa", claims.ClientID)
+
+	t.Run("refresh preserves the grant", func(t *testing.T) {
+		body, claims = issued(t, post(url.Values{
+			"grant_type":    {handlers.GrantRefreshToken},
+			"refresh_token": {body.RefreshToken},
+		}))
+		assert.Equal(t, []string{"orders.read", "orders.write"}, claims.Scope)
+		assert.Equal(t, []string{"orders-api"}, claims.Audience)
+	})
+
+	t.Run("refresh narrows the grant", func(t *testing.T) {
+		body, claims = issued(t, post(url.Values{
+			"grant_type":    {handlers.GrantRefreshToken},
+			"refresh_token": {body.RefreshToken},
+			"scope":         {"orders.read"},
+		}))
+		assert.Equal(t, "orders.read", body.Scope)
+		assert.Equal(t, []string{"orders.read"}, claims.Scope)
+	})
+
+	t.Run("refresh can't widen the grant", func(t *testing.T) {
+		resp := post(url.Values{
+			"grant_type":    {handlers.GrantRefreshToken},
+			"refresh_token": {body.RefreshToken},
+			"scope":         {"orders.read orders.write"},
+		})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_scope", decodeOAuthError(t, resp).Error)
+
+		resp = post(url.Values{
+			"grant_type":    {handlers.GrantRefreshToken},
+			"refresh_token": {body.RefreshToken},
+			"audience":      {"billing-api"},
+		})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_target", decodeOAuthError(t, resp).Error)
+	})
+
+	t.Run("scope not allowed for the client", func(t *testing.T) {
+		resp := post(url.Values{"grant_type": {handlers.GrantGUID}, "guid": {guid}, "scope": {"admin"}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_scope", decodeOAuthError(t, resp).Error)
+
+		resp = post(url.Values{"grant_type": {handlers.GrantGUID}, "guid": {guid}, "audience": {"hr-api"}})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_target", decodeOAuthError(t, resp).Error)
+	})
+
+	t.Run("client gets its allowed grant by default", func(t *testing.T) {
+		_, claims := issued(t, post(url.Values{"grant_type": {handlers.GrantClientCredentials}}))
+		assert.Equal(t, []string{"orders.read", "orders.write"}, claims.Scope)
+		assert.Equal(t, []string{"orders-api", "billing-api"}, claims.Audience)
+	})
+
+	// Токен без scope или aud ничем не ограничен, поэтому refresh не выдаёт его
+	// взамен ограниченного, когда клиенту запретили всё выданное раньше
+	t.Run("refresh fails when the whole grant was withdrawn", func(t *testing.T) {
+		refresh := url.Values{"grant_type": {handlers.GrantRefreshToken}, "refresh_token": {body.RefreshToken}}
+
+		client.AllowedScopes = []string{"orders.write"}
+		resp := post(refresh)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_scope", decodeOAuthError(t, resp).Error)
+
+		client.AllowedScopes = []string{"orders.read"}
+		client.AllowedAudiences = []string{"billing-api"}
+		resp = post(refresh)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Equal(t, "invalid_target", decodeOAuthError(t, resp).Error)
+	})
+}
@@ -0,0 +1,239 @@
+package unit_tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/volchok96/auth-medods/internal/clientauth"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+	"github.com/volchok96/auth-medods/internal/servertls"
+)
+
+// testCert - сертификат с ключом для TLS тестов
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// issueCert выпускает сертификат, подписанный parent; без parent - самоподписанный
+func issueCert(t *testing.T, cn string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) certPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})
+}
+
+func (c *testCert) keyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func (c *testCert) tlsCertificate(t *testing.T) tls.Certificate {
+	pair, err := tls.X509KeyPair(c.certPEM(), c.keyPEM(t))
+	require.NoError(t, err)
+	return pair
+}
+
+// writeCertFiles записывает сертификат и ключ и сдвигает время их изменения на age от текущего
+func writeCertFiles(t *testing.T, dir string, c *testCert, age time.Duration) (certFile, keyFile string) {
+	t.Helper()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, c.certPEM(), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, c.keyPEM(t), 0o600))
+	for _, f := range []string{certFile, keyFile} {
+		require.NoError(t, os.Chtimes(f, time.Now().Add(age), time.Now().Add(age)))
+	}
+	return certFile, keyFile
+}
+
+func servedCN(t *testing.T, r *servertls.Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	return cert.Leaf.Subject.CommonName
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertFiles(t, dir, issueCert(t, "v1", nil, false), -time.Hour)
+
+	reloader, err := servertls.NewReloader(certFile, keyFile, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", servedCN(t, reloader))
+
+	reloaded, err := reloader.Reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded, "unchanged files are not reloaded")
+
+	writeCertFiles(t, dir, issueCert(t, "v2", nil, false), -time.Minute)
+	reloaded, err = reloader.Reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "v2", servedCN(t, reloader))
+
+	// Файл, записанный наполовину, не заменяет рабочий сертификат
+	require.NoError(t, os.WriteFile(certFile, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600))
+	_, err = reloader.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "v2", servedCN(t, reloader))
+}
+
+// serveTLS запускает сервер, который отвечает отпечатком клиентского сертификата
+func serveTLS(t *testing.T, cfg servertls.Config) string {
+	t.Helper()
+	tlsConfig, _, err := servertls.New(cfg)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &http.Server{
+		TLSConfig: tlsConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.TLS.PeerCertificates) == 0 {
+				_, _ = io.WriteString(w, "none")
+				return
+			}
+			_, _ = io.WriteString(w, clientauth.Thumbprint(r.TLS.PeerCertificates[0]))
+		}),
+	}
+	go func() { _ = srv.ServeTLS(ln, "", "") }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	return "https://" + ln.Addr().String()
+}
+
+func tlsGet(t *testing.T, url string, server *testCert, client *testCert) (string, error) {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.cert)
+	clientTLS := &tls.Config{RootCAs: roots}
+	if client != nil {
+		clientTLS.Certificates = []tls.Certificate{client.tlsCertificate(t)}
+	}
+
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}).Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServerTLSClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	server := issueCert(t, "auth-medods", nil, false)
+	certFile, keyFile := writeCertFiles(t, dir, server, 0)
+
+	ca := issueCert(t, "clients CA", nil, true)
+	caFile := filepath.Join(dir, "clients-ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM(), 0o600))
+
+	trusted := issueCert(t, "gateway", ca, false)
+	foreign := issueCert(t, "stranger", nil, false)
+
+	t.Run("require", func(t *testing.T) {
+		url := serveTLS(t, servertls.Config{
+			CertFile: certFile, KeyFile: keyFile, ClientCA: caFile, ClientAuth: servertls.ClientAuthRequire,
+		})
+
+		body, err := tlsGet(t, url, server, trusted)
+		require.NoError(t, err)
+		assert.Equal(t, clientauth.Thumbprint(trusted.cert), body)
+
+		_, err = tlsGet(t, url, server, nil)
+		assert.Error(t, err, "a client without a certificate is rejected")
+		_, err = tlsGet(t, url, server, foreign)
+		assert.Error(t, err, "a certificate from another CA is rejected")
+	})
+
+	t.Run("request", func(t *testing.T) {
+		url := serveTLS(t, servertls.Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: servertls.ClientAuthRequest})
+
+		// Самоподписанный сертификат принимается: клиента определяет отпечаток
+		body, err := tlsGet(t, url, server, foreign)
+		require.NoError(t, err)
+		assert.Equal(t, clientauth.Thumbprint(foreign.cert), body)
+
+		body, err = tlsGet(t, url, server, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "none", body)
+	})
+
+	t.Run("missing CA bundle", func(t *testing.T) {
+		_, _, err := servertls.New(servertls.Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: servertls.ClientAuthOptional})
+		assert.Error(t, err)
+	})
+}
+
+func TestInternalListenerRoutes(t *testing.T) {
+	public := handlers.SetupRoutes(nil, "test_key", time.Minute, "admin_token", ratelimit.Config{},
+		handlers.WithInternalListener())
+	internal := handlers.SetupInternalRoutes(nil, "test_key", "admin_token")
+
+	status := func(h http.Handler, path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	for _, path := range []string{"/metrics", "/api/v1/admin/users", "/admin/users"} {
+		assert.Equal(t, http.StatusNotFound, status(public, path), "%s is internal", path)
+	}
+	assert.Equal(t, http.StatusOK, status(public, "/readyz"))
+
+	assert.Equal(t, http.StatusOK, status(internal, "/metrics"))
+	assert.Equal(t, http.StatusUnauthorized, status(internal, "/api/v1/admin/users"))
+	assert.Equal(t, http.StatusUnauthorized, status(internal, "/admin/users"))
+	assert.Equal(t, http.StatusOK, status(internal, "/healthz"))
+	assert.Equal(t, http.StatusNotFound, status(internal, "/api/v1/access"), "token endpoints stay public")
+}
@@ -0,0 +1,192 @@
+package unit_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/volchok96/auth-medods/internal/database/models"
+	"github.com/volchok96/auth-medods/internal/domain/api/response"
+	"github.com/volchok96/auth-medods/internal/handlers"
+	"github.com/volchok96/auth-medods/internal/ratelimit"
+	"github.com/volchok96/auth-medods/internal/tracing"
+)
+
+const (
+	incomingTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingParent   = "00f067aa0ba902b7"
+	incomingTraceHdr = "00-" + incomingTraceID + "-" + incomingParent + "-01"
+)
+
+// recordSpans подключает провайдер трасс, который сохраняет завершённые span в память
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	// Setup без экспортёра устанавливает только W3C propagation
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+	require.NoError(t, err)
+	return recorder
+}
+
+func spanByName(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name() == name {
+			return s
+		}
+	}
+	require.Failf(t, "span not found", "%s", name)
+	return nil
+}
+
+func spanAttr(s sdktrace.ReadOnlySpan, key string) any {
+	for _, kv := range s.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value.AsInterface()
+		}
+	}
+	return nil
+}
+
+// ctxMockDB запоминает контекст, с которым хендлер обращается к хранилищу
+type ctxMockDB struct {
+	*RMockDB
+	seen trace.SpanContext
+}
+
+func (m *ctxMockDB) GetUserByGUID(ctx context.Context, guid string) (*models.User, error) {
+	m.seen = trace.SpanContextFromContext(ctx)
+	return m.RMockDB.GetUserByGUID(ctx, guid)
+}
+
+func TestTracingContinuesIncomingTrace(t *testing.T) {
+	recorder := recordSpans(t)
+	logs := captureLog(t)
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set("traceparent", incomingTraceHdr)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	server := spans[0]
+	assert.Equal(t, "GET /openapi.json", server.Name())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Equal(t, incomingTraceID, server.SpanContext().TraceID().String())
+	assert.Equal(t, incomingParent, server.Parent().SpanID().String())
+	assert.Equal(t, "/openapi.json", spanAttr(server, "http.route"))
+	assert.EqualValues(t, http.StatusOK, spanAttr(server, "http.response.status_code"))
+
+	lines := logLines(t, logs)
+	require.NotEmpty(t, lines)
+	assert.Equal(t, incomingTraceID, lines[len(lines)-1]["trace_id"])
+}
+
+func TestTracingRefreshSpans(t *testing.T) {
+	recorder := recordSpans(t)
+
+	guid := uuid.New().String()
+	refreshToken := guid + ":0123456789abcdef0123456789abcdef"
+	hashedToken, err := bcrypt.GenerateFromPassword([]byte(refreshToken), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	mockDB := &ctxMockDB{RMockDB: new(RMockDB)}
+	mockDB.On("GetUserByGUID", guid).Return(&models.User{
+		UserGUID:           uuid.MustParse(guid),
+		HashedRefreshToken: string(hashedToken),
+		IP:                 "192.0.2.1",
+		Email:              "user@example.com",
+	}, nil)
+	mockDB.On("UpdateUser", mock.Anything).Return(nil)
+	mockDB.On("RotateRefreshToken", mock.Anything, mock.Anything).Return(nil)
+	mockDB.On("CreateSession", mock.Anything).Return(nil)
+
+	handler := handlers.Tracing(handlers.TokenMetrics("refresh")(handlers.RefreshHandler(mockDB, "test_key",
+		time.Minute, handlers.WithNotifier(failingNotifier{}))))
+	body, _ := json.Marshal(response.RefreshToken{
+		GUID:         guid,
+		RefreshToken: base64.StdEncoding.EncodeToString([]byte(refreshToken)),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/refresh", bytes.NewReader(body))
+	req.RemoteAddr = "198.51.100.7:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	server := spanByName(t, spans, http.MethodPost)
+	assert.Equal(t, server.SpanContext(), mockDB.seen, "storage gets the request span")
+
+	compare := spanByName(t, spans, "bcrypt.CompareHashAndPassword")
+	assert.Equal(t, server.SpanContext().SpanID(), compare.Parent().SpanID())
+	assert.Equal(t, true, spanAttr(compare, "bcrypt.match"))
+
+	issue := spanByName(t, spans, "domain.jwt.NewTokens")
+	assert.Equal(t, server.SpanContext().SpanID(), issue.Parent().SpanID())
+	hash := spanByName(t, spans, "bcrypt.GenerateFromPassword")
+	assert.Equal(t, issue.SpanContext().SpanID(), hash.Parent().SpanID())
+
+	notify := spanByName(t, spans, "notify.Notify")
+	assert.Equal(t, server.SpanContext().SpanID(), notify.Parent().SpanID())
+	assert.Equal(t, "ip_changed", spanAttr(notify, "notify.kind"))
+	assert.Equal(t, codes.Error, notify.Status().Code, "failed delivery marks the span")
+}
+
+func TestTracingRecordsFailureReason(t *testing.T) {
+	recorder := recordSpans(t)
+	router := handlers.SetupRoutes(nil, "test_key", time.Minute, "", ratelimit.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/refresh", bytes.NewReader([]byte("{")))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	server := spanByName(t, recorder.Ended(), "POST /api/v1/refresh")
+	assert.Equal(t, string(response.CodeInvalidRequest), spanAttr(server, "auth.failure_reason"))
+	assert.EqualValues(t, http.StatusBadRequest, spanAttr(server, "http.response.status_code"))
+	assert.Equal(t, codes.Unset, server.Status().Code, "client errors are not span errors")
+}
+
+func TestTracingStdoutExporter(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	var out bytes.Buffer
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    tracing.ExporterStdout,
+		ServiceName: "auth-medods-test",
+		Output:      &out,
+	})
+	require.NoError(t, err)
+
+	_, span := tracing.Start(context.Background(), "test-span")
+	span.End()
+	require.NoError(t, shutdown(context.Background()))
+
+	assert.Contains(t, out.String(), `"Name": "test-span"`)
+	assert.Contains(t, out.String(), "auth-medods-test")
+
+	_, err = tracing.Setup(context.Background(), tracing.Config{Exporter: "zipkin"})
+	assert.Error(t, err)
+}